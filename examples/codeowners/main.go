@@ -8,42 +8,15 @@ import (
 	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/apply"
 	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
 	"github.com/jessevdk/go-flags"
-	"github.com/pkg/errors"
 )
 
 // Creates or updates .github/CODEOWNERS
-func modifyRepository(codeowners string) func(codemod.Project) {
-	return func(code codemod.Project) {
-		fmt.Printf("creating or updating codemods file. codeowners=%s\n", codeowners)
+func modifyRepository(codeowners string) func(*codemod.Project) {
+	return func(project *codemod.Project) {
+		fmt.Printf("creating or updating codeowners file. project_root=%s codeowners=%s\n", project.Root(), codeowners)
 
-		projectRoot, err := os.Getwd()
-		if err != nil {
-			fmt.Printf("couldn't find project root => %+v", err)
-			return
-		}
-
-		err = os.MkdirAll(fmt.Sprintf("%s/.github", projectRoot), os.ModePerm)
-		if err != nil {
-			fmt.Printf("couldn't create .github folder => %+v", errors.WithStack(err))
-			return
-		}
-
-		fmt.Printf("creating codeowners file, project_root=%s\n", projectRoot)
-		file, err := os.OpenFile(
-			fmt.Sprintf("%s/.github/CODEOWNERS", projectRoot),
-			os.O_RDWR|os.O_CREATE|os.O_TRUNC,
-			os.ModePerm,
-		)
-		if err != nil {
-			fmt.Printf("couldn't open nor create => %+v", errors.WithStack(err))
-			return
-		}
-
-		defer file.Close()
-
-		_, err = file.Write([]byte(codeowners))
-		if err != nil {
-			fmt.Printf("couldn't modify CODEOWNERS file => %+v", errors.WithStack(err))
+		if err := project.CreateFile(".github/CODEOWNERS", []byte(codeowners)); err != nil {
+			fmt.Printf("couldn't create nor update CODEOWNERS file => %+v", err)
 		}
 	}
 }
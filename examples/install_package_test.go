@@ -4,21 +4,21 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"io/fs"
-	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/apply"
 	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
 )
 
-func installPkg(pkgName string) func(codemod.Project) {
-	return func(code codemod.Project) {
+func installPkg(pkgName string) func(*codemod.Project) {
+	return func(project *codemod.Project) {
 		fmt.Printf("installing %s\n", pkgName)
-		err := exec.Command("go", "get", pkgName).Run()
-		if err != nil {
+
+		cmd := exec.Command("go", "get", pkgName)
+		cmd.Dir = project.Root()
+
+		if err := cmd.Run(); err != nil {
 			fmt.Printf("error installing package: %+v\n", err)
 			return
 		}
@@ -40,17 +40,17 @@ func replaceImports(from, to string) func(*codemod.SourceFile) {
 	}
 }
 
-func deletePkgFolder(target string) func(codemod.Project) {
-	return func(code codemod.Project) {
-		projectRoot, _ := os.Getwd()
-
-		filepath.Walk(projectRoot, func(path string, _ fs.FileInfo, _ error) error {
-			if strings.HasSuffix(path, target) {
-				os.RemoveAll(path)
+func deletePkgFolder(target string) func(*codemod.Project) {
+	return func(project *codemod.Project) {
+		for _, pkg := range project.Packages() {
+			if !strings.HasSuffix(pkg.Directory, target) {
+				continue
 			}
 
-			return nil
-		})
+			if err := project.DeleteFile(pkg.Directory); err != nil {
+				fmt.Printf("error deleting package folder: %+v\n", err)
+			}
+		}
 	}
 }
 
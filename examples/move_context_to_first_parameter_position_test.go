@@ -1,7 +1,6 @@
 package main_test
 
 import (
-	"go/ast"
 	"strings"
 	"testing"
 
@@ -39,8 +38,13 @@ func Test_IfContextIsTheLastArgumentItBecomesTheFirst(t *testing.T) {
 		return nil
 	}
 
+	func qux(a, b int, ctx context.Context) error {
+		return foo(int64(a+b), ctx)
+	}
+
 	func main() {
 		_ = foo(1, context.Background())
+		_ = qux(1, 2, context.Background())
 	}
 	`)
 
@@ -50,51 +54,53 @@ func Test_IfContextIsTheLastArgumentItBecomesTheFirst(t *testing.T) {
 	// example:
 	// func foo(x int) {}
 	for _, function := range file.Functions() {
-		params := function.Params()
+		// ChangeSignature takes the position of each parameter,
+		// a, b int counts as two parameters.
+		count := 0
+		contextIndex := -1
 
 		// for each function parameter
 		// example:
 		// func(x int, y string) {}
 		// we would go through x and then y
-		for i, param := range params {
+		for _, param := range function.Params() {
+			names := len(param.Names)
+			if names == 0 {
+				names = 1
+			}
+
 			// we are looking for the type Context from any package.
 			// we will match these two for example:
 			// context.Context
 			// othercontext.Context
-			if !strings.HasSuffix(codemod.SourceCode(param.Type), ".Context") {
-				continue
+			if contextIndex == -1 && strings.HasSuffix(codemod.SourceCode(param.Type), ".Context") {
+				contextIndex = count
 			}
 
-			// swap context with first position argument
-			params[0], params[i] = params[i], params[0]
+			count += names
 		}
-	}
 
-	for _, calls := range file.FunctionCalls() {
-		for _, call := range calls {
-			for i, arg := range call.Node.Args {
-				if expr, ok := arg.(*ast.CallExpr); ok {
-					// if we are calling context.Background()
-					if fun, ok := expr.Fun.(*ast.SelectorExpr); ok {
-						if fun.X.(*ast.Ident).Name == "context" && fun.Sel.Name == "Background" {
-							// swap context argument with the first position argument
-							call.Node.Args[0], call.Node.Args[i] = call.Node.Args[i], call.Node.Args[0]
-						}
-					}
-				}
+		if contextIndex <= 0 {
+			continue
+		}
 
-				if expr, ok := arg.(*ast.Ident); ok {
-					// if we are passing context.Context as argument to a function
-					// example:
-					// foo(userID, ctx)
-					if expr.Name == "ctx" || expr.Name == "context" {
-						call.Node.Args[0], call.Node.Args[i] = call.Node.Args[i], call.Node.Args[0]
-					}
-				}
+		// move context to the first position,
+		// the function calls are changed as well.
+		newParams := []codemod.Parameter{codemod.KeepParameter(contextIndex)}
+
+		for i := 0; i < count; i++ {
+			if i != contextIndex {
+				newParams = append(newParams, codemod.KeepParameter(i))
 			}
 		}
+
+		skipped, err := function.ChangeSignature(newParams)
+		assert.NoError(t, err)
+		assert.Empty(t, skipped)
 	}
 
+	// interfaces that no function in the file implements
+	// are changed by hand.
 	for _, typeDecl := range file.TypeDeclarations() {
 		for _, method := range typeDecl.Methods() {
 			params := method.Params()
@@ -132,8 +138,13 @@ func foo(ctx context.Context, userID int64) error {
 	return nil
 }
 
+func qux(ctx context.Context, a, b int) error {
+	return foo(ctx, int64(a+b))
+}
+
 func main() {
 	_ = foo(context.Background(), 1)
+	_ = qux(context.Background(), 1, 2)
 }
 `
 
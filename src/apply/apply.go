@@ -26,7 +26,7 @@ type Codemod struct {
 
 type projectCodemod struct {
 	description string
-	transform   func(codemod.Project)
+	transform   func(*codemod.Project)
 }
 
 type sourceFileCodemod struct {
@@ -63,6 +63,15 @@ type CliArgs struct {
 	//
 	// Should be in the format regex_to_match:new_value.
 	Replacements map[string]string `long:"replace" description:"replaces whatever matches the regex on left to whatever is on the right"`
+	// If the user wants codemods to have access to type information,
+	// Go files are parsed and type checked package by package.
+	TypeCheck bool `long:"type_check" description:"type check packages before applying codemods so codemods can use type information"`
+	// If the user wants pull requests to change only the code that codemods changed,
+	// the parts of files that were not changed are kept as they were instead of being formatted.
+	MinimalDiff bool `long:"minimal_diff" description:"only rewrite the parts of files that codemods changed instead of formatting whole files"`
+	// If the user wants imports to be fixed after codemods are applied,
+	// unused imports are removed and missing imports are added like goimports does.
+	FixImports bool `long:"fix_imports" description:"remove unused imports and add missing imports to files changed by codemods"`
 }
 
 var ErrArgumentIsRequired = errors.New("argument is required")
@@ -312,7 +321,7 @@ func (applier *Applier) repositoriesFoundUsingCodeSearch() bool {
 func (applier *Applier) setCodemods(codemods []Codemod) {
 	for _, mod := range codemods {
 		switch transform := mod.Transform.(type) {
-		case func(codemod.Project):
+		case func(*codemod.Project):
 			applier.projectCodemods = append(applier.projectCodemods, projectCodemod{
 				description: mod.Description,
 				transform:   transform,
@@ -459,26 +468,7 @@ func (applier *Applier) applyCodemodsToRemoteRepositories(ctx context.Context, r
 					return pullRequestURL, err
 				}
 
-				originalDir, err := os.Getwd()
-				if err != nil {
-					return pullRequestURL, err
-				}
-
-				if err := os.Chdir(repoTempFolder); err != nil {
-					return pullRequestURL, err
-				}
-
-				fmt.Printf("applying project codemods. num_project_codemods=%d\n", len(applier.projectCodemods))
-
-				for _, mod := range applier.projectCodemods {
-					mod.transform(codemod.Project{})
-				}
-
-				if err := applyCodemodsToDirectory(repoTempFolder, applier.args.Replacements, applier.sourceFileCodemods); err != nil {
-					return pullRequestURL, err
-				}
-
-				if err := os.Chdir(originalDir); err != nil {
+				if err := applyCodemodsToDirectory(repoTempFolder, applier.args.Replacements, applier.projectCodemods, applier.sourceFileCodemods, applier.directoryOptions()); err != nil {
 					return pullRequestURL, err
 				}
 
@@ -558,28 +548,21 @@ func (applier *Applier) applyCodemodsToRemoteRepositories(ctx context.Context, r
 
 // Applies codemods to a local directory.
 func (applier *Applier) applyCodemodsLocally(ctx context.Context) error {
-	originalDir, err := os.Getwd()
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	if err := os.Chdir(*applier.args.LocalDirectory); err != nil {
+	if err := applyCodemodsToDirectory(*applier.args.LocalDirectory, applier.args.Replacements, applier.projectCodemods, applier.sourceFileCodemods, applier.directoryOptions()); err != nil {
 		return errors.WithStack(err)
 	}
 
-	for _, mod := range applier.projectCodemods {
-		mod.transform(codemod.Project{})
-	}
-
-	if err := applyCodemodsToDirectory(*applier.args.LocalDirectory, applier.args.Replacements, applier.sourceFileCodemods); err != nil {
-		return errors.WithStack(err)
-	}
+	return nil
+}
 
-	if err := os.Chdir(originalDir); err != nil {
-		return errors.WithStack(err)
+// Returns the options used to apply codemods to a directory
+// based on the command line arguments.
+func (applier *Applier) directoryOptions() directoryOptions {
+	return directoryOptions{
+		typeCheck:   applier.args.TypeCheck,
+		minimalDiff: applier.args.MinimalDiff,
+		fixImports:  applier.args.FixImports,
 	}
-
-	return nil
 }
 
 func (applier *Applier) buildPullRequestDescription() string {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
@@ -74,7 +75,7 @@ func Test_applyCodemodsToDirectory(t *testing.T) {
 				},
 			}
 
-			err := applyCodemodsToDirectory(tempFolder, map[string]string{}, mods)
+			err := applyCodemodsToDirectory(tempFolder, map[string]string{}, nil, mods, directoryOptions{})
 
 			assert.Equal(t, panicErr, err)
 		})
@@ -87,7 +88,7 @@ func Test_applyCodemodsToDirectory(t *testing.T) {
 				},
 			}
 
-			err := applyCodemodsToDirectory(tempFolder, map[string]string{}, mods)
+			err := applyCodemodsToDirectory(tempFolder, map[string]string{}, nil, mods, directoryOptions{})
 
 			assert.Equal(t, "unexpected panic => a", err.Error())
 		})
@@ -102,7 +103,70 @@ func Test_applyCodemodsToDirectory(t *testing.T) {
 				},
 			}
 
-			assert.Nil(t, applyCodemodsToDirectory(tempFolder, map[string]string{}, mods))
+			assert.Nil(t, applyCodemodsToDirectory(tempFolder, map[string]string{}, nil, mods, directoryOptions{}))
+		})
+
+		t.Run("when type checking is enabled, source files have type information", func(t *testing.T) {
+			files := 0
+			typeChecked := 0
+
+			mods := []sourceFileCodemod{
+				{
+					description: "checks type information",
+					transform: func(code *codemod.SourceFile) {
+						files++
+
+						if code.IsTypeChecked() {
+							typeChecked++
+						}
+					},
+				},
+			}
+
+			assert.Nil(t, applyCodemodsToDirectory(tempFolder, map[string]string{}, nil, mods, directoryOptions{typeCheck: true}))
+
+			assert.Greater(t, files, 0)
+			assert.Equal(t, files, typeChecked)
+		})
+
+		t.Run("ignores vendor folders inside of the directory but not the folders the directory is in", func(t *testing.T) {
+			projectFolder := fmt.Sprintf("%s/vendor/project", testFolder)
+
+			assert.Nil(t, os.MkdirAll(fmt.Sprintf("%s/vendor/dependency", projectFolder), os.ModePerm))
+			assert.Nil(t, ioutil.WriteFile(fmt.Sprintf("%s/main.go", projectFolder), []byte("package main\n"), os.ModePerm))
+			assert.Nil(t, ioutil.WriteFile(fmt.Sprintf("%s/vendor/dependency/dependency.go", projectFolder), []byte("package dependency\n"), os.ModePerm))
+
+			visited := make([]string, 0)
+
+			mods := []sourceFileCodemod{
+				{
+					description: "records the files it is applied to",
+					transform: func(code *codemod.SourceFile) {
+						visited = append(visited, filepath.Base(code.FilePath))
+					},
+				},
+			}
+
+			assert.Nil(t, applyCodemodsToDirectory(projectFolder, map[string]string{}, nil, mods, directoryOptions{}))
+
+			assert.Equal(t, []string{"main.go"}, visited)
+		})
+
+		t.Run("project codemods change files in the directory", func(t *testing.T) {
+			projectMods := []projectCodemod{
+				{
+					description: "creates CODEOWNERS",
+					transform: func(project *codemod.Project) {
+						assert.NoError(t, project.CreateFile(".github/CODEOWNERS", []byte("* @owner")))
+					},
+				},
+			}
+
+			assert.Nil(t, applyCodemodsToDirectory(testFolder, map[string]string{}, projectMods, nil, directoryOptions{}))
+
+			contents, err := ioutil.ReadFile(fmt.Sprintf("%s/.github/CODEOWNERS", testFolder))
+			assert.NoError(t, err)
+			assert.Equal(t, "* @owner", string(contents))
 		})
 	})
 }
@@ -1,10 +1,10 @@
 package apply
 
 import (
+	"bytes"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -13,10 +13,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-func isGoFile(filename string) bool {
-	return strings.HasSuffix(filename, ".go")
-}
-
 func compileRegexes(regexes map[string]string) (map[*regexp.Regexp]string, error) {
 	out := make(map[*regexp.Regexp]string, len(regexes))
 
@@ -32,17 +28,30 @@ func compileRegexes(regexes map[string]string) (map[*regexp.Regexp]string, error
 	return out, nil
 }
 
-// Traverses `directory` and applies each codemod to each Go file in
-// `directory` and its subdirectories.
+// Controls how codemods are applied to the files in a directory.
+type directoryOptions struct {
+	// When true, Go files are parsed and type checked package by package
+	// before codemods are applied so codemods can use type information.
+	typeCheck bool
+	// When true, only the parts of Go files that were changed by codemods
+	// are written back, the rest of the file is kept as it was.
+	minimalDiff bool
+	// When true, unused imports are removed and missing imports are added
+	// to the Go files changed by codemods and replacements.
+	fixImports bool
+}
+
+// Traverses `directory` and applies each project codemod to the project in `directory`
+// and each source file codemod to each Go file in `directory` and its subdirectories.
 //
 // Replacements are applied to every file.
 //
-// The vendor folder is ignored.
-func applyCodemodsToDirectory(directory string, replacements map[string]string, codemods []sourceFileCodemod) (err error) {
-	fmt.Printf("applying source file codemods and replacements . num_source_file_codemods=%d replacements=%+v\n", len(codemods), replacements)
+// The vendor and .git folders are ignored.
+func applyCodemodsToDirectory(directory string, replacements map[string]string, projectCodemods []projectCodemod, codemods []sourceFileCodemod, options directoryOptions) (err error) {
+	fmt.Printf("applying codemods and replacements . num_project_codemods=%d num_source_file_codemods=%d replacements=%+v\n", len(projectCodemods), len(codemods), replacements)
 	// If we have nothing to do with the repository files,
 	// we won't wast time traversing the directory.
-	if len(replacements) == 0 && len(codemods) == 0 {
+	if len(replacements) == 0 && len(projectCodemods) == 0 && len(codemods) == 0 {
 		return nil
 	}
 
@@ -62,72 +71,49 @@ func applyCodemodsToDirectory(directory string, replacements map[string]string,
 		return errors.WithStack(err)
 	}
 
-	err = filepath.Walk("./", func(path string, info fs.FileInfo, _ error) error {
-		if strings.Contains(path, "vendor") || info == nil || info.IsDir() {
-			return nil
-		}
+	files := make([]codemod.ProjectFile, 0)
 
-		file, err := os.OpenFile(path, os.O_RDWR, 0o644)
-		if err != nil {
-			return errors.WithStack(err)
+	err = filepath.Walk(directory, func(path string, info fs.FileInfo, _ error) error {
+		if info == nil {
+			return nil
 		}
 
-		sourceCode, err := ioutil.ReadAll(file)
+		// The path is relative to `directory`, so the directories
+		// `directory` is in don't cause its files to be ignored.
+		relativePath, err := filepath.Rel(directory, path)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 
-		for re, replacement := range replacementRegexes {
-			sourceCode = re.ReplaceAll(sourceCode, []byte(replacement))
-		}
-
-		if isGoFile(info.Name()) {
-			code, err := codemod.New(codemod.NewInput{
-				SourceCode: sourceCode,
-				FilePath:   path,
-			})
-			if err != nil {
-				return errors.WithStack(err)
+		if info.IsDir() {
+			if relativePath != "." && (info.Name() == ".git" || info.Name() == "vendor") {
+				return filepath.SkipDir
 			}
 
-			for _, mod := range codemods {
-				if err != nil {
-					return errors.WithStack(err)
-				}
-
-				mod.transform(code)
-
-				sourceCode = code.SourceCode()
-				if err != nil {
-					return errors.WithStack(err)
-				}
-			}
+			return nil
 		}
 
-		err = file.Truncate(0)
-		if err != nil {
-			return errors.WithStack(err)
+		if strings.Contains(relativePath, "vendor") {
+			return nil
 		}
 
-		_, err = file.Seek(0, 0)
+		sourceCode, err := ioutil.ReadFile(path)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 
-		_, err = file.Write(sourceCode)
-		if err != nil {
-			return errors.WithStack(err)
-		}
+		contents := sourceCode
 
-		err = file.Sync()
-		if err != nil {
-			return errors.WithStack(err)
+		for re, replacement := range replacementRegexes {
+			contents = re.ReplaceAll(contents, []byte(replacement))
 		}
 
-		err = file.Close()
-		if err != nil {
-			return errors.WithStack(err)
-		}
+		files = append(files, codemod.ProjectFile{
+			Path:     relativePath,
+			Contents: contents,
+			Mode:     info.Mode(),
+			Changed:  !bytes.Equal(contents, sourceCode),
+		})
 
 		return nil
 	})
@@ -135,5 +121,32 @@ func applyCodemodsToDirectory(directory string, replacements map[string]string,
 		return errors.WithStack(err)
 	}
 
+	project, err := codemod.NewProject(codemod.NewProjectInput{
+		Root:      directory,
+		Files:     files,
+		TypeCheck: options.typeCheck,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, mod := range projectCodemods {
+		mod.transform(project)
+	}
+
+	for _, code := range project.SourceFiles() {
+		for _, mod := range codemods {
+			mod.transform(code)
+		}
+	}
+
+	if options.fixImports {
+		project.FixImports()
+	}
+
+	if err := project.Save(codemod.SaveOptions{MinimalDiff: options.minimalDiff}); err != nil {
+		return errors.WithStack(err)
+	}
+
 	return nil
 }
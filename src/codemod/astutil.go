@@ -0,0 +1,279 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+)
+
+var (
+	nodeType         = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	posType          = reflect.TypeOf(token.NoPos)
+	objectType       = reflect.TypeOf((*ast.Object)(nil))
+	scopeType        = reflect.TypeOf((*ast.Scope)(nil))
+	commentGroupType = reflect.TypeOf((*ast.CommentGroup)(nil))
+)
+
+// Positions that tell the printer whether a token is present
+// instead of only telling where it is.
+//
+// If we zero them, the printer forgets about the token:
+// f(xs...) would become f(xs) for example.
+var presencePositions = map[reflect.Type]map[string]bool{
+	reflect.TypeOf(ast.CallExpr{}): {"Ellipsis": true},
+	reflect.TypeOf(ast.TypeSpec{}): {"Assign": true},
+	reflect.TypeOf(ast.GenDecl{}):  {"Lparen": true},
+}
+
+// Returns true if values of type `typ` hold ast nodes.
+func isNodeType(typ reflect.Type) bool {
+	if typ == commentGroupType {
+		return false
+	}
+
+	return (typ.Kind() == reflect.Interface || typ.Kind() == reflect.Ptr) && typ.Implements(nodeType)
+}
+
+// Returns true if the field should not be visited when traversing the tree.
+//
+// Comments are not part of the tree and *ast.File has fields that
+// point to nodes that are already reachable from its declarations.
+func isSkippedField(structType reflect.Type, field reflect.StructField) bool {
+	if field.Type == objectType || field.Type == scopeType || field.Type == commentGroupType {
+		return true
+	}
+
+	if structType == reflect.TypeOf(ast.File{}) {
+		return field.Name == "Imports" || field.Name == "Unresolved" || field.Name == "Comments"
+	}
+
+	return false
+}
+
+func isNilNode(node ast.Node) bool {
+	if node == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(node)
+
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
+
+// Visits every node in the tree rooted at `node` after its children
+// have been visited and replaces each node with the node returned by `f`.
+//
+// A node is only replaced if the new node can be stored where
+// the old node was. An *ast.Ident that is the name of a function
+// can't be replaced by a call expression, for example.
+func rewriteTree(node ast.Node, f func(ast.Node) ast.Node) ast.Node {
+	if isNilNode(node) {
+		return node
+	}
+
+	value := reflect.ValueOf(node)
+
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return f(node)
+	}
+
+	structValue := value.Elem()
+	structType := structValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		if isSkippedField(structType, field) || !structValue.Field(i).CanSet() {
+			continue
+		}
+
+		fieldValue := structValue.Field(i)
+
+		switch {
+		case isNodeType(field.Type):
+			rewriteValue(fieldValue, f)
+
+		case field.Type.Kind() == reflect.Slice && isNodeType(field.Type.Elem()):
+			for j := 0; j < fieldValue.Len(); j++ {
+				rewriteValue(fieldValue.Index(j), f)
+			}
+		}
+	}
+
+	return f(node)
+}
+
+func rewriteValue(value reflect.Value, f func(ast.Node) ast.Node) {
+	if value.IsNil() {
+		return
+	}
+
+	child := value.Interface().(ast.Node)
+
+	newChild := rewriteTree(child, f)
+
+	if newChild == child || isNilNode(newChild) {
+		return
+	}
+
+	if reflect.TypeOf(newChild).AssignableTo(value.Type()) {
+		value.Set(reflect.ValueOf(newChild))
+	}
+}
+
+// Creates deep copies of nodes.
+type cloner struct {
+	// Position given to positions that tell the printer whether a token is present,
+	// every other position is zeroed.
+	pos token.Pos
+	// Called before a node is copied. When it returns true,
+	// the returned nodes are used instead of a copy of the node.
+	//
+	// More than one node may be returned if the node is an element of a list.
+	replace func(ast.Node) ([]ast.Node, bool)
+	// When true, positions are copied instead of being zeroed.
+	keepPositions bool
+	// When not nil, maps each copy to the node it was copied from.
+	origins map[ast.Node]ast.Node
+}
+
+// Returns a deep copy of `node` without position information.
+func cloneNode(node ast.Node) ast.Node {
+	return (&cloner{}).clone(node)
+}
+
+func (c *cloner) clone(node ast.Node) ast.Node {
+	if isNilNode(node) {
+		return node
+	}
+
+	if nodes, ok := c.replacement(node); ok {
+		switch len(nodes) {
+		case 0:
+			return nil
+		case 1:
+			return nodes[0]
+		default:
+			panic(fmt.Sprintf("%d nodes can't be used where a single node is expected: %s", len(nodes), SourceCode(node)))
+		}
+	}
+
+	return c.copy(node)
+}
+
+func (c *cloner) replacement(node ast.Node) ([]ast.Node, bool) {
+	if c.replace == nil {
+		return nil, false
+	}
+
+	return c.replace(node)
+}
+
+// Copies `node` without asking if it should be replaced.
+func (c *cloner) copy(node ast.Node) ast.Node {
+	if isNilNode(node) {
+		return node
+	}
+
+	value := reflect.ValueOf(node)
+
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return node
+	}
+
+	structType := value.Elem().Type()
+
+	newValue := reflect.New(structType)
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		from := value.Elem().Field(i)
+		to := newValue.Elem().Field(i)
+
+		if !to.CanSet() || isSkippedField(structType, field) {
+			continue
+		}
+
+		switch {
+		case field.Type == posType:
+			if c.keepPositions {
+				to.Set(from)
+				continue
+			}
+
+			if presencePositions[structType][field.Name] && token.Pos(from.Int()).IsValid() {
+				to.Set(reflect.ValueOf(c.presencePosition()))
+			}
+
+		case isNodeType(field.Type):
+			if from.IsNil() {
+				continue
+			}
+
+			cloned := c.clone(from.Interface().(ast.Node))
+
+			if !isNilNode(cloned) {
+				to.Set(reflect.ValueOf(cloned))
+			}
+
+		case field.Type.Kind() == reflect.Slice && isNodeType(field.Type.Elem()):
+			if from.IsNil() {
+				continue
+			}
+
+			to.Set(c.cloneList(from))
+
+		case field.Type.Kind() == reflect.Slice:
+			if from.IsNil() {
+				continue
+			}
+
+			to.Set(reflect.AppendSlice(reflect.MakeSlice(field.Type, 0, from.Len()), from))
+
+		default:
+			to.Set(from)
+		}
+	}
+
+	copied := newValue.Interface().(ast.Node)
+
+	if c.origins != nil {
+		c.origins[copied] = node
+	}
+
+	return copied
+}
+
+func (c *cloner) cloneList(list reflect.Value) reflect.Value {
+	out := reflect.MakeSlice(list.Type(), 0, list.Len())
+
+	for i := 0; i < list.Len(); i++ {
+		element := list.Index(i).Interface().(ast.Node)
+
+		nodes, ok := c.replacement(element)
+		if !ok {
+			nodes = []ast.Node{c.copy(element)}
+		}
+
+		for _, node := range nodes {
+			nodeValue := reflect.ValueOf(node)
+
+			if !nodeValue.Type().AssignableTo(list.Type().Elem()) {
+				panic(fmt.Sprintf("%s can't be used as %s", SourceCode(node), list.Type().Elem()))
+			}
+
+			out = reflect.Append(out, nodeValue)
+		}
+	}
+
+	return out
+}
+
+func (c *cloner) presencePosition() token.Pos {
+	if c.pos.IsValid() {
+		return c.pos
+	}
+
+	return token.Pos(1)
+}
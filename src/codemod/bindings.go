@@ -0,0 +1,819 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Something that gives values to names: an assignment, like x := 1, x = 1, x += 1 or a, b = b, a,
+// or a variable or constant declaration, like var x int or const x = 1.
+//
+// Each spec of a declaration is a binding of its own, const (A = iota; B) has two bindings.
+type Binding struct {
+	// *ast.AssignStmt or *ast.ValueSpec.
+	Node   ast.Node
+	file   *SourceFile
+	cursor *Cursor
+}
+
+// Returns the assignments and the variable and constant declarations in the file,
+// inside functions and at the top level, in the order they appear in.
+func (code *SourceFile) Bindings(matchers ...Matcher) []Binding {
+	out := make([]Binding, 0)
+
+	isBinding := func(cursor *Cursor) bool {
+		switch cursor.Node().(type) {
+		case *ast.AssignStmt, *ast.ValueSpec:
+			return true
+		default:
+			return false
+		}
+	}
+
+	for _, match := range code.find("*", append([]Matcher{isBinding}, matchers...)) {
+		out = append(out, Binding{Node: match.Node, file: code, cursor: match.Cursor})
+	}
+
+	return out
+}
+
+// Returns the cursor that points to the assignment or to the spec of the declaration.
+func (binding *Binding) Cursor() *Cursor {
+	return binding.cursor
+}
+
+// Returns the innermost scope the binding is in.
+func (binding *Binding) Scope() Scope {
+	return scopeOf(binding.cursor)
+}
+
+// Returns the file, line and column where the assignment or the spec starts.
+func (binding *Binding) Position() token.Position {
+	return binding.file.nodePosition(binding.Node)
+}
+
+// Returns the token of the binding: token.DEFINE, token.ASSIGN or an assignment operator
+// like token.ADD_ASSIGN for assignments and token.VAR or token.CONST for declarations.
+func (binding *Binding) Tok() token.Token {
+	if assign, ok := binding.Node.(*ast.AssignStmt); ok {
+		return assign.Tok
+	}
+
+	return binding.genDecl().Tok
+}
+
+// Returns what values are given to: the left side of assignments and the names of declarations.
+func (binding *Binding) Targets() []ast.Expr {
+	switch node := binding.Node.(type) {
+	case *ast.AssignStmt:
+		return node.Lhs
+	default:
+		return identsToExprs(node.(*ast.ValueSpec).Names)
+	}
+}
+
+// Returns the targets as they are written in the source code, like x and c.x in x, c.x = 1, 2.
+func (binding *Binding) Names() []string {
+	out := make([]string, 0)
+
+	for _, target := range binding.Targets() {
+		out = append(out, SourceCode(target))
+	}
+
+	return out
+}
+
+// Returns the values of the binding. Declarations without values,
+// like var x int or B in const (A = iota; B), don't have values.
+func (binding *Binding) Values() []ast.Expr {
+	switch node := binding.Node.(type) {
+	case *ast.AssignStmt:
+		return node.Rhs
+	default:
+		return node.(*ast.ValueSpec).Values
+	}
+}
+
+// Returns the type of the declaration, like int in var x int, or nil if there's none.
+func (binding *Binding) Type() ast.Expr {
+	if spec, ok := binding.Node.(*ast.ValueSpec); ok {
+		return spec.Type
+	}
+
+	return nil
+}
+
+// Returns true if the binding is a declaration outside of functions.
+func (binding *Binding) IsPackageLevel() bool {
+	if _, ok := binding.Node.(*ast.ValueSpec); !ok {
+		return false
+	}
+
+	_, ok := binding.cursor.parent.Parent().(*ast.File)
+
+	return ok
+}
+
+// Splits a binding with more than one target into one binding for each target,
+// a, b := 1, 2 becomes a := 1 and b := 2 and var a, b = 1, 2 becomes var a = 1 and var b = 2.
+// The new bindings are returned in order.
+//
+// Returns error if the values come from a single expression, like in a, b := f(),
+// if a value uses a target that comes before it, like in a, b = b, a,
+// if a constant uses iota or if the binding is not in a list of statements, like the init statement of an if statement.
+func (binding *Binding) Split() ([]Binding, error) {
+	targets := binding.Targets()
+	values := binding.Values()
+
+	if len(targets) == 1 {
+		return []Binding{*binding}, nil
+	}
+
+	description := SourceCode(cloneNode(binding.Node))
+
+	if len(values) > 0 && len(values) != len(targets) {
+		return nil, errors.Errorf("can't split %s: the values come from a single expression", description)
+	}
+
+	if err := checkIndependentTargets(targets, values); err != nil {
+		return nil, errors.Wrapf(err, "can't split %s", description)
+	}
+
+	code := binding.file
+
+	var nodes []ast.Node
+
+	switch node := binding.Node.(type) {
+	case *ast.AssignStmt:
+		if !binding.cursor.InList() {
+			return nil, errors.Errorf("can't split %s: it's not in a list of statements", description)
+		}
+
+		declared := make(map[*ast.Ident]bool)
+		for _, ident := range code.declaredVariables(node) {
+			declared[ident] = true
+		}
+
+		stmts := make([]*ast.AssignStmt, 0, len(targets))
+
+		for i := range targets {
+			stmt := &ast.AssignStmt{Lhs: []ast.Expr{targets[i]}, Tok: node.Tok, Rhs: []ast.Expr{values[i]}}
+
+			// Targets that were declared before are assigned to.
+			if ident, ok := targets[i].(*ast.Ident); node.Tok == token.DEFINE && (!ok || !declared[ident]) {
+				stmt.Tok = token.ASSIGN
+			}
+
+			stmts = append(stmts, stmt)
+		}
+
+		// The assignment is kept as the first one so its comments stay where they are.
+		node.Lhs, node.Tok, node.Rhs = stmts[0].Lhs, stmts[0].Tok, stmts[0].Rhs
+		stmts[0] = node
+
+		for i := len(stmts) - 1; i > 0; i-- {
+			if err := binding.cursor.InsertAfter(stmts[i]); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+
+		for _, stmt := range stmts {
+			nodes = append(nodes, stmt)
+		}
+
+	case *ast.ValueSpec:
+		genDecl := binding.genDecl()
+
+		if genDecl.Tok == token.CONST {
+			if err := binding.checkConstCanChange(); err != nil {
+				return nil, errors.Wrapf(err, "can't split %s", description)
+			}
+		}
+
+		specs := make([]ast.Spec, 0, len(targets))
+
+		for i, name := range node.Names {
+			spec := &ast.ValueSpec{Names: []*ast.Ident{name}, Type: node.Type}
+
+			if i > 0 && node.Type != nil {
+				spec.Type = cloneNode(node.Type).(ast.Expr)
+			}
+
+			if len(values) > 0 {
+				spec.Values = []ast.Expr{values[i]}
+			}
+
+			specs = append(specs, spec)
+		}
+
+		node.Names, node.Values = specs[0].(*ast.ValueSpec).Names, specs[0].(*ast.ValueSpec).Values
+		specs[0] = node
+
+		code.replaceSpec(binding.cursor, specs)
+
+		for _, spec := range specs {
+			nodes = append(nodes, spec)
+		}
+	}
+
+	out := make([]Binding, 0, len(nodes))
+
+	for _, node := range nodes {
+		out = append(out, Binding{Node: node, file: code, cursor: code.cursorOf(node)})
+	}
+
+	return out, nil
+}
+
+// Merges the binding that comes right after the binding into it,
+// x := 1 followed by y := 2 becomes x, y := 1, 2.
+//
+// Returns error if there is no binding right after the binding, if the bindings
+// don't have the same token and type, if the second binding uses the targets of the first
+// or if the values of a binding come from a single expression, like in a, b := f().
+func (binding *Binding) MergeNext() error {
+	description := SourceCode(cloneNode(binding.Node))
+
+	next, ok := binding.next()
+	if !ok {
+		return errors.Errorf("can't merge %s: there's no binding right after it", description)
+	}
+
+	if binding.Tok() != next.Tok() || !sameSource(binding.Type(), next.Type()) {
+		return errors.Errorf("can't merge %s with %s: they are not the same kind of binding", description, SourceCode(cloneNode(next.Node)))
+	}
+
+	if tok := binding.Tok(); tok != token.DEFINE && tok != token.ASSIGN && tok != token.VAR && tok != token.CONST {
+		return errors.Errorf("can't merge %s: %s assignments can't have more than one target", description, tok)
+	}
+
+	for _, b := range []*Binding{binding, &next} {
+		if len(b.Values()) > 0 && len(b.Values()) != len(b.Targets()) {
+			return errors.Errorf("can't merge %s: the values of %s come from a single expression", description, SourceCode(cloneNode(b.Node)))
+		}
+	}
+
+	if (len(binding.Values()) == 0) != (len(next.Values()) == 0) {
+		return errors.Errorf("can't merge %s with %s: only one of them has values", description, SourceCode(cloneNode(next.Node)))
+	}
+
+	if binding.Tok() == token.CONST {
+		if err := binding.checkConstCanChange(); err != nil {
+			return errors.Wrapf(err, "can't merge %s", description)
+		}
+		if err := next.checkConstCanChange(); err != nil {
+			return errors.Wrapf(err, "can't merge %s", description)
+		}
+	}
+
+	if err := checkIndependentTargets(append(binding.Targets(), next.Targets()...), append(binding.Values(), next.Values()...)); err != nil {
+		return errors.Wrapf(err, "can't merge %s with %s", description, SourceCode(cloneNode(next.Node)))
+	}
+
+	switch node := binding.Node.(type) {
+	case *ast.AssignStmt:
+		nextNode := next.Node.(*ast.AssignStmt)
+
+		node.Lhs = append(node.Lhs, nextNode.Lhs...)
+		node.Rhs = append(node.Rhs, nextNode.Rhs...)
+
+	case *ast.ValueSpec:
+		nextNode := next.Node.(*ast.ValueSpec)
+
+		node.Names = append(node.Names, nextNode.Names...)
+		node.Values = append(node.Values, nextNode.Values...)
+	}
+
+	binding.file.MoveComments(next.Node, binding.Node)
+
+	return errors.WithStack(next.Remove())
+}
+
+// Removes the binding.
+//
+// Declarations that end up without specs are removed. When a constant in a group
+// is removed, the constants that repeat its values, like B in const (A = iota; B), get the values,
+// but the constants after it that use iota get a different value. Replace it with _ to keep the values.
+//
+// Returns error if the binding can't be removed without changing the statement it's in,
+// like the assignment in case v = <-ch: or in switch v := x.(type).
+func (binding *Binding) Remove() error {
+	switch node := binding.Node.(type) {
+	case *ast.AssignStmt:
+		return errors.WithStack(removeStmt(binding.cursor))
+
+	default:
+		genDecl := binding.genDecl()
+
+		index := specIndex(genDecl, node)
+		if index == -1 {
+			return errors.Errorf("can't remove %s: it has already been removed", SourceCode(cloneNode(node)))
+		}
+
+		spec := node.(*ast.ValueSpec)
+
+		if index+1 < len(genDecl.Specs) && genDecl.Tok == token.CONST {
+			next := genDecl.Specs[index+1].(*ast.ValueSpec)
+
+			if len(next.Values) == 0 && len(spec.Values) > 0 {
+				next.Type = cloneType(spec.Type)
+				next.Values = cloneExprs(spec.Values)
+			}
+		}
+
+		binding.file.replaceSpec(binding.cursor, nil)
+
+		return nil
+	}
+}
+
+// Removes the target `name`, as it's written in the source code, and its value from the binding,
+// a, b := 1, 2 becomes b := 2. The binding is removed if it has no other targets.
+//
+// Returns error if the binding does not have the target, if the values come from
+// a single expression, like in a, b := f(), or if constants after it repeat its values.
+func (binding *Binding) RemoveName(name string) error {
+	targets := binding.Targets()
+	values := binding.Values()
+
+	index := -1
+	for i, target := range targets {
+		if SourceCode(target) == name {
+			index = i
+		}
+	}
+
+	description := SourceCode(cloneNode(binding.Node))
+
+	if index == -1 {
+		return errors.Errorf("can't remove %s: %s does not have it", name, description)
+	}
+
+	if len(targets) == 1 {
+		return errors.WithStack(binding.Remove())
+	}
+
+	if len(values) > 0 && len(values) != len(targets) {
+		return errors.Errorf("can't remove %s from %s: the values come from a single expression, assign it to _ instead", name, description)
+	}
+
+	switch node := binding.Node.(type) {
+	case *ast.AssignStmt:
+		declared := binding.file.declaredVariables(node)
+
+		node.Lhs = removeExprAt(node.Lhs, index)
+		node.Rhs = removeExprAt(node.Rhs, index)
+
+		if node.Tok == token.DEFINE && !declaresAny(declared, node.Lhs) {
+			node.Tok = token.ASSIGN
+		}
+
+	case *ast.ValueSpec:
+		if binding.genDecl().Tok == token.CONST {
+			if err := binding.checkConstCanChange(); err != nil {
+				return errors.Wrapf(err, "can't remove %s from %s", name, description)
+			}
+		}
+
+		node.Names = append(node.Names[:index:index], node.Names[index+1:]...)
+		if len(node.Values) > 0 {
+			node.Values = removeExprAt(node.Values, index)
+		}
+	}
+
+	return nil
+}
+
+// Replaces the binding with `source`.
+//
+// Assignments and declarations inside functions that are not in a group
+// are replaced by statements, like x := 1 or var x, y int.
+// Declarations outside functions that are not in a group are replaced
+// by declarations, like const x = 1. Specs in a group, like x = 1 in var (x = 1; y = 2),
+// are replaced by the specs of declarations of the same kind, like var x, z = 1, 3.
+//
+// Returns error if `source` is not valid where the binding is.
+func (binding *Binding) Replace(source string) error {
+	code := binding.file
+
+	spec, isSpec := binding.Node.(*ast.ValueSpec)
+
+	if !isSpec || !binding.genDecl().Lparen.IsValid() && !binding.IsPackageLevel() {
+		stmts, err := parseStmts(source)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		cursor := binding.cursor
+		if isSpec {
+			cursor = binding.cursor.parent.parent
+		}
+
+		return errors.WithStack(replaceStmt(cursor, stmts))
+	}
+
+	decls, err := parseDecls(source)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !binding.genDecl().Lparen.IsValid() {
+		genDecl := binding.genDecl()
+
+		out := make([]ast.Decl, 0, len(code.file.Decls)+len(decls))
+
+		for _, decl := range code.file.Decls {
+			if decl != genDecl {
+				out = append(out, decl)
+				continue
+			}
+
+			if len(decls) > 0 {
+				code.MoveComments(genDecl, decls[0])
+			}
+
+			out = append(out, decls...)
+		}
+
+		code.file.Decls = out
+
+		return nil
+	}
+
+	specs := make([]ast.Spec, 0)
+
+	for _, decl := range decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != binding.genDecl().Tok {
+			return errors.Errorf("can't replace %s with %s: %s declarations can only have %s specs",
+				SourceCode(cloneNode(spec)), source, binding.genDecl().Tok, binding.genDecl().Tok)
+		}
+
+		specs = append(specs, genDecl.Specs...)
+	}
+
+	if len(specs) > 0 {
+		code.MoveComments(spec, specs[0])
+	}
+
+	code.replaceSpec(binding.cursor, specs)
+
+	return nil
+}
+
+// Returns the declaration the spec belongs to.
+func (binding *Binding) genDecl() *ast.GenDecl {
+	return binding.cursor.parent.node.(*ast.GenDecl)
+}
+
+// Returns the binding that comes right after the binding: the next statement
+// if it's an assignment, the next spec of a group, or the next declaration if
+// the declaration is not in a group.
+func (binding *Binding) next() (Binding, bool) {
+	code := binding.file
+
+	nextNode := func(cursor *Cursor) ast.Node {
+		field, index, err := cursor.list()
+		if err != nil || index+1 >= field.Len() {
+			return nil
+		}
+
+		return field.Index(index + 1).Interface().(ast.Node)
+	}
+
+	var node ast.Node
+
+	switch binding.Node.(type) {
+	case *ast.AssignStmt:
+		node = nextNode(binding.cursor)
+
+	case *ast.ValueSpec:
+		genDecl := binding.genDecl()
+
+		if genDecl.Lparen.IsValid() {
+			node = nextNode(binding.cursor)
+			break
+		}
+
+		declCursor := binding.cursor.parent
+		if !binding.IsPackageLevel() {
+			declCursor = declCursor.parent
+		}
+
+		if next := nextNode(declCursor); next != nil {
+			if declStmt, ok := next.(*ast.DeclStmt); ok {
+				next = declStmt.Decl
+			}
+
+			if nextDecl, ok := next.(*ast.GenDecl); ok && !nextDecl.Lparen.IsValid() && len(nextDecl.Specs) == 1 {
+				node = nextDecl.Specs[0]
+			}
+		}
+	}
+
+	switch node.(type) {
+	case *ast.AssignStmt, *ast.ValueSpec:
+		return Binding{Node: node, file: code, cursor: code.cursorOf(node)}, true
+	default:
+		return Binding{}, false
+	}
+}
+
+// Returns error if changing the constant spec would change the values of other constants:
+// its values use iota, which depends on the position of the spec,
+// or the spec after it repeats its values.
+func (binding *Binding) checkConstCanChange() error {
+	spec := binding.Node.(*ast.ValueSpec)
+
+	if len(spec.Values) == 0 {
+		return errors.New("the constant repeats the values of the constant before it")
+	}
+
+	for _, value := range spec.Values {
+		if mentionsName(value, "iota") {
+			return errors.New("the value uses iota")
+		}
+	}
+
+	genDecl := binding.genDecl()
+
+	if index := specIndex(genDecl, spec); index+1 < len(genDecl.Specs) && len(genDecl.Specs[index+1].(*ast.ValueSpec).Values) == 0 {
+		return errors.New("the constants after it repeat its values")
+	}
+
+	return nil
+}
+
+// Returns error if a target or value uses a target that comes before it,
+// since they would not see the old value anymore once the targets are assigned one by one.
+func checkIndependentTargets(targets, values []ast.Expr) error {
+	for i := range targets {
+		uses := make([]ast.Node, 0, 2)
+
+		if i < len(values) {
+			uses = append(uses, values[i])
+		}
+
+		// The target itself is written to, the indices and selectors in it are read.
+		if _, ok := targets[i].(*ast.Ident); !ok {
+			uses = append(uses, targets[i])
+		}
+
+		for _, previous := range targets[:i] {
+			name := rootName(previous)
+			if name == "" || name == "_" {
+				continue
+			}
+
+			for _, use := range uses {
+				if mentionsName(use, name) {
+					return errors.Errorf("%s uses %s, which is assigned before it", SourceCode(cloneNode(use)), name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the name of the variable that `expr` is part of, like x in x.y[i], or an empty string.
+func rootName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		return rootName(expr.X)
+	case *ast.IndexExpr:
+		return rootName(expr.X)
+	case *ast.StarExpr:
+		return rootName(expr.X)
+	case *ast.ParenExpr:
+		return rootName(expr.X)
+	default:
+		return ""
+	}
+}
+
+// Returns true if `node` has an identifier called `name`.
+func mentionsName(node ast.Node, name string) bool {
+	found := false
+
+	ast.Inspect(node, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+
+	return found
+}
+
+func sameSource(a, b ast.Node) bool {
+	if isNilNode(a) || isNilNode(b) {
+		return isNilNode(a) && isNilNode(b)
+	}
+
+	return SourceCode(cloneNode(a)) == SourceCode(cloneNode(b))
+}
+
+// Returns true if one of the identifiers in `declared` is in `exprs`.
+func declaresAny(declared []*ast.Ident, exprs []ast.Expr) bool {
+	for _, ident := range declared {
+		for _, expr := range exprs {
+			if expr == ident {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func removeExprAt(exprs []ast.Expr, index int) []ast.Expr {
+	return append(exprs[:index:index], exprs[index+1:]...)
+}
+
+func cloneExprs(exprs []ast.Expr) []ast.Expr {
+	out := make([]ast.Expr, 0, len(exprs))
+
+	for _, expr := range exprs {
+		out = append(out, cloneNode(expr).(ast.Expr))
+	}
+
+	return out
+}
+
+func cloneType(typ ast.Expr) ast.Expr {
+	if typ == nil {
+		return nil
+	}
+
+	return cloneNode(typ).(ast.Expr)
+}
+
+// Returns the position of `spec` in the specs of `genDecl` or -1 if it's not there.
+func specIndex(genDecl *ast.GenDecl, spec ast.Node) int {
+	for i, existing := range genDecl.Specs {
+		if existing == spec {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Replaces the spec `cursor` points to with `specs`.
+//
+// Specs of a group stay in the group. A declaration that's not in a group is replaced
+// by a declaration for each spec, and removed if there are no specs.
+func (code *SourceFile) replaceSpec(cursor *Cursor, specs []ast.Spec) {
+	genDecl := cursor.parent.node.(*ast.GenDecl)
+	index := specIndex(genDecl, cursor.node)
+
+	if genDecl.Lparen.IsValid() || len(specs) == 1 {
+		list := make([]ast.Spec, 0, len(genDecl.Specs)+len(specs))
+		list = append(list, genDecl.Specs[:index]...)
+		list = append(list, specs...)
+		list = append(list, genDecl.Specs[index+1:]...)
+
+		genDecl.Specs = list
+
+		if len(list) > 0 {
+			return
+		}
+	}
+
+	decls := make([]*ast.GenDecl, 0, len(specs))
+
+	for i, spec := range specs {
+		// The declaration is kept for the first spec so its comments stay where they are.
+		if i == 0 {
+			genDecl.Specs = []ast.Spec{spec}
+			decls = append(decls, genDecl)
+			continue
+		}
+
+		decls = append(decls, &ast.GenDecl{Tok: genDecl.Tok, Specs: []ast.Spec{spec}})
+	}
+
+	declCursor := cursor.parent
+
+	if declStmt, ok := declCursor.parent.node.(*ast.DeclStmt); ok && declStmt.Decl == genDecl {
+		stmts := make([]ast.Stmt, 0, len(decls))
+		for _, decl := range decls {
+			stmts = append(stmts, &ast.DeclStmt{Decl: decl})
+		}
+
+		if len(stmts) > 0 {
+			stmts[0] = declStmt
+		}
+
+		_ = replaceStmt(declCursor.parent, stmts)
+
+		return
+	}
+
+	out := make([]ast.Decl, 0, len(code.file.Decls)+len(decls))
+
+	for _, decl := range code.file.Decls {
+		if decl != genDecl {
+			out = append(out, decl)
+			continue
+		}
+
+		for _, newDecl := range decls {
+			out = append(out, newDecl)
+		}
+	}
+
+	code.file.Decls = out
+}
+
+// Replaces the statement `cursor` points to with `stmts`, the statement is removed if `stmts` is empty.
+//
+// Returns error if the statement is not in a list and `stmts` does not have exactly one statement.
+func replaceStmt(cursor *Cursor, stmts []ast.Stmt) error {
+	if len(stmts) == 0 {
+		return errors.WithStack(removeStmt(cursor))
+	}
+
+	if !cursor.InList() && len(stmts) != 1 {
+		return errors.Errorf("can't replace %s with %d statements: it's not in a list of statements", cursor.description(), len(stmts))
+	}
+
+	if stmts[0] != cursor.node {
+		if err := cursor.Replace(stmts[0]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for i := len(stmts) - 1; i > 0; i-- {
+		if err := cursor.InsertAfter(stmts[i]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// Removes the statement `cursor` points to from the list it's in or from
+// the field it's in when the field is optional, like the init statement of an if statement.
+//
+// Returns error if the statement can't be removed, like the assignment in case v = <-ch:.
+func removeStmt(cursor *Cursor) error {
+	if cursor.InList() {
+		return errors.WithStack(cursor.Delete())
+	}
+
+	if cursor.name != "Init" && cursor.name != "Post" {
+		return errors.Errorf("can't remove %s: the statement is required", cursor.description())
+	}
+
+	field, err := cursor.field()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	field.Set(reflect.Zero(field.Type()))
+
+	return nil
+}
+
+// Returns the cursor that points to `node` or nil if `node` is not in the file.
+func (code *SourceFile) cursorOf(node ast.Node) *Cursor {
+	var out *Cursor
+
+	code.Walk(func(cursor *Cursor) bool {
+		if cursor.Node() == node {
+			out = cursor
+		}
+		return out == nil
+	})
+
+	return out
+}
+
+// Parses `source` as statements inside a function, without positions.
+func parseStmts(source string) ([]ast.Stmt, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", fmt.Sprintf("package p; func _() {\n%s\n}", source), 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid statements %s", source)
+	}
+
+	return cloneNode(file.Decls[0].(*ast.FuncDecl).Body).(*ast.BlockStmt).List, nil
+}
+
+// Parses `source` as top level declarations, without positions.
+func parseDecls(source string) ([]ast.Decl, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+source, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid declarations %s", source)
+	}
+
+	return cloneNode(file).(*ast.File).Decls, nil
+}
@@ -0,0 +1,298 @@
+package codemod_test
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the binding that has the target `name`.
+func findBinding(t *testing.T, file *codemod.SourceFile, name string) *codemod.Binding {
+	t.Helper()
+
+	for _, binding := range file.Bindings() {
+		binding := binding
+
+		for _, target := range binding.Names() {
+			if target == name {
+				return &binding
+			}
+		}
+	}
+
+	t.Fatalf("binding %s not found", name)
+
+	return nil
+}
+
+func Test_SourceFile_Bindings(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+const (
+	A = iota
+	B
+)
+
+var timeout, retries int
+
+func main() {
+	x := 1
+	x += 2
+	if y := x; y > 0 {
+		var z = y
+		_ = z
+	}
+}
+`)})
+	assert.NoError(t, err)
+
+	bindings := file.Bindings()
+
+	names := make([][]string, 0)
+	for _, binding := range bindings {
+		names = append(names, binding.Names())
+	}
+
+	assert.Equal(t, [][]string{{"A"}, {"B"}, {"timeout", "retries"}, {"x"}, {"x"}, {"y"}, {"z"}, {"_"}}, names)
+
+	assert.Equal(t, token.CONST, bindings[0].Tok())
+	assert.True(t, bindings[0].IsPackageLevel())
+	assert.Empty(t, bindings[1].Values())
+	assert.Equal(t, "int", codemod.SourceCode(bindings[2].Type()))
+	assert.Equal(t, token.DEFINE, bindings[3].Tok())
+	assert.Equal(t, token.ADD_ASSIGN, bindings[4].Tok())
+	assert.False(t, bindings[6].IsPackageLevel())
+	assert.Equal(t, token.VAR, bindings[6].Tok())
+}
+
+func Test_Binding_Split(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+var a, b = 1, 2
+
+const (
+	C, D = iota, iota
+)
+
+func main() {
+	x, y := 1, 2
+	x, z := 3, 4
+	x, y = y, x
+	v, err := f()
+}
+`)})
+	assert.NoError(t, err)
+
+	bindings, err := findBinding(t, file, "a").Split()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bindings))
+	assert.Equal(t, []string{"b"}, bindings[1].Names())
+
+	_, err = findBinding(t, file, "C").Split()
+	assert.Error(t, err)
+
+	_, err = findBinding(t, file, "y").Split()
+	assert.NoError(t, err)
+
+	_, err = findBinding(t, file, "z").Split()
+	assert.NoError(t, err)
+
+	for _, binding := range file.Bindings() {
+		if binding.Tok() == token.ASSIGN && len(binding.Targets()) == 2 {
+			_, err := binding.Split()
+			assert.Error(t, err)
+		}
+	}
+
+	_, err = findBinding(t, file, "err").Split()
+	assert.Error(t, err)
+
+	expected := `package main
+
+var a = 1
+var b = 2
+
+const (
+	C, D = iota, iota
+)
+
+func main() {
+	x := 1
+	y := 2
+	x = 3
+	z := 4
+	x, y = y, x
+	v, err := f()
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
+
+func Test_Binding_MergeNext(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+var (
+	host = "localhost"
+	port = 8080
+	url  = host
+)
+
+func main() {
+	x := 1
+	y := 2
+	z := x
+	w += 1
+}
+`)})
+	assert.NoError(t, err)
+
+	host := findBinding(t, file, "host")
+	assert.NoError(t, host.MergeNext())
+	assert.Error(t, host.MergeNext())
+
+	x := findBinding(t, file, "x")
+	assert.NoError(t, x.MergeNext())
+	assert.Error(t, x.MergeNext())
+
+	assert.Error(t, findBinding(t, file, "z").MergeNext())
+	assert.Error(t, findBinding(t, file, "w").MergeNext())
+
+	expected := `package main
+
+var (
+	host, port = "localhost", 8080
+	url        = host
+)
+
+func main() {
+	x, y := 1, 2
+	z := x
+	w += 1
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
+
+func Test_Binding_Remove(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+const (
+	StatusActive Status = iota
+	StatusDeleted
+)
+
+var debug = false
+
+func main() {
+	if err := run(); err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 10; i += 1 {
+		a, b := 1, 2
+		println(b)
+	}
+
+	select {
+	case v = <-ch:
+	}
+}
+`)})
+	assert.NoError(t, err)
+
+	assert.NoError(t, findBinding(t, file, "StatusActive").Remove())
+	assert.NoError(t, findBinding(t, file, "debug").Remove())
+	assert.NoError(t, findBinding(t, file, "err").Remove())
+
+	for _, binding := range file.Bindings() {
+		if binding.Tok() == token.ADD_ASSIGN {
+			assert.NoError(t, binding.Remove())
+		}
+	}
+
+	assert.Error(t, findBinding(t, file, "v").Remove())
+
+	ab := findBinding(t, file, "a")
+	assert.Error(t, ab.RemoveName("c"))
+	assert.NoError(t, ab.RemoveName("a"))
+
+	expected := `package main
+
+const (
+	StatusDeleted Status = iota
+)
+
+func main() {
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 10; {
+		b := 2
+		println(b)
+	}
+
+	select {
+	case v = <-ch:
+	}
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
+
+func Test_Binding_Replace(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+var timeout = 10
+
+const (
+	retries = 3
+	backoff = 2
+)
+
+func main() {
+	var x int
+	y := 1
+}
+`)})
+	assert.NoError(t, err)
+
+	assert.NoError(t, findBinding(t, file, "timeout").Replace("const timeout = 30"))
+	assert.NoError(t, findBinding(t, file, "retries").Replace("const retries, delay = 5, 1"))
+	assert.Error(t, findBinding(t, file, "backoff").Replace("var backoff = 2"))
+	assert.NoError(t, findBinding(t, file, "x").Replace("x := 0"))
+	assert.NoError(t, findBinding(t, file, "y").Replace("y, z := 1, 2"))
+	assert.Error(t, findBinding(t, file, "z").Replace("y :="))
+
+	expected := `package main
+
+const timeout = 30
+
+const (
+	retries, delay = 5, 1
+	backoff        = 2
+)
+
+func main() {
+	x := 0
+	y, z := 1, 2
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
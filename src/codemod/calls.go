@@ -0,0 +1,159 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// Returns the import path of the package the called function is declared in,
+// like github.com/pkg/errors for pkgerrors.Wrapf(...) when the file imports
+// github.com/pkg/errors as pkgerrors.
+//
+// Calls to functions of dot imported packages, like Wrapf(...),
+// and calls to methods of package-level variables of imported packages,
+// like http.DefaultClient.Do(...), are resolved as well.
+//
+// Returns false if the function is not declared in an imported package,
+// like builtin functions, functions of the package the file belongs to
+// and methods of local variables.
+//
+// Type information is used when the file has been type checked. Otherwise names
+// that are not declared in the file are assumed to come from the dot imported package,
+// if there's only one, even if they are declared in another file of the package.
+func (call *FunctionCall) Package() (string, bool) {
+	importPath, _, ok := call.callee()
+
+	return importPath, ok
+}
+
+// Returns true if the call calls `funcName` from the package at `importPath`.
+//
+// Methods of package-level variables are called Variable.Method:
+//
+//	call.IsCallTo("net/http", "DefaultClient.Do")
+//
+// See Package.
+func (call *FunctionCall) IsCallTo(importPath, funcName string) bool {
+	calleePath, calleeName, ok := call.callee()
+
+	return ok && calleePath == importPath && calleeName == funcName
+}
+
+// Returns the import path of the package the called function is declared in
+// and the name of the function in that package.
+func (call *FunctionCall) callee() (string, string, bool) {
+	if call.file == nil {
+		return "", "", false
+	}
+
+	root, selectors := selectorChain(calledFunction(call.Node.Fun))
+	if root == nil {
+		return "", "", false
+	}
+
+	if len(selectors) > 0 {
+		if importPath, ok := call.file.importedPackage(root); ok {
+			return importPath, strings.Join(selectors, "."), true
+		}
+	}
+
+	if importPath, ok := call.file.dotImportedPackage(root); ok {
+		return importPath, strings.Join(append([]string{root.Name}, selectors...), "."), true
+	}
+
+	return "", "", false
+}
+
+// Returns the expression that refers to the function being called,
+// without parentheses and type arguments.
+func calledFunction(fun ast.Expr) ast.Expr {
+	for {
+		switch expr := fun.(type) {
+		case *ast.ParenExpr:
+			fun = expr.X
+		case *ast.IndexExpr:
+			fun = expr.X
+		case *ast.IndexListExpr:
+			fun = expr.X
+		default:
+			return fun
+		}
+	}
+}
+
+// Returns the identifier at the start of a chain of selectors like a.b.c,
+// and the selectors after it, b and c.
+//
+// Returns nil if the chain does not start with an identifier.
+func selectorChain(expr ast.Expr) (*ast.Ident, []string) {
+	selectors := make([]string, 0)
+
+	for {
+		switch node := expr.(type) {
+		case *ast.Ident:
+			return node, selectors
+
+		case *ast.SelectorExpr:
+			selectors = append([]string{node.Sel.Name}, selectors...)
+			expr = node.X
+
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// Returns the import path of the package `ident` refers to, like fmt in fmt.Println.
+func (code *SourceFile) importedPackage(ident *ast.Ident) (string, bool) {
+	if code.pkg != nil {
+		// Nodes added after type checking don't have type information.
+		if obj, ok := code.pkg.info.Uses[ident]; ok {
+			pkgName, ok := obj.(*types.PkgName)
+			if !ok {
+				return "", false
+			}
+
+			return pkgName.Imported().Path(), true
+		}
+	}
+
+	// Identifiers declared in the file are resolved by the parser.
+	if ident.Obj != nil {
+		return "", false
+	}
+
+	return code.Imports().pathOf(ident.Name)
+}
+
+// Returns the import path of the dot imported package
+// that declares what `ident` refers to.
+func (code *SourceFile) dotImportedPackage(ident *ast.Ident) (string, bool) {
+	if code.pkg != nil {
+		if obj, ok := code.pkg.info.Uses[ident]; ok {
+			if obj.Pkg() == nil || obj.Pkg() == code.pkg.types || obj.Parent() != obj.Pkg().Scope() {
+				return "", false
+			}
+
+			return obj.Pkg().Path(), true
+		}
+	}
+
+	if ident.Obj != nil || types.Universe.Lookup(ident.Name) != nil || topLevelNames(code.file)[ident.Name] {
+		return "", false
+	}
+
+	dotImports := make([]string, 0)
+
+	for _, i := range code.Imports().List() {
+		if i.Name == "." {
+			dotImports = append(dotImports, i.Path)
+		}
+	}
+
+	if len(dotImports) != 1 {
+		return "", false
+	}
+
+	return dotImports[0], true
+}
@@ -0,0 +1,138 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the calls in `file` by the source code of the called function.
+func callsByName(file *codemod.SourceFile) map[string]codemod.FunctionCall {
+	out := make(map[string]codemod.FunctionCall)
+
+	for _, calls := range file.FunctionCalls() {
+		for _, call := range calls {
+			out[call.FunctionName()] = call
+		}
+	}
+
+	return out
+}
+
+func Test_FunctionCall_Package(t *testing.T) {
+	t.Parallel()
+
+	sourceCode := `package main
+
+import (
+	"errors"
+	"net/http"
+
+	. "github.com/stretchr/testify/assert"
+	pkgerrors "github.com/pkg/errors"
+)
+
+func wrap(err error) error {
+	return pkgerrors.Wrapf(err, "wrapping")
+}
+
+func check(t TestingT) {
+	Equal(t, 1, 1)
+	println(errors.New("error"))
+}
+
+func shadowed(errors []error) {
+	errors.Wrapf()
+}
+
+func main() {
+	http.DefaultClient.Do(nil)
+	(pkgerrors.WithStack)(nil)
+	wrap(nil)
+}
+`
+
+	t.Run("without type information", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(sourceCode)})
+		assert.NoError(t, err)
+
+		calls := callsByName(file)
+
+		tests := []struct {
+			call       string
+			importPath string
+			funcName   string
+		}{
+			{call: "pkgerrors.Wrapf", importPath: "github.com/pkg/errors", funcName: "Wrapf"},
+			{call: "Equal", importPath: "github.com/stretchr/testify/assert", funcName: "Equal"},
+			{call: "errors.New", importPath: "errors", funcName: "New"},
+			{call: "http.DefaultClient.Do", importPath: "net/http", funcName: "DefaultClient.Do"},
+			{call: "(pkgerrors.WithStack)", importPath: "github.com/pkg/errors", funcName: "WithStack"},
+		}
+
+		for _, tt := range tests {
+			call := calls[tt.call]
+
+			importPath, ok := call.Package()
+			assert.True(t, ok, tt.call)
+			assert.Equal(t, tt.importPath, importPath, tt.call)
+			assert.True(t, call.IsCallTo(tt.importPath, tt.funcName), tt.call)
+		}
+
+		for _, name := range []string{"errors.Wrapf", "wrap", "println"} {
+			call := calls[name]
+
+			_, ok := call.Package()
+			assert.False(t, ok, name)
+		}
+
+		errorsWrapf := calls["errors.Wrapf"]
+		assert.False(t, errorsWrapf.IsCallTo("github.com/pkg/errors", "Wrapf"))
+
+		pkgerrorsWrapf := calls["pkgerrors.Wrapf"]
+		assert.False(t, pkgerrorsWrapf.IsCallTo("errors", "Wrapf"))
+
+		matches := file.Find(codemod.IsCallTo("github.com/pkg/errors", "Wrapf"))
+		assert.Equal(t, 1, len(matches))
+		assert.Equal(t, `pkgerrors.Wrapf(err, "wrapping")`, codemod.SourceCode(matches[0].Node))
+	})
+
+	t.Run("with type information", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"main.go": `package main
+
+import (
+	. "strings"
+	str "strings"
+	"net/http"
+)
+
+var DefaultClient = &http.Client{}
+
+func main() {
+	_ = str.ToUpper("a")
+	_ = ToLower("a")
+	http.DefaultClient.Do(nil)
+	DefaultClient.Do(nil)
+}
+`,
+		}))
+		assert.NoError(t, err)
+
+		calls := callsByName(findFile(t, files, "main.go"))
+
+		toUpper := calls["str.ToUpper"]
+		assert.True(t, toUpper.IsCallTo("strings", "ToUpper"))
+
+		toLower := calls["ToLower"]
+		assert.True(t, toLower.IsCallTo("strings", "ToLower"))
+
+		do := calls["http.DefaultClient.Do"]
+		assert.True(t, do.IsCallTo("net/http", "DefaultClient.Do"))
+
+		localDo := calls["DefaultClient.Do"]
+		_, ok := localDo.Package()
+		assert.False(t, ok)
+	})
+}
@@ -14,13 +14,16 @@ import (
 	"github.com/pkg/errors"
 )
 
-type Project struct {
-}
-
 type SourceFile struct {
 	fileSet  *token.FileSet
 	file     *ast.File
 	FilePath string
+	// Type information, only available when the file is type checked.
+	pkg *packageInfo
+	// Comments attached to the nodes they are about.
+	decorations *decorations
+	// The source code the file was parsed from.
+	source []byte
 }
 
 type NewInput struct {
@@ -39,9 +42,11 @@ func New(input NewInput) (*SourceFile, error) {
 	}
 
 	sourceFile := &SourceFile{
-		fileSet:  fileSet,
-		file:     ast,
-		FilePath: input.FilePath,
+		fileSet:     fileSet,
+		file:        ast,
+		FilePath:    input.FilePath,
+		decorations: decorate(fileSet, ast),
+		source:      input.SourceCode,
 	}
 
 	return sourceFile, nil
@@ -54,58 +59,39 @@ func NormalizeString(s string) string {
 	return s
 }
 
-func insertAfter(node NodeWithParent, newNode ast.Node) {
-	block := node.Parent.FindUpstreamNode(&ast.BlockStmt{}).Node.(*ast.BlockStmt)
-
-	newList := make([]ast.Stmt, 0, len(block.List))
-
-	for _, stmt := range block.List {
-		newList = append(newList, stmt)
-
-		if stmt == node.Node {
-			newList = append(newList, newNode.(ast.Stmt))
-		}
+// Inserts `newNode` after the statement that contains the node `cursor` points to.
+func insertAfter(cursor *Cursor, newNode ast.Node) {
+	stmt := cursor.statement()
+	if stmt == nil {
+		panic(errors.New("node is not inside a list of statements"))
 	}
 
-	block.List = newList
+	if err := stmt.InsertAfter(newNode); err != nil {
+		panic(err)
+	}
 }
 
-func insertBefore(node NodeWithParent, newNode ast.Node) {
-	block := node.Parent.FindUpstreamNode(&ast.BlockStmt{}).Node.(*ast.BlockStmt)
-
-	newList := make([]ast.Stmt, 0, len(block.List))
-
-	for _, stmt := range block.List {
-		if stmt == node.Node {
-			newList = append(newList, newNode.(ast.Stmt))
-		}
-
-		newList = append(newList, stmt)
+// Inserts `newNode` before the statement that contains the node `cursor` points to.
+func insertBefore(cursor *Cursor, newNode ast.Node) {
+	stmt := cursor.statement()
+	if stmt == nil {
+		panic(errors.New("node is not inside a list of statements"))
 	}
 
-	block.List = newList
+	if err := stmt.InsertBefore(newNode); err != nil {
+		panic(err)
+	}
 }
 
-func remove(node NodeWithParent) {
-	blockStmt := node.Parent.FindUpstreamNode(&ast.BlockStmt{})
-
-	if blockStmt == nil {
+// Removes the statement that contains the node `cursor` points to.
+func remove(cursor *Cursor) {
+	stmt := cursor.statement()
+	if stmt == nil {
 		return
 	}
 
-	block := blockStmt.Node.(*ast.BlockStmt)
-
-	list := make([]ast.Stmt, 0)
-
-	for _, stmt := range block.List {
-		if stmt == node.Node {
-			continue
-		}
-
-		list = append(list, stmt)
-	}
-
-	block.List = list
+	// Delete only fails if the statement has already been removed.
+	_ = stmt.Delete()
 }
 
 func SourceCode(node ast.Node) string {
@@ -119,40 +105,6 @@ func SourceCode(node ast.Node) string {
 	return buffer.String()
 }
 
-var x int = 1
-
-func zeroPositionInformation(value reflect.Value) {
-	if !value.IsValid() || value.IsZero() {
-		return
-	}
-
-	// TODO: something is causing infinite recursion in this function
-	// this is work around so we can test something that depends on this
-	if x > 1000 {
-		return
-	}
-	x++
-
-	if value.Type().Name() == "Pos" {
-		value.Set(reflect.Zero(value.Type()))
-	}
-
-	switch value.Kind() {
-	case reflect.Ptr, reflect.Interface:
-		if !value.IsNil() {
-			zeroPositionInformation(value.Elem())
-		}
-	case reflect.Struct:
-		for i := 0; i < value.NumField(); i++ {
-			zeroPositionInformation(value.Field(i))
-		}
-	case reflect.Array, reflect.Slice:
-		for i := 0; i < value.Len(); i++ {
-			zeroPositionInformation(value.Index(i))
-		}
-	}
-}
-
 func Ast(sourceCode string) ast.Node {
 	packageName := fmt.Sprintf("package_name_%d\n", time.Now().Nanosecond())
 	functionName := fmt.Sprintf("function_name_%d", time.Now().Nanosecond())
@@ -180,9 +132,7 @@ func Ast(sourceCode string) ast.Node {
 		node = body.List[0]
 	}
 
-	zeroPositionInformation(reflect.ValueOf(node))
-
-	return node
+	return cloneNode(node)
 }
 
 func Unquote(s string) string {
@@ -193,36 +143,28 @@ func Quote(s string) string {
 	return fmt.Sprintf(`"%s"`, s)
 }
 
+// Returns the source code of the file.
+//
+// Comments are kept next to the nodes they are about even if
+// nodes were moved, replaced or inserted.
 func (code *SourceFile) SourceCode() []byte {
-	buffer := bytes.Buffer{}
-
-	err := format.Node(&buffer, code.fileSet, code.file)
+	sourceCode, err := code.printWithComments()
 	if err != nil {
 		panic(errors.WithStack(err))
 	}
 
-	return buffer.Bytes()
+	return sourceCode
 }
 
-func (code *SourceFile) TraverseAst(f func(NodeWithParent)) {
-	parent := NodeWithParent{
-		Node: code.file,
-	}
-
-	ast.Inspect(
-		code.file,
-		func(node ast.Node) bool {
-			p := parent
-			parent = NodeWithParent{
-				Parent: &p,
-				Node:   node,
-			}
-
-			f(parent)
+// Calls `f` with every node in the source file that is matched by every matcher in `matchers`.
+func (code *SourceFile) TraverseAst(f func(NodeWithParent), matchers ...Matcher) {
+	code.Walk(func(cursor *Cursor) bool {
+		if matchesAll(cursor, matchers) {
+			f(cursor.nodeWithParent())
+		}
 
-			return true
-		},
-	)
+		return true
+	})
 }
 
 type Package struct {
@@ -241,127 +183,73 @@ func (code *SourceFile) Package() Package {
 	return Package{Identifier: code.file.Name}
 }
 
-type Imports struct {
-	specs *[]ast.Spec
-}
-
-func (imports *Imports) Paths() []string {
-	if imports.specs == nil {
-		return make([]string, 0)
-	}
-
-	out := make([]string, 0, len(*imports.specs))
-
-	for _, spec := range *imports.specs {
-		importStatement := spec.(*ast.ImportSpec)
-
-		out = append(out, Unquote(importStatement.Path.Value))
-	}
-
-	return out
-}
-
-func (imports *Imports) Contains(importPath string) bool {
-	for _, path := range imports.Paths() {
-		if path == importPath {
-			return true
-		}
-	}
-
-	return false
-}
-
-func (imports *Imports) Add(importPath string) {
-	for _, path := range imports.Paths() {
-		if path == importPath {
-			return
-		}
-	}
-
-	*imports.specs = append(*imports.specs, &ast.ImportSpec{
-		Path: &ast.BasicLit{
-			Kind:  token.STRING,
-			Value: Quote(importPath),
-		},
-	})
-}
-
-func (imports *Imports) Remove(importPath string) {
-	specs := make([]ast.Spec, 0)
-
-	for _, spec := range *imports.specs {
-		if Unquote(spec.(*ast.ImportSpec).Path.Value) == importPath {
-			continue
-		}
-
-		specs = append(specs, spec)
-	}
-
-	*imports.specs = specs
-}
-
-func (code *SourceFile) Imports() *Imports {
-	var specs *[]ast.Spec
-
-	ast.Inspect(code.file, func(node ast.Node) bool {
-		decl, ok := node.(*ast.GenDecl)
-		if ok && decl.Tok == token.IMPORT {
-			specs = &decl.Specs
-			return false
-		}
-		return true
-	})
-
-	return &Imports{specs: specs}
-}
-
 type FunctionCall struct {
 	Parent NodeWithParent
 	Node   *ast.CallExpr
+	file   *SourceFile
+	cursor *Cursor
 }
 
+// Inserts `node` after the statement that contains the call.
 func (call *FunctionCall) InsertAfter(node ast.Node) {
-	insertAfter(NodeWithParent{Parent: &call.Parent, Node: call.Parent.Node}, node)
+	insertAfter(call.cursor, node)
 }
 
+// Inserts `node` before the statement that contains the call.
 func (call *FunctionCall) InsertBefore(node ast.Node) {
-	insertBefore(NodeWithParent{Parent: &call.Parent, Node: call.Parent.Node}, node)
+	insertBefore(call.cursor, node)
 }
 
+// Removes the statement that contains the call.
 func (call *FunctionCall) Remove() {
-	remove(NodeWithParent{Parent: &call.Parent, Node: call.Parent.Node})
+	remove(call.cursor)
+}
+
+// Returns the cursor that points to the call.
+func (call *FunctionCall) Cursor() *Cursor {
+	return call.cursor
+}
+
+// Returns the innermost scope the call is in.
+func (call *FunctionCall) Scope() Scope {
+	return scopeOf(call.cursor)
+}
+
+// Returns the file, line and column where the call starts.
+func (call *FunctionCall) Position() token.Position {
+	return call.file.nodePosition(call.Node)
 }
 
 func (call *FunctionCall) FunctionName() string {
 	return SourceCode(call.Node.Fun)
 }
 
-func (code *SourceFile) FunctionCalls() map[Scope][]FunctionCall {
+// Returns the function calls grouped by the innermost scope they are in.
+//
+// See FunctionCallsInOrder to visit them in the order they appear in.
+func (code *SourceFile) FunctionCalls(matchers ...Matcher) map[Scope][]FunctionCall {
 	out := make(map[Scope][]FunctionCall)
 
-	var parent NodeWithParent
-	var scope Scope
-
-	ast.Inspect(
-		code.file,
-		func(node ast.Node) bool {
-			switch value := node.(type) {
-			case *ast.FuncDecl:
-				scope = Scope{fun: value}
+	for _, call := range code.FunctionCallsInOrder(matchers...) {
+		scope := call.Scope()
+		out[scope] = append(out[scope], call)
+	}
 
-			case *ast.CallExpr:
-				out[scope] = append(out[scope], FunctionCall{Node: value, Parent: parent})
-			}
+	return out
+}
 
-			p := parent
-			parent = NodeWithParent{
-				Parent: &p,
-				Node:   node,
-			}
+// Returns the function calls in the order they appear in the file.
+func (code *SourceFile) FunctionCallsInOrder(matchers ...Matcher) []FunctionCall {
+	out := make([]FunctionCall, 0)
 
-			return true
-		},
-	)
+	for _, match := range code.find("CallExpr", matchers) {
+		out = append(out, FunctionCall{
+			Node:   match.Node.(*ast.CallExpr),
+			Parent: match.Cursor.parent.nodeWithParent(),
+			file:   code,
+			cursor: match.Cursor,
+		})
+	}
 
 	return out
 }
@@ -369,6 +257,7 @@ func (code *SourceFile) FunctionCalls() map[Scope][]FunctionCall {
 type TypeDeclaration struct {
 	Parent NodeWithParent
 	Node   *ast.TypeSpec
+	file   *SourceFile
 }
 
 func (typeDecl *TypeDeclaration) IsInterface() bool {
@@ -390,7 +279,14 @@ type Method struct {
 }
 
 func (method *Method) Params() []*ast.Field {
-	return method.Node.(*ast.Field).Type.(*ast.FuncType).Params.List
+	switch node := method.Node.(type) {
+	case *ast.FuncDecl:
+		return node.Type.Params.List
+	case *ast.Field:
+		return node.Type.(*ast.FuncType).Params.List
+	default:
+		panic(fmt.Sprintf("node is not a method: %+v", method.Node))
+	}
 }
 
 func (method *Method) Name() string {
@@ -416,59 +312,39 @@ func (typeDecl *TypeDeclaration) Methods() []Method {
 	}
 
 	if typeDecl.IsStruct() || typeDecl.IsTypeAlias() {
-		file := typeDecl.Parent.FindUpstreamNode(&ast.File{})
-
-		for _, decl := range file.Node.(*ast.File).Decls {
-			if funDecl, ok := decl.(*ast.FuncDecl); ok {
-				if funDecl.Recv == nil {
-					continue
-				}
-
-				for _, receiver := range funDecl.Recv.List {
-					if pointerReceiver, ok := receiver.Type.(*ast.StarExpr); ok {
-						if pointerReceiver.X.(*ast.Ident).Name == typeDecl.Node.Name.Name {
-							out = append(out, Method{Node: funDecl})
-						}
-					}
-
-					if byCopyReceiver, ok := receiver.Type.(*ast.Ident); ok {
-						if byCopyReceiver.Name == typeDecl.Node.Name.Name {
-							out = append(out, Method{Node: funDecl})
-						}
-					}
-				}
-			}
+		for _, decl := range typeDecl.methodDecls() {
+			out = append(out, Method{Node: decl})
 		}
 	}
 
 	return out
 }
 
-func (code *SourceFile) TypeDeclarations() []TypeDeclaration {
-	out := make([]TypeDeclaration, 0)
+// Returns the declarations of the methods of the type in the file, including methods
+// of generic types like func (m *Map[K, V]) Get(key K) V.
+func (typeDecl *TypeDeclaration) methodDecls() []*ast.FuncDecl {
+	out := make([]*ast.FuncDecl, 0)
 
-	parent := NodeWithParent{
-		Parent: nil,
-		Node:   code.file,
+	file := typeDecl.Parent.FindUpstreamNode(&ast.File{})
+	if file == nil {
+		return out
 	}
 
-	ast.Inspect(
-		code.file,
-		func(node ast.Node) bool {
-			switch value := node.(type) {
-			case *ast.TypeSpec:
-				out = append(out, TypeDeclaration{Node: value, Parent: parent})
-			}
+	for _, decl := range file.Node.(*ast.File).Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && receiverTypeName(funcDecl) == typeDecl.Node.Name.Name {
+			out = append(out, funcDecl)
+		}
+	}
 
-			p := parent
-			parent = NodeWithParent{
-				Parent: &p,
-				Node:   node,
-			}
+	return out
+}
 
-			return true
-		},
-	)
+func (code *SourceFile) TypeDeclarations(matchers ...Matcher) []TypeDeclaration {
+	out := make([]TypeDeclaration, 0)
+
+	for _, match := range code.find("TypeSpec", matchers) {
+		out = append(out, TypeDeclaration{Node: match.Node.(*ast.TypeSpec), Parent: match.Cursor.parent.nodeWithParent(), file: code})
+	}
 
 	return out
 }
@@ -476,162 +352,81 @@ func (code *SourceFile) TypeDeclarations() []TypeDeclaration {
 type Function struct {
 	Parent NodeWithParent
 	Node   *ast.FuncDecl
+	file   *SourceFile
 }
 
 func (function *Function) Params() []*ast.Field {
 	return function.Node.Type.Params.List
 }
 
-func (code *SourceFile) Functions() []Function {
+func (code *SourceFile) Functions(matchers ...Matcher) []Function {
 	out := make([]Function, 0)
 
-	var parent NodeWithParent
-
-	ast.Inspect(
-		code.file,
-		func(node ast.Node) bool {
-			switch value := node.(type) {
-			case *ast.FuncDecl:
-				out = append(out, Function{Node: value, Parent: parent})
-			}
-
-			p := parent
-			parent = NodeWithParent{
-				Parent: &p,
-				Node:   node,
-			}
-
-			return true
-		},
-	)
-
-	return out
-}
-
-type Map struct {
-	Expr NodeWithParent
-}
-
-func (m *Map) Has(key string) bool {
-	composite := m.Expr.Node.(*ast.CompositeLit)
-
-	for _, element := range composite.Elts {
-		if Unquote(element.(*ast.KeyValueExpr).Key.(*ast.BasicLit).Value) == key {
-			return true
-		}
-	}
-
-	return false
-}
-
-func (m *Map) RenameKey(currentKeyValue string, newKeyValue string) {
-	composite := m.Expr.Node.(*ast.CompositeLit)
-
-	for _, element := range composite.Elts {
-		key := element.(*ast.KeyValueExpr).Key.(*ast.BasicLit)
-
-		if Unquote(key.Value) != currentKeyValue {
-			continue
-		}
-
-		key.Value = Quote(newKeyValue)
-	}
-}
-
-func (code *SourceFile) FindMapLiteral(mapType string) (*Scope, *Map) {
-	for scope, literals := range code.FindMapLiterals(mapType) {
-		return &scope, &literals[0]
-	}
-
-	return nil, nil
-}
-
-func (code *SourceFile) FindMapLiterals(mapType string) map[Scope][]Map {
-	out := make(map[Scope][]Map)
-
-	parent := NodeWithParent{
-		Node: code.file,
+	for _, match := range code.find("FuncDecl", matchers) {
+		out = append(out, Function{Node: match.Node.(*ast.FuncDecl), Parent: match.Cursor.parent.nodeWithParent(), file: code})
 	}
 
-	var scope Scope
-
-	mapTypeExpr, err := parser.ParseExpr(mapType)
-	if err != nil {
-		panic(errors.Wrapf(err, "invalid map type: %s", mapType))
-	}
-
-	ast.Inspect(
-		code.file,
-		func(node ast.Node) bool {
-			p := parent
-			parent = NodeWithParent{
-				Parent: &p,
-				Node:   node,
-			}
-
-			switch value := node.(type) {
-			case *ast.FuncDecl:
-				scope = Scope{fun: value}
-
-			case *ast.CompositeLit:
-				typ := value.Type.(*ast.MapType)
-
-				if typ.Key.(*ast.Ident).Name == mapTypeExpr.(*ast.MapType).Key.(*ast.Ident).Name &&
-					typ.Value.(*ast.Ident).Name == mapTypeExpr.(*ast.MapType).Value.(*ast.Ident).Name {
-					p := parent
-					out[scope] = append(out[scope], Map{Expr: NodeWithParent{Parent: &p, Node: node}})
-				}
-			}
-
-			return true
-		},
-	)
-
 	return out
 }
 
 type SwitchStmt struct {
 	Parent NodeWithParent
 	Node   *ast.SwitchStmt
+	cursor *Cursor
 }
 
 func (stmt *SwitchStmt) InsertAfter(node ast.Node) {
-	insertAfter(NodeWithParent{Parent: &stmt.Parent, Node: stmt.Node}, node)
+	insertAfter(stmt.cursor, node)
 }
 
 func (stmt *SwitchStmt) InsertBefore(node ast.Node) {
-	insertBefore(NodeWithParent{Parent: &stmt.Parent, Node: stmt.Node}, node)
+	insertBefore(stmt.cursor, node)
 }
 
 func (stmt *SwitchStmt) Remove() {
-	remove(NodeWithParent{Parent: &stmt.Parent, Node: stmt.Node})
+	remove(stmt.cursor)
+}
+
+// Returns the cursor that points to the statement.
+func (stmt *SwitchStmt) Cursor() *Cursor {
+	return stmt.cursor
+}
+
+// Returns the innermost scope the statement is in.
+func (stmt *SwitchStmt) Scope() Scope {
+	return scopeOf(stmt.cursor)
 }
 
-func (code *SourceFile) SwitchStatements() map[Scope][]SwitchStmt {
+// Returns the file, line and column where the statement starts.
+func (stmt *SwitchStmt) Position() token.Position {
+	return stmt.cursor.file.nodePosition(stmt.Node)
+}
+
+// Returns the switch statements grouped by the innermost scope they are in.
+//
+// See SwitchStatementsInOrder to visit them in the order they appear in.
+func (code *SourceFile) SwitchStatements(matchers ...Matcher) map[Scope][]SwitchStmt {
 	out := make(map[Scope][]SwitchStmt)
-	var parent NodeWithParent
-	var scope Scope
-
-	ast.Inspect(
-		code.file,
-		func(node ast.Node) bool {
-			switch value := node.(type) {
-			case *ast.FuncDecl:
-				scope = Scope{fun: value}
-
-			case *ast.SwitchStmt:
-				out[scope] = append(out[scope], SwitchStmt{Parent: parent, Node: value})
-			}
 
-			p := parent
-			parent = NodeWithParent{
-				Parent: &p,
-				Node:   node,
-			}
+	for _, stmt := range code.SwitchStatementsInOrder(matchers...) {
+		scope := stmt.Scope()
+		out[scope] = append(out[scope], stmt)
+	}
+
+	return out
+}
 
-			return true
-		},
-	)
+// Returns the switch statements in the order they appear in the file.
+func (code *SourceFile) SwitchStatementsInOrder(matchers ...Matcher) []SwitchStmt {
+	out := make([]SwitchStmt, 0)
+
+	for _, match := range code.find("SwitchStmt", matchers) {
+		out = append(out, SwitchStmt{
+			Parent: match.Cursor.parent.nodeWithParent(),
+			Node:   match.Node.(*ast.SwitchStmt),
+			cursor: match.Cursor,
+		})
+	}
 
 	return out
 }
@@ -639,61 +434,98 @@ func (code *SourceFile) SwitchStatements() map[Scope][]SwitchStmt {
 type IfStmt struct {
 	Parent NodeWithParent
 	Node   *ast.IfStmt
+	cursor *Cursor
 }
 
 func (stmt *IfStmt) InsertAfter(node ast.Node) {
-	insertAfter(NodeWithParent{Parent: &stmt.Parent, Node: stmt.Node}, node)
+	insertAfter(stmt.cursor, node)
 }
 
 func (stmt *IfStmt) InsertBefore(node ast.Node) {
-	insertBefore(NodeWithParent{Parent: &stmt.Parent, Node: stmt.Node}, node)
+	insertBefore(stmt.cursor, node)
 }
 
 func (stmt *IfStmt) Remove() {
-	remove(NodeWithParent{Parent: &stmt.Parent, Node: stmt.Node})
+	remove(stmt.cursor)
+}
+
+// Returns the cursor that points to the statement.
+func (stmt *IfStmt) Cursor() *Cursor {
+	return stmt.cursor
+}
+
+// Returns the innermost scope the statement is in.
+func (stmt *IfStmt) Scope() Scope {
+	return scopeOf(stmt.cursor)
+}
+
+// Returns the file, line and column where the statement starts.
+func (stmt *IfStmt) Position() token.Position {
+	return stmt.cursor.file.nodePosition(stmt.Node)
 }
 
+// Replaces the if statement with its init statement and the statements in its body,
+// as if the condition was always true. The else branch is removed.
+//
+// The statements are kept in a block of their own if they declare names
+// that are used next to the if statement.
 func (stmt *IfStmt) RemoveCondition() {
-	blockStmt := stmt.Parent.FindUpstreamNode(&ast.BlockStmt{})
+	cursor := stmt.cursor
+	block := keptBranch(stmt.Node, true)
 
-	if blockStmt == nil {
+	// The if statement is the else branch of another if statement.
+	if !cursor.InList() {
+		_ = cursor.Replace(block)
 		return
 	}
 
-	blockStmt.Node.(*ast.BlockStmt).List = stmt.Node.Body.List
+	field, index, err := cursor.list()
+	if err != nil {
+		return
+	}
+
+	var outer map[string]bool
+	if body := cursor.parent; body.parent != nil && body.name == "Body" {
+		switch fun := body.parent.node.(type) {
+		case *ast.FuncDecl:
+			outer = funcNames(fun.Recv, fun.Type)
+		case *ast.FuncLit:
+			outer = funcNames(nil, fun.Type)
+		}
+	}
+
+	list := cursor.file.inlineBlock(field.Interface().([]ast.Stmt), index, stmt.Node, block, outer)
+
+	field.Set(reflect.ValueOf(list))
 }
 
-func (code *SourceFile) IfStatements() map[Scope][]IfStmt {
+// Returns the if statements grouped by the innermost scope they are in.
+//
+// See IfStatementsInOrder to visit them in the order they appear in.
+func (code *SourceFile) IfStatements(matchers ...Matcher) map[Scope][]IfStmt {
 	out := make(map[Scope][]IfStmt)
-	var parent NodeWithParent
-	var scope Scope
-
-	ast.Inspect(
-		code.file,
-		func(node ast.Node) bool {
-			switch value := node.(type) {
-			case *ast.FuncDecl:
-				scope = Scope{fun: value}
-
-			case *ast.IfStmt:
-				out[scope] = append(out[scope], IfStmt{Parent: parent, Node: value})
-			}
-
-			p := parent
-			parent = NodeWithParent{
-				Parent: &p,
-				Node:   node,
-			}
 
-			return true
-		},
-	)
+	for _, stmt := range code.IfStatementsInOrder(matchers...) {
+		scope := stmt.Scope()
+		out[scope] = append(out[scope], stmt)
+	}
 
 	return out
 }
 
-type Scope struct {
-	fun *ast.FuncDecl
+// Returns the if statements in the order they appear in the file.
+func (code *SourceFile) IfStatementsInOrder(matchers ...Matcher) []IfStmt {
+	out := make([]IfStmt, 0)
+
+	for _, match := range code.find("IfStmt", matchers) {
+		out = append(out, IfStmt{
+			Parent: match.Cursor.parent.nodeWithParent(),
+			Node:   match.Node.(*ast.IfStmt),
+			cursor: match.Cursor,
+		})
+	}
+
+	return out
 }
 
 type NodeWithParent struct {
@@ -717,172 +549,146 @@ func (node *NodeWithParent) FindUpstreamNode(targetNode ast.Node) *NodeWithParen
 	}
 }
 
-func (scope *Scope) FindCall(selector string) *FunctionCall {
-	var call *FunctionCall
-
-	var parent NodeWithParent
-
-	// TODO: remove duplication
-	ast.Inspect(
-		scope.fun,
-		func(node ast.Node) bool {
-			callExpr, ok := node.(*ast.CallExpr)
-			if ok && SourceCode(callExpr.Fun) == selector {
-				call = &FunctionCall{Node: callExpr, Parent: parent}
-				return false
-			}
-
-			if node != nil {
-				p := parent
-				parent = NodeWithParent{
-					Parent: &p,
-					Node:   node,
-				}
-			}
-
-			return true
-		},
-	)
-
-	return call
-}
-
 type Assignment struct {
 	Parent NodeWithParent
 	Node   *ast.AssignStmt
+	file   *SourceFile
+	cursor *Cursor
 }
 
 func (assignment *Assignment) InsertAfter(node ast.Node) {
-	insertAfter(NodeWithParent{Parent: &assignment.Parent, Node: assignment.Node}, node)
+	insertAfter(assignment.cursor, node)
 }
 
 func (assignment *Assignment) InsertBefore(node ast.Node) {
-	insertBefore(NodeWithParent{Parent: &assignment.Parent, Node: assignment.Node}, node)
+	insertBefore(assignment.cursor, node)
 }
 
+// Removes the assignment. Assignments that can't be removed without changing
+// the statement they are in, like the one in case v = <-ch:, are kept.
 func (assignment *Assignment) Remove() {
-	if nodeWithParent := assignment.Parent.FindUpstreamNode(&ast.FuncDecl{}); nodeWithParent != nil {
-		funDecl := nodeWithParent.Node.(*ast.FuncDecl)
-
-		stmts := make([]ast.Stmt, 0)
-		for _, stmt := range funDecl.Body.List {
-			if reflect.DeepEqual(stmt, assignment.Node) {
-				continue
-			}
-
-			stmts = append(stmts, stmt)
-		}
+	_ = removeStmt(assignment.cursor)
+}
 
-		funDecl.Body.List = stmts
-	}
+// Returns the cursor that points to the assignment.
+func (assignment *Assignment) Cursor() *Cursor {
+	return assignment.cursor
 }
 
-type Struct struct {
-	literal *ast.CompositeLit
+// Returns the innermost scope the assignment is in.
+func (assignment *Assignment) Scope() Scope {
+	return scopeOf(assignment.cursor)
 }
 
-type Value struct {
-	Expr ast.Expr
+// Returns the file, line and column where the assignment starts.
+func (assignment *Assignment) Position() token.Position {
+	return assignment.file.nodePosition(assignment.Node)
 }
 
-func (struct_ *Struct) Field(key string) Value {
-	for _, element := range struct_.literal.Elts {
-		expr := element.(*ast.KeyValueExpr)
-		if expr.Key.(*ast.Ident).Name == key {
-			return Value{Expr: expr.Value}
-		}
+// Returns the struct literal assigned by the assignment, like T{} or &T{} in x := T{}.
+//
+// Panics if the first value assigned is not a composite literal.
+func (assignment *Assignment) Struct() Struct {
+	expr := assignment.Node.Rhs[0]
+
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		expr = unary.X
 	}
 
-	panic(fmt.Sprintf("struct %+v doesn't have a property called %+v", struct_, key))
-}
+	composite, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		panic(errors.Errorf("%s does not assign a composite literal", SourceCode(assignment.Node)))
+	}
 
-func (assignment *Assignment) Struct() Struct {
-	composite := assignment.Node.Rhs[0].(*ast.CompositeLit)
-	return Struct{literal: composite}
+	return Struct{CompositeLiteral{Node: composite, file: assignment.file}}
 }
 
 func (assignment *Assignment) Replace(node ast.Stmt) {
-	if funDecl := assignment.Parent.FindUpstreamNode(&ast.FuncDecl{}); funDecl != nil {
-		block := funDecl.Node.(*ast.FuncDecl).Body
-
-		for i := 0; i < len(block.List); i++ {
-			assignStmt, ok := block.List[i].(*ast.AssignStmt)
-
-			if ok &&
-				reflect.DeepEqual(assignment.Node.Lhs, assignStmt.Lhs) &&
-				reflect.DeepEqual(assignment.Node.Rhs, assignStmt.Rhs) {
-				block.List[i] = node
-			}
-		}
+	if err := assignment.cursor.Replace(node); err != nil {
+		panic(err)
 	}
 }
 
-func (code *SourceFile) Assignments() map[Scope][]Assignment {
-	assignments := make(map[Scope][]Assignment, 0)
+// Returns the assignments, like x := 1 or x += 1, grouped by the innermost scope they are in,
+// including the ones that are not directly in a block, like the init statement of an if statement.
+//
+// See AssignmentsInOrder to visit them in the order they appear in
+// and Bindings for variable and constant declarations.
+func (code *SourceFile) Assignments(matchers ...Matcher) map[Scope][]Assignment {
+	return groupAssignments(code.AssignmentsInOrder(matchers...))
+}
+
+// Returns the assignments in the order they appear in the file. See Assignments.
+func (code *SourceFile) AssignmentsInOrder(matchers ...Matcher) []Assignment {
+	out := make([]Assignment, 0)
 
-	parent := NodeWithParent{
-		Node: code.file,
+	for _, match := range code.find("AssignStmt", matchers) {
+		out = append(out, Assignment{
+			Parent: match.Cursor.parent.nodeWithParent(),
+			Node:   match.Node.(*ast.AssignStmt),
+			file:   code,
+			cursor: match.Cursor,
+		})
 	}
 
-	var scope Scope
+	return out
+}
 
-	ast.Inspect(code.file, func(node ast.Node) bool {
-		p := parent
-		parent = NodeWithParent{
-			Parent: &p,
-			Node:   node,
-		}
+// Returns the assignments to `target`, like s, s.x or s[0], grouped by the innermost scope they are in.
+//
+// See FindAssignmentsInOrder to visit them in the order they appear in.
+func (code *SourceFile) FindAssignments(target string, matchers ...Matcher) map[Scope][]Assignment {
+	return groupAssignments(code.FindAssignmentsInOrder(target, matchers...))
+}
 
-		switch value := node.(type) {
-		case *ast.FuncDecl:
-			scope = Scope{fun: value}
-		case *ast.BlockStmt:
-			for _, statement := range value.List {
-				stmt, ok := statement.(*ast.AssignStmt)
-				if ok {
-					assignments[scope] = append(assignments[scope], Assignment{
-						Parent: parent,
-						Node:   stmt,
-					})
-				}
-			}
-		}
+// Returns the assignments to `target` in the order they appear in the file. See FindAssignments.
+func (code *SourceFile) FindAssignmentsInOrder(target string, matchers ...Matcher) []Assignment {
+	out := make([]Assignment, 0)
 
-		return true
-	})
+	for _, assignment := range code.AssignmentsInOrder(matchers...) {
+		if assignsTo(assignment.Node, target) {
+			out = append(out, assignment)
+		}
+	}
 
-	return assignments
+	return out
 }
 
-func (code *SourceFile) FindAssignments(target string) map[Scope][]Assignment {
-	out := make(map[Scope][]Assignment, 0)
+// Returns true if `assignment` is `target` or assigns to it.
+func assignsTo(assignment *ast.AssignStmt, target string) bool {
+	if NormalizeString(SourceCode(assignment)) == NormalizeString(target) {
+		return true
+	}
 
-	for scope, assignments := range code.Assignments() {
-		for _, assignment := range assignments {
-			if NormalizeString(SourceCode(assignment.Node)) == NormalizeString(target) {
-				out[scope] = append(out[scope], assignment)
-				continue
+	for _, expr := range assignment.Lhs {
+		switch ident := expr.(type) {
+		case *ast.SelectorExpr:
+			if NormalizeString(SourceCode(ident)) == NormalizeString(target) {
+				return true
 			}
-
-			for _, expr := range assignment.Node.Lhs {
-				switch ident := expr.(type) {
-				case *ast.SelectorExpr:
-					if NormalizeString(SourceCode(ident)) == NormalizeString(target) {
-						out[scope] = append(out[scope], assignment)
-					}
-				case *ast.Ident:
-					if ident.Name == target {
-						out[scope] = append(out[scope], assignment)
-					}
-				case *ast.IndexExpr:
-					if NormalizeString(SourceCode(ident.X)) == NormalizeString(target) ||
-						NormalizeString(SourceCode(ident)) == NormalizeString(target) {
-						out[scope] = append(out[scope], assignment)
-					}
-				}
+		case *ast.Ident:
+			if ident.Name == target {
+				return true
+			}
+		case *ast.IndexExpr:
+			if NormalizeString(SourceCode(ident.X)) == NormalizeString(target) ||
+				NormalizeString(SourceCode(ident)) == NormalizeString(target) {
+				return true
 			}
 		}
 	}
 
+	return false
+}
+
+// Groups `assignments` by the innermost scope they are in, keeping their order in each scope.
+func groupAssignments(assignments []Assignment) map[Scope][]Assignment {
+	out := make(map[Scope][]Assignment)
+
+	for _, assignment := range assignments {
+		scope := assignment.Scope()
+		out[scope] = append(out[scope], assignment)
+	}
+
 	return out
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -52,7 +54,7 @@ func Test_Map(t *testing.T) {
 		t.Run("renames the key", func(t *testing.T) {
 			expected := `map[string]string{"tx_isolation": "'READ-COMMITED'"}`
 
-			literal.RenameKey("transaction_isolation", "tx_isolation")
+			assert.NoError(t, literal.RenameKey("transaction_isolation", "tx_isolation"))
 
 			actual := codemod.SourceCode(literal.Expr.Node)
 
@@ -66,7 +68,7 @@ func Test_Map(t *testing.T) {
 
 			expected := `map[string]string{"transaction_isolation": "'READ-COMMITED'"}`
 
-			literal.RenameKey("a", "b")
+			assert.NoError(t, literal.RenameKey("a", "b"))
 
 			actual := codemod.SourceCode(literal.Expr.Node)
 
@@ -144,6 +146,36 @@ func Test_IfStatements(t *testing.T) {
 
 			check(t, expected, actual)
 		})
+
+		t.Run("keeps the other statements and removes the else branch", func(t *testing.T) {
+			file, _ := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func main() {
+	println(1)
+	if ok() {
+		println(2)
+	} else if other() {
+		println(3)
+	} else {
+		println(4)
+	}
+	println(5)
+}
+`)})
+
+			for _, statements := range file.IfStatements() {
+				for _, statement := range statements {
+					if codemod.SourceCode(statement.Node.Cond) == "ok()" {
+						statement.RemoveCondition()
+					}
+				}
+			}
+
+			expected := "package main\n\nfunc main() {\n\tprintln(1)\n\tprintln(2)\n\tprintln(5)\n}\n"
+
+			// The inlined statements keep their spacing.
+			assert.Equal(t, expected, string(file.SourceCode()))
+		})
 	})
 }
 
@@ -1430,7 +1462,7 @@ func Test_SourceFile_Imports(t *testing.T) {
 		imports.Add("new_import")
 		imports.Add("new_import")
 
-		assert.Equal(t, []string{"errors", "package_a", "package_b", "new_import"}, imports.Paths())
+		assert.Equal(t, []string{"errors", "new_import", "package_a", "package_b"}, imports.Paths())
 	})
 
 	t.Run("checks if file contains import path", func(t *testing.T) {
@@ -1453,6 +1485,196 @@ func Test_SourceFile_Imports(t *testing.T) {
 
 		assert.Equal(t, []string{"errors", "package_b"}, imports.Paths())
 	})
+
+	t.Run("returns import names", func(t *testing.T) {
+		file, _ := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import (
+	_ "embed"
+	. "fmt"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+`)})
+
+		imports := file.Imports()
+
+		assert.Equal(t, []codemod.Import{
+			{Name: "_", Path: "embed"},
+			{Name: ".", Path: "fmt"},
+			{Name: "pkgerrors", Path: "github.com/pkg/errors"},
+			{Path: "github.com/go-redis/redis/v8"},
+		}, imports.List())
+
+		assert.Equal(t, []string{"embed", "fmt", "github.com/pkg/errors", "github.com/go-redis/redis/v8"}, imports.Paths())
+
+		name, ok := imports.Name("github.com/pkg/errors")
+		assert.True(t, ok)
+		assert.Equal(t, "pkgerrors", name)
+
+		name, ok = imports.Name("github.com/go-redis/redis/v8")
+		assert.True(t, ok)
+		assert.Equal(t, "", name)
+
+		name, ok = imports.LocalName("github.com/go-redis/redis/v8")
+		assert.True(t, ok)
+		assert.Equal(t, "redis", name)
+
+		_, ok = imports.Name("errors")
+		assert.False(t, ok)
+
+		assert.True(t, imports.Contains("github.com/pkg/errors"))
+		assert.True(t, imports.ContainsNamed("pkgerrors", "github.com/pkg/errors"))
+		assert.False(t, imports.ContainsNamed("", "github.com/pkg/errors"))
+	})
+
+	t.Run("changes import names", func(t *testing.T) {
+		file, _ := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import (
+	"fmt"
+
+	pkgerrors "github.com/pkg/errors"
+)
+`)})
+
+		imports := file.Imports()
+
+		imports.SetName("github.com/pkg/errors", "")
+		imports.SetName("fmt", "format")
+		imports.AddNamed("_", "embed")
+		imports.AddNamed("_", "embed")
+
+		expected := `package main
+
+import (
+	_ "embed"
+	format "fmt"
+
+	"github.com/pkg/errors"
+)
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+
+		imports.RemoveNamed("", "embed")
+		assert.True(t, imports.Contains("embed"))
+
+		imports.RemoveNamed("_", "embed")
+		assert.False(t, imports.Contains("embed"))
+	})
+
+	t.Run("creates an import declaration when the file does not have one", func(t *testing.T) {
+		file, _ := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+// main does nothing.
+func main() {}
+`)})
+
+		file.Imports().Add("fmt")
+
+		expected := `package main
+
+import "fmt"
+
+// main does nothing.
+func main() {}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+
+		file.Imports().Add("errors")
+
+		expected = `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// main does nothing.
+func main() {}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("adds imports to the group they belong to", func(t *testing.T) {
+		directory := t.TempDir()
+
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(directory, "go.mod"), []byte("module example.com/project\n"), 0644))
+
+		file, _ := codemod.New(codemod.NewInput{
+			FilePath: filepath.Join(directory, "main.go"),
+			SourceCode: []byte(`package main
+
+import (
+	// Formatting.
+	"fmt"
+
+	"example.com/project/users"
+)
+`),
+		})
+
+		imports := file.Imports()
+
+		imports.Add("example.com/project/orders")
+		imports.Add("github.com/pkg/errors")
+		imports.Add("errors")
+		imports.Add("github.com/google/uuid")
+
+		expected := `package main
+
+import (
+	"errors"
+	// Formatting.
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"example.com/project/orders"
+	"example.com/project/users"
+)
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("starts a group before the groups that come after it", func(t *testing.T) {
+		file, _ := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import "github.com/pkg/errors"
+`)})
+
+		file.Imports().Add("fmt")
+
+		expected := `package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("removes import declarations without imports", func(t *testing.T) {
+		file, _ := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import "fmt"
+
+func main() {}
+`)})
+
+		file.Imports().Remove("fmt")
+
+		assert.Equal(t, "package main\n\nfunc main() {}\n", string(file.SourceCode()))
+	})
 }
 
 func Test_SourceFile_SwitchStatements(t *testing.T) {
@@ -1655,9 +1877,6 @@ func Create(ctx context.Context) {
 	defer StartDBSegment("users",
 		"INSERT",
 		"INSERT INTO users (name, age) VALUES ($1, $2)").End()
-
-	// ... make the datastore call
-
 }
 `
 
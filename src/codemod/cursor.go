@@ -0,0 +1,429 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Points to a node in the tree and to where the node is stored in its parent,
+// so the node can be replaced, deleted or have nodes inserted next to it.
+//
+// Cursors are created by SourceFile.Walk and can be kept after the walk is done.
+type Cursor struct {
+	node   ast.Node
+	parent *Cursor
+	// The name of the field of the parent node the node is stored in.
+	name string
+	// The position of the node in the field if the field is a list, -1 otherwise.
+	index int
+	// The list being iterated over when the cursor was created,
+	// used to keep iterating from the right element when the list is changed.
+	iter *iterator
+	file *SourceFile
+}
+
+// The state of the iteration over a list of nodes.
+type iterator struct {
+	// The index of the node being visited.
+	index int
+	// How many elements to move forward after visiting the node.
+	step int
+	// True after every node in the list has been visited.
+	done bool
+}
+
+// Visits every node in the source file in depth-first order, starting at the *ast.File.
+//
+// Children of a node are not visited when `f` returns false.
+// Nodes inserted before or after the node being visited are not visited,
+// children of a node that replaced the node being visited are.
+func (code *SourceFile) Walk(f func(*Cursor) bool) {
+	walk(&Cursor{node: code.file, index: -1, file: code}, f)
+}
+
+// Returns the node the cursor points to.
+func (cursor *Cursor) Node() ast.Node {
+	return cursor.node
+}
+
+// Returns the parent of the node the cursor points to or nil if the node is the *ast.File.
+func (cursor *Cursor) Parent() ast.Node {
+	if cursor.parent == nil {
+		return nil
+	}
+
+	return cursor.parent.node
+}
+
+// Returns the cursor that points to the parent of the node or nil if the node is the *ast.File.
+func (cursor *Cursor) ParentCursor() *Cursor {
+	return cursor.parent
+}
+
+// Returns the name of the field of the parent node the node is stored in,
+// like Body for the body of an *ast.FuncDecl.
+func (cursor *Cursor) Name() string {
+	return cursor.name
+}
+
+// Returns the position of the node in the list it belongs to, like the index of
+// an argument in *ast.CallExpr.Args, or -1 if the node does not belong to a list.
+func (cursor *Cursor) Index() int {
+	if cursor.index < 0 {
+		return -1
+	}
+
+	if index, ok := cursor.currentIndex(); ok {
+		return index
+	}
+
+	return cursor.index
+}
+
+// Returns true if the node belongs to a list.
+func (cursor *Cursor) InList() bool {
+	return cursor.index >= 0
+}
+
+// Replaces the node with `node` and moves the comments attached
+// to the node to `node`.
+//
+// Returns error if `node` can't be stored where the node is,
+// like replacing a statement with an expression.
+func (cursor *Cursor) Replace(node ast.Node) error {
+	field, err := cursor.field()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	value, err := nodeValue(node, field.Type(), cursor.listElementType(field))
+	if err != nil {
+		return errors.Wrapf(err, "can't replace %s", cursor.description())
+	}
+
+	if cursor.InList() {
+		index, ok := cursor.currentIndex()
+		if !ok {
+			return errors.Errorf("can't replace %s: node is no longer in the list", cursor.description())
+		}
+
+		field.Index(index).Set(value)
+	} else {
+		field.Set(value)
+	}
+
+	cursor.file.MoveComments(cursor.node, node)
+
+	cursor.node = node
+
+	return nil
+}
+
+// Removes the node from the list it belongs to.
+//
+// Returns error if the node does not belong to a list.
+func (cursor *Cursor) Delete() error {
+	field, index, err := cursor.list()
+	if err != nil {
+		return errors.Wrapf(err, "can't delete %s", cursor.description())
+	}
+
+	list := reflect.AppendSlice(field.Slice(0, index), field.Slice(index+1, field.Len()))
+	field.Set(list)
+
+	if cursor.iterating() {
+		switch {
+		case index == cursor.iter.index:
+			cursor.iter.step--
+		case index < cursor.iter.index:
+			cursor.iter.index--
+		}
+	}
+
+	cursor.index = -1
+
+	return nil
+}
+
+// Inserts `node` before the node in the list it belongs to.
+//
+// Returns error if the node does not belong to a list
+// or if `node` can't be stored in the list.
+func (cursor *Cursor) InsertBefore(node ast.Node) error {
+	field, index, err := cursor.list()
+	if err != nil {
+		return errors.Wrapf(err, "can't insert before %s", cursor.description())
+	}
+
+	if err := insertAt(field, index, node); err != nil {
+		return errors.Wrapf(err, "can't insert before %s", cursor.description())
+	}
+
+	if cursor.iterating() && index <= cursor.iter.index {
+		cursor.iter.index++
+	}
+
+	cursor.index = index + 1
+
+	return nil
+}
+
+// Inserts `node` after the node in the list it belongs to.
+//
+// Returns error if the node does not belong to a list
+// or if `node` can't be stored in the list.
+func (cursor *Cursor) InsertAfter(node ast.Node) error {
+	field, index, err := cursor.list()
+	if err != nil {
+		return errors.Wrapf(err, "can't insert after %s", cursor.description())
+	}
+
+	if err := insertAt(field, index+1, node); err != nil {
+		return errors.Wrapf(err, "can't insert after %s", cursor.description())
+	}
+
+	if cursor.iterating() {
+		switch {
+		case index == cursor.iter.index:
+			cursor.iter.step++
+		case index < cursor.iter.index:
+			cursor.iter.index++
+		}
+	}
+
+	return nil
+}
+
+// Returns the cursor of the closest ancestor, starting at the node itself,
+// for which `f` returns true or nil if there is none.
+func (cursor *Cursor) findUpstream(f func(*Cursor) bool) *Cursor {
+	for current := cursor; current != nil; current = current.parent {
+		if f(current) {
+			return current
+		}
+	}
+
+	return nil
+}
+
+// Returns the cursor of the statement that contains the node
+// and that belongs to a list of statements, like the body of a function.
+func (cursor *Cursor) statement() *Cursor {
+	return cursor.findUpstream(func(current *Cursor) bool {
+		_, ok := current.node.(ast.Stmt)
+		return ok && current.InList()
+	})
+}
+
+// Returns the node and its ancestors as a NodeWithParent.
+func (cursor *Cursor) nodeWithParent() NodeWithParent {
+	if cursor.parent == nil {
+		return NodeWithParent{Node: cursor.node}
+	}
+
+	parent := cursor.parent.nodeWithParent()
+
+	return NodeWithParent{Parent: &parent, Node: cursor.node}
+}
+
+// Returns a description of where the node is, like *ast.CallExpr.Args[1].
+func (cursor *Cursor) description() string {
+	if cursor.parent == nil {
+		return fmt.Sprintf("%T", cursor.node)
+	}
+
+	if cursor.InList() {
+		return fmt.Sprintf("%T.%s[%d]", cursor.parent.node, cursor.name, cursor.Index())
+	}
+
+	return fmt.Sprintf("%T.%s", cursor.parent.node, cursor.name)
+}
+
+// Returns the field of the parent node the node is stored in.
+func (cursor *Cursor) field() (reflect.Value, error) {
+	if cursor.parent == nil {
+		return reflect.Value{}, errors.New("the *ast.File is not stored in a parent node")
+	}
+
+	field := reflect.ValueOf(cursor.parent.node).Elem().FieldByName(cursor.name)
+	if !field.IsValid() {
+		return reflect.Value{}, errors.Errorf("%T does not have a field called %s", cursor.parent.node, cursor.name)
+	}
+
+	return field, nil
+}
+
+// Returns the list the node belongs to and the position of the node in it.
+func (cursor *Cursor) list() (reflect.Value, int, error) {
+	if !cursor.InList() {
+		return reflect.Value{}, 0, errors.New("node is not in a list")
+	}
+
+	field, err := cursor.field()
+	if err != nil {
+		return reflect.Value{}, 0, errors.WithStack(err)
+	}
+
+	index, ok := cursor.currentIndex()
+	if !ok {
+		return reflect.Value{}, 0, errors.New("node is no longer in the list")
+	}
+
+	return field, index, nil
+}
+
+// Returns the position of the node in the list it belongs to.
+//
+// Nodes may have been added to or removed from the list since the cursor
+// was created, so the node is searched for if it is not where it was.
+func (cursor *Cursor) currentIndex() (int, bool) {
+	field, err := cursor.field()
+	if err != nil || field.Kind() != reflect.Slice {
+		return 0, false
+	}
+
+	if cursor.index < field.Len() && field.Index(cursor.index).Interface() == cursor.node {
+		return cursor.index, true
+	}
+
+	for i := 0; i < field.Len(); i++ {
+		if field.Index(i).Interface() == cursor.node {
+			cursor.index = i
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// Returns true if the walk that created the cursor is still iterating over
+// the list the node belongs to, so changes to the list must update the iteration.
+func (cursor *Cursor) iterating() bool {
+	return cursor.iter != nil && !cursor.iter.done
+}
+
+// Returns the type of the elements of `field` if the node belongs to a list.
+func (cursor *Cursor) listElementType(field reflect.Value) reflect.Type {
+	if !cursor.InList() {
+		return nil
+	}
+
+	return field.Type().Elem()
+}
+
+// Returns `node` as a value that can be stored in a field of type `fieldType`,
+// or in an element of the list if `elementType` is not nil.
+func nodeValue(node ast.Node, fieldType, elementType reflect.Type) (reflect.Value, error) {
+	typ := fieldType
+	if elementType != nil {
+		typ = elementType
+	}
+
+	if isNilNode(node) {
+		return reflect.Value{}, errors.New("node is nil")
+	}
+
+	if !reflect.TypeOf(node).AssignableTo(typ) {
+		return reflect.Value{}, errors.Errorf("%T can't be used as %s", node, typ)
+	}
+
+	return reflect.ValueOf(node), nil
+}
+
+// Inserts `node` in the list `field` at `index`.
+func insertAt(field reflect.Value, index int, node ast.Node) error {
+	value, err := nodeValue(node, nil, field.Type().Elem())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	list := reflect.MakeSlice(field.Type(), 0, field.Len()+1)
+	list = reflect.AppendSlice(list, field.Slice(0, index))
+	list = reflect.Append(list, value)
+	list = reflect.AppendSlice(list, field.Slice(index, field.Len()))
+
+	field.Set(list)
+
+	return nil
+}
+
+// Calls `f` with `cursor` and walks the children of the node if `f` returns true.
+func walk(cursor *Cursor, f func(*Cursor) bool) {
+	if !f(cursor) {
+		return
+	}
+
+	// The node has been deleted.
+	if cursor.iter != nil && cursor.index < 0 {
+		return
+	}
+
+	value := reflect.ValueOf(cursor.node)
+
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	structValue := value.Elem()
+	structType := structValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		if isSkippedField(structType, field) {
+			continue
+		}
+
+		switch {
+		case isNodeType(field.Type):
+			fieldValue := structValue.Field(i)
+
+			if fieldValue.IsNil() {
+				continue
+			}
+
+			walk(&Cursor{
+				node:   fieldValue.Interface().(ast.Node),
+				parent: cursor,
+				name:   field.Name,
+				index:  -1,
+				file:   cursor.file,
+			}, f)
+
+		case field.Type.Kind() == reflect.Slice && isNodeType(field.Type.Elem()):
+			walkList(cursor, structValue, i, f)
+		}
+	}
+}
+
+// Walks the elements of the list stored in the field `i` of the node `cursor` points to.
+//
+// The field is read again after each element is visited
+// because `f` may have changed the list.
+func walkList(cursor *Cursor, structValue reflect.Value, i int, f func(*Cursor) bool) {
+	iter := &iterator{}
+
+	for iter.index = 0; iter.index < structValue.Field(i).Len(); iter.index += iter.step {
+		iter.step = 1
+
+		element := structValue.Field(i).Index(iter.index)
+
+		if element.IsNil() {
+			continue
+		}
+
+		walk(&Cursor{
+			node:   element.Interface().(ast.Node),
+			parent: cursor,
+			name:   structValue.Type().Field(i).Name,
+			index:  iter.index,
+			iter:   iter,
+			file:   cursor.file,
+		}, f)
+	}
+
+	iter.done = true
+}
@@ -0,0 +1,293 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SourceFile_Walk(t *testing.T) {
+	t.Parallel()
+
+	t.Run("yields the parent, field and index of every node", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func main() {
+	if true {
+		f(1, 2)
+	}
+	g()
+}
+`)})
+		assert.NoError(t, err)
+
+		type location struct {
+			parent string
+			name   string
+			index  int
+		}
+
+		locations := make(map[string]location)
+
+		file.Walk(func(cursor *codemod.Cursor) bool {
+			if lit, ok := cursor.Node().(*ast.BasicLit); ok {
+				locations[lit.Value] = location{parent: codemod.SourceCode(cursor.Parent()), name: cursor.Name(), index: cursor.Index()}
+			}
+
+			if call, ok := cursor.Node().(*ast.CallExpr); ok && codemod.SourceCode(call) == "g()" {
+				_, isExprStmt := cursor.Parent().(*ast.ExprStmt)
+				assert.True(t, isExprStmt)
+				assert.Equal(t, "X", cursor.Name())
+				assert.Equal(t, -1, cursor.Index())
+				assert.Equal(t, 1, cursor.ParentCursor().Index())
+			}
+
+			return true
+		})
+
+		assert.Equal(t, location{parent: "f(1, 2)", name: "Args", index: 0}, locations["1"])
+		assert.Equal(t, location{parent: "f(1, 2)", name: "Args", index: 1}, locations["2"])
+	})
+
+	t.Run("does not visit children when f returns false", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte("package main\n\nfunc f() { g() }\n")})
+		assert.NoError(t, err)
+
+		visited := false
+
+		file.Walk(func(cursor *codemod.Cursor) bool {
+			if _, ok := cursor.Node().(*ast.CallExpr); ok {
+				visited = true
+			}
+
+			_, isFunc := cursor.Node().(*ast.FuncDecl)
+
+			return !isFunc
+		})
+
+		assert.False(t, visited)
+	})
+}
+
+func Test_Cursor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("edits case clauses, declarations, call arguments and composite literal elements", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+var a = 1
+
+var b = 2
+
+func main() {
+	switch x {
+	case 1:
+		f(1, 2, 3)
+	case 2:
+		g()
+	}
+
+	_ = []int{1, 2, 3}
+}
+`)})
+		assert.NoError(t, err)
+
+		file.Walk(func(cursor *codemod.Cursor) bool {
+			switch node := cursor.Node().(type) {
+			case *ast.GenDecl:
+				if codemod.SourceCode(node) == "var b = 2" {
+					assert.NoError(t, cursor.Delete())
+				}
+
+			case *ast.CaseClause:
+				if codemod.SourceCode(node.List[0]) == "2" {
+					assert.NoError(t, cursor.InsertBefore(&ast.CaseClause{
+						List: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "3"}},
+						Body: []ast.Stmt{codemod.Ast("h()").(ast.Stmt)},
+					}))
+				}
+
+			case *ast.BasicLit:
+				if _, ok := cursor.Parent().(*ast.CallExpr); ok && node.Value == "2" {
+					assert.NoError(t, cursor.Delete())
+				}
+
+				if _, ok := cursor.Parent().(*ast.CompositeLit); ok && node.Value == "3" {
+					assert.NoError(t, cursor.InsertAfter(&ast.BasicLit{Kind: token.INT, Value: "4"}))
+					assert.NoError(t, cursor.Replace(&ast.BasicLit{Kind: token.INT, Value: "30"}))
+				}
+			}
+
+			return true
+		})
+
+		expected := `package main
+
+var a = 1
+
+func main() {
+	switch x {
+	case 1:
+		f(1, 3)
+	case 3:
+		h()
+	case 2:
+		g()
+	}
+
+	_ = []int{1, 2, 30, 4}
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("visits every node once when the list being walked is changed", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func main() {
+	a()
+	b()
+	c()
+}
+`)})
+		assert.NoError(t, err)
+
+		visited := make([]string, 0)
+
+		file.Walk(func(cursor *codemod.Cursor) bool {
+			call, ok := cursor.Node().(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			visited = append(visited, codemod.SourceCode(call))
+
+			statement := cursor.ParentCursor()
+
+			switch codemod.SourceCode(call) {
+			case "a()":
+				assert.NoError(t, statement.InsertBefore(codemod.Ast("before()")))
+				assert.NoError(t, statement.InsertAfter(codemod.Ast("after()")))
+			case "b()":
+				assert.NoError(t, statement.Delete())
+			}
+
+			return true
+		})
+
+		assert.Equal(t, []string{"a()", "b()", "c()"}, visited)
+
+		expected := `package main
+
+func main() {
+	before()
+	a()
+	after()
+	c()
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("can be used after the walk is done", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func main() {
+	a()
+	b()
+}
+`)})
+		assert.NoError(t, err)
+
+		cursors := make([]*codemod.Cursor, 0)
+
+		file.Walk(func(cursor *codemod.Cursor) bool {
+			if _, ok := cursor.Node().(*ast.ExprStmt); ok {
+				cursors = append(cursors, cursor)
+			}
+
+			return true
+		})
+
+		assert.NoError(t, cursors[0].Delete())
+		assert.NoError(t, cursors[1].InsertAfter(codemod.Ast("c()")))
+		assert.Equal(t, 0, cursors[1].Index())
+
+		assert.Error(t, cursors[0].Delete())
+
+		assert.Equal(t, "package main\n\nfunc main() {\n\tb()\n\tc()\n}\n", string(file.SourceCode()))
+	})
+
+	t.Run("returns error when the node can't be stored where the cursor points to", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte("package main\n\nfunc main() {\n\tf(1)\n}\n")})
+		assert.NoError(t, err)
+
+		file.Walk(func(cursor *codemod.Cursor) bool {
+			switch cursor.Node().(type) {
+			case *ast.File:
+				assert.Error(t, cursor.Replace(codemod.Ast("x")))
+			case *ast.Ident:
+				// Names are not in a list.
+				assert.Error(t, cursor.Delete())
+			case *ast.BasicLit:
+				// Statements can't be function arguments.
+				assert.Error(t, cursor.InsertAfter(codemod.Ast("x := 1")))
+				assert.Error(t, cursor.Replace(codemod.Ast("x := 1")))
+			}
+
+			return true
+		})
+
+		assert.Equal(t, "package main\n\nfunc main() {\n\tf(1)\n}\n", string(file.SourceCode()))
+	})
+}
+
+func Test_FunctionCall_InsertAfter_NestedStatements(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func main() {
+	if true {
+		a()
+	}
+
+	switch {
+	case true:
+		x := b()
+		_ = x
+	}
+}
+`)})
+	assert.NoError(t, err)
+
+	for _, calls := range file.FunctionCalls() {
+		for _, call := range calls {
+			call.InsertAfter(codemod.Ast(`println("after ` + call.FunctionName() + `")`))
+		}
+	}
+
+	expected := `package main
+
+func main() {
+	if true {
+		a()
+		println("after a")
+	}
+
+	switch {
+	case true:
+		x := b()
+		println("after b")
+		_ = x
+	}
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
@@ -0,0 +1,422 @@
+package codemod
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// Comments are not part of the syntax tree. The parser keeps them in a list
+// sorted by position and the printer puts them back where their positions say
+// they should be, so once nodes are moved, replaced or created, comments end up
+// next to the wrong code or in the middle of it.
+//
+// To avoid that, every comment is attached to the node it is about when the file is parsed.
+// When the tree changes, positions are computed again from the tree itself
+// and comments are printed next to the nodes they are attached to.
+
+type commentPlacement int
+
+const (
+	// The comment comes before the node or before one of its tokens.
+	commentBefore commentPlacement = iota
+	// The comment comes after the node.
+	commentAfter
+)
+
+// A comment group attached to a node.
+type decoration struct {
+	group     *ast.CommentGroup
+	placement commentPlacement
+	// Name of the position field of the token the comment comes before.
+	// Empty if the comment comes before the node itself.
+	token string
+	// Number of lines between the comment and the node.
+	lines int
+	// True if the comment started in a line of its own.
+	ownLine bool
+}
+
+type decorations struct {
+	fileSet *token.FileSet
+	// Comments attached to each node.
+	comments map[ast.Node][]decoration
+	// Nodes created by the parser. Positions of other nodes don't mean anything in the file.
+	parsed map[ast.Node]bool
+	// True if comments were moved from one node to another
+	// or nodes were marked to start after an empty line.
+	moved bool
+	// Nodes that start after an empty line.
+	emptyLines map[ast.Node]bool
+	// Maps positions of the tokens in the source code to the lines the tokens end at.
+	tokens map[token.Pos]int
+	// Number of lines in the source code.
+	lines int
+	// Lines of the source code that have tokens or comments.
+	occupied []bool
+}
+
+// Attaches every comment in `file` to the node it is about.
+func decorate(fileSet *token.FileSet, file *ast.File) *decorations {
+	d := &decorations{
+		fileSet:    fileSet,
+		comments:   make(map[ast.Node][]decoration),
+		parsed:     make(map[ast.Node]bool),
+		emptyLines: make(map[ast.Node]bool),
+		tokens:     make(map[token.Pos]int),
+		lines:      fileSet.File(file.Pos()).LineCount(),
+	}
+
+	var walk func(node, parent ast.Node)
+
+	walk = func(node, parent ast.Node) {
+		d.parsed[node] = true
+
+		for _, part := range partsOf(node, parent) {
+			if part.node != nil {
+				walk(part.node, node)
+			} else if part.pos.IsValid() {
+				d.tokens[*part.pos] = d.line(*part.pos) + strings.Count(tokenText(node, part.field), "\n")
+			}
+		}
+	}
+
+	walk(file, nil)
+
+	d.occupied = make([]bool, d.lines+2)
+
+	for pos, endLine := range d.tokens {
+		for line := d.line(pos); line <= endLine; line++ {
+			d.occupied[line] = true
+		}
+	}
+
+	for _, group := range file.Comments {
+		node, decoration := d.attach(file, group)
+
+		d.comments[node] = append(d.comments[node], decoration)
+
+		for line := d.line(group.Pos()); line <= d.line(group.End()); line++ {
+			d.occupied[line] = true
+		}
+	}
+
+	return d
+}
+
+func (d *decorations) line(pos token.Pos) int {
+	return d.fileSet.Position(pos).Line
+}
+
+// Returns the first and last position of a part.
+func (d *decorations) span(part part) (token.Pos, token.Pos) {
+	if part.pos != nil {
+		return *part.pos, *part.pos + 1
+	}
+
+	return part.node.Pos(), part.node.End()
+}
+
+// Finds the node `group` is about.
+//
+// Comments at the end of a line are about what comes before them
+// and other comments are about what comes after them.
+func (d *decorations) attach(file *ast.File, group *ast.CommentGroup) (ast.Node, decoration) {
+	var enclosing ast.Node = file
+
+	var parent ast.Node
+
+	var previous, next *part
+
+	// Look for the smallest node that contains the comment.
+	for {
+		parts := partsOf(enclosing, parent)
+
+		previous, next = nil, nil
+
+		var child ast.Node
+
+		for i := range parts {
+			start, end := d.span(parts[i])
+
+			switch {
+			case !start.IsValid():
+			case end <= group.Pos():
+				previous = &parts[i]
+			case start >= group.End():
+				if next == nil {
+					next = &parts[i]
+				}
+			case parts[i].node != nil:
+				child = parts[i].node
+			}
+		}
+
+		if child == nil {
+			break
+		}
+
+		parent, enclosing = enclosing, child
+	}
+
+	ownLine := true
+
+	if previous != nil {
+		_, end := d.span(*previous)
+
+		ownLine = d.line(end) < d.line(group.Pos())
+	}
+
+	switch {
+	case previous != nil && previous.node != nil && !ownLine:
+		return previous.node, decoration{group: group, placement: commentAfter}
+
+	case next != nil && next.node != nil:
+		// A comment right after a node that is followed by an empty line is about the node before it.
+		if previous != nil && previous.node != nil &&
+			d.line(group.Pos()) == d.line(previous.node.End())+1 &&
+			d.line(next.node.Pos()) > d.line(group.End())+1 {
+			return previous.node, decoration{group: group, placement: commentAfter, lines: 1, ownLine: true}
+		}
+
+		return next.node, decoration{
+			group:     group,
+			placement: commentBefore,
+			lines:     d.line(next.node.Pos()) - d.line(group.Pos()),
+			ownLine:   ownLine,
+		}
+
+	case next != nil:
+		return enclosing, decoration{
+			group:     group,
+			placement: commentBefore,
+			token:     next.field,
+			lines:     d.line(*next.pos) - d.line(group.Pos()),
+			ownLine:   ownLine,
+		}
+
+	case previous != nil && previous.node != nil:
+		return previous.node, decoration{
+			group:     group,
+			placement: commentAfter,
+			lines:     d.line(group.Pos()) - d.line(previous.node.End()),
+			ownLine:   ownLine,
+		}
+
+	default:
+		return enclosing, decoration{
+			group:     group,
+			placement: commentAfter,
+			lines:     d.line(group.Pos()) - d.line(enclosing.End()),
+			ownLine:   ownLine,
+		}
+	}
+}
+
+// Returns true if every node in the tree came from the parser, appears once
+// and is where the parser found it, and no node was removed. When that's the case,
+// the tree can be printed with the positions given by the parser.
+func (d *decorations) unchanged(file *ast.File) bool {
+	if d.moved {
+		return false
+	}
+
+	last := token.NoPos
+
+	tokens := 0
+
+	var walk func(node, parent ast.Node) bool
+
+	walk = func(node, parent ast.Node) bool {
+		if isNilNode(node) {
+			return true
+		}
+
+		if !d.parsed[node] {
+			return false
+		}
+
+		for _, part := range partsOf(node, parent) {
+			if part.node != nil {
+				if !walk(part.node, node) {
+					return false
+				}
+
+				continue
+			}
+
+			if !part.pos.IsValid() {
+				continue
+			}
+
+			if *part.pos <= last {
+				return false
+			}
+
+			last = *part.pos
+			tokens++
+		}
+
+		return true
+	}
+
+	return walk(file, nil) && tokens == len(d.tokens)
+}
+
+// Attaches the comments attached to `from` to `to`.
+func (d *decorations) move(from, to ast.Node) {
+	if from == to || len(d.comments[from]) == 0 {
+		return
+	}
+
+	d.comments[to] = append(d.comments[to], d.comments[from]...)
+	delete(d.comments, from)
+
+	d.moved = true
+}
+
+// Makes `node` start after an empty line, used for nodes that are
+// not where the parser found them, since only the positions of those are kept.
+func (d *decorations) setEmptyLineBefore(node ast.Node) {
+	if d.emptyLines[node] {
+		return
+	}
+
+	d.emptyLines[node] = true
+	d.moved = true
+}
+
+// Attaches the comments attached to `from` to `to`,
+// so they are printed next to `to` instead of being removed with `from`.
+//
+// Useful when a node is replaced by a new node.
+func (code *SourceFile) MoveComments(from, to ast.Node) {
+	if code == nil || code.decorations == nil {
+		return
+	}
+
+	code.decorations.move(from, to)
+}
+
+// Prints the file, putting comments next to the nodes they are attached to.
+func (code *SourceFile) printWithComments() ([]byte, error) {
+	buffer := bytes.Buffer{}
+
+	if code.decorations.unchanged(code.file) {
+		if err := format.Node(&buffer, code.fileSet, code.file); err != nil {
+			return nil, err
+		}
+
+		return buffer.Bytes(), nil
+	}
+
+	fileSet, file := newLayout(code.decorations).print(code.file)
+
+	if err := format.Node(&buffer, fileSet, file); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// A position or a child of a node.
+type part struct {
+	// Name of the field that holds the part.
+	field string
+	// Set if the part is a position.
+	pos *token.Pos
+	// Set if the part is a node.
+	node ast.Node
+	// Index of the node in the field, if the field is a list.
+	index int
+}
+
+// Returns the positions and children of `node` in the order they appear in the source code.
+func partsOf(node ast.Node, parent ast.Node) []part {
+	if isNilNode(node) {
+		return nil
+	}
+
+	value := reflect.ValueOf(node)
+
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	parts := make([]part, 0)
+
+	switch node := node.(type) {
+	case *ast.EmptyStmt:
+		// Implicit semicolons are not tokens.
+		if node.Implicit {
+			return nil
+		}
+
+	case *ast.FuncDecl:
+		// The func keyword comes before the receiver and the name of the function
+		// but it is stored in the function type.
+		if node.Type != nil {
+			parts = append(parts, part{field: "Func", pos: &node.Type.Func})
+		}
+	}
+
+	_, funcDeclType := parent.(*ast.FuncDecl)
+
+	structValue := value.Elem()
+	structType := structValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		fieldValue := structValue.Field(i)
+
+		if isSkippedField(structType, field) || !fieldValue.CanSet() {
+			continue
+		}
+
+		switch {
+		case field.Type == posType:
+			if isIgnoredPosition(node, field.Name, funcDeclType) {
+				continue
+			}
+
+			parts = append(parts, part{field: field.Name, pos: fieldValue.Addr().Interface().(*token.Pos)})
+
+		case isNodeType(field.Type):
+			if fieldValue.IsNil() {
+				continue
+			}
+
+			parts = append(parts, part{field: field.Name, node: fieldValue.Interface().(ast.Node)})
+
+		case field.Type.Kind() == reflect.Slice && isNodeType(field.Type.Elem()):
+			for j := 0; j < fieldValue.Len(); j++ {
+				if fieldValue.Index(j).IsNil() {
+					continue
+				}
+
+				parts = append(parts, part{field: field.Name, node: fieldValue.Index(j).Interface().(ast.Node), index: j})
+			}
+		}
+	}
+
+	return parts
+}
+
+// Returns true if the position field is not the position of a token
+// or is the position of a token that is visited somewhere else.
+func isIgnoredPosition(node ast.Node, field string, funcDeclType bool) bool {
+	switch node := node.(type) {
+	case *ast.File:
+		return field == "FileStart" || field == "FileEnd"
+	case *ast.FuncType:
+		return field == "Func" && funcDeclType
+	case *ast.ChanType:
+		// <-chan starts with the arrow.
+		return field == "Arrow" && node.Dir == ast.RECV
+	}
+
+	return false
+}
@@ -0,0 +1,262 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Comments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		description string
+		code        string
+		f           func(*codemod.SourceFile)
+		expected    string
+	}{
+		{
+			description: "keeps the file as is when nothing changes",
+			code: `package main
+
+// main does things.
+func main() {
+	// x is one
+	x := 1 // trailing
+
+	/* y is two */
+	y := 2
+}
+`,
+			f: func(*codemod.SourceFile) {},
+			expected: `package main
+
+// main does things.
+func main() {
+	// x is one
+	x := 1 // trailing
+
+	/* y is two */
+	y := 2
+}
+`,
+		},
+		{
+			description: "removing a statement removes its comments",
+			code: `package main
+
+func main() {
+	// x is one
+	x := 1 // trailing
+	// y is two
+	y := 2 // trailing
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				for _, assignments := range file.FindAssignments("x") {
+					assignments[0].Remove()
+				}
+			},
+			expected: `package main
+
+func main() {
+	// y is two
+	y := 2 // trailing
+}
+`,
+		},
+		{
+			description: "comments stay with the statement when a statement is inserted before it",
+			code: `package main
+
+func main() {
+	x := 1
+
+	// y is two
+	y := 2 // trailing
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				for _, assignments := range file.FindAssignments("y") {
+					assignments[0].InsertBefore(codemod.Ast("z := 3"))
+				}
+			},
+			expected: `package main
+
+func main() {
+	x := 1
+	z := 3
+	// y is two
+	y := 2 // trailing
+}
+`,
+		},
+		{
+			description: "comments stay with the statement when a statement is inserted after it",
+			code: `package main
+
+func main() {
+	// x is one
+	x := 1 // trailing
+	// y is two
+	y := 2
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				for _, assignments := range file.FindAssignments("x") {
+					assignments[0].InsertAfter(codemod.Ast("z := 3"))
+				}
+			},
+			expected: `package main
+
+func main() {
+	// x is one
+	x := 1 // trailing
+	z := 3
+	// y is two
+	y := 2
+}
+`,
+		},
+		{
+			description: "comments move with statements",
+			code: `package main
+
+func main() {
+	// x is one
+	x := 1 // trailing x
+
+	// y is two
+	y := 2 // trailing y
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				file.TraverseAst(func(node codemod.NodeWithParent) {
+					if block, ok := node.Node.(*ast.BlockStmt); ok {
+						block.List[0], block.List[1] = block.List[1], block.List[0]
+					}
+				})
+			},
+			expected: `package main
+
+func main() {
+	// y is two
+	y := 2 // trailing y
+	// x is one
+	x := 1 // trailing x
+}
+`,
+		},
+		{
+			description: "replacing a statement keeps its comments",
+			code: `package main
+
+func main() {
+	// x is one
+	x := 1 // trailing
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				for _, assignments := range file.FindAssignments("x") {
+					assignments[0].Replace(codemod.Ast("x := 2").(ast.Stmt))
+				}
+			},
+			expected: `package main
+
+func main() {
+	// x is one
+	x := 2 // trailing
+}
+`,
+		},
+		{
+			description: "rewriting a statement keeps its comments",
+			code: `package main
+
+func main() {
+	// Prints the answer.
+	log.Printf("%d", 42) // the answer
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				codemod.Rewrite(file, "log.Printf($args...)", "logger.Infof($args...)")
+			},
+			expected: `package main
+
+func main() {
+	// Prints the answer.
+	logger.Infof("%d", 42) // the answer
+}
+`,
+		},
+		{
+			description: "comments at the end of a block stay at the end of the block",
+			code: `package main
+
+func main() {
+	x := 1
+	// TODO: use x
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				for _, assignments := range file.FindAssignments("x") {
+					assignments[0].InsertAfter(codemod.Ast("y := x"))
+				}
+			},
+			expected: `package main
+
+func main() {
+	x := 1
+	y := x
+	// TODO: use x
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			file, err := codemod.New(codemod.NewInput{SourceCode: []byte(tt.code)})
+			assert.NoError(t, err)
+
+			tt.f(file)
+
+			assert.Equal(t, tt.expected, string(file.SourceCode()))
+		})
+	}
+}
+
+func Test_SourceFile_MoveComments(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func main() {
+	// Prints the answer.
+	println(42)
+}
+`)})
+	assert.NoError(t, err)
+
+	file.TraverseAst(func(node codemod.NodeWithParent) {
+		if block, ok := node.Node.(*ast.BlockStmt); ok {
+			newStmt := codemod.Ast("fmt.Println(42)").(ast.Stmt)
+
+			file.MoveComments(block.List[0], newStmt)
+
+			block.List[0] = newStmt
+		}
+	})
+
+	expected := `package main
+
+func main() {
+	// Prints the answer.
+	fmt.Println(42)
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
@@ -0,0 +1,299 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/pkg/errors"
+)
+
+// A field of a struct type declaration.
+//
+// Fields declared together, like A, B int, are different fields
+// that share the same *ast.Field until one of them is changed.
+type StructField struct {
+	// The declaration of the field, which may declare other fields as well.
+	Node *ast.Field
+	name string
+	typ  *TypeDeclaration
+}
+
+// Returns the fields of the struct in the order they are declared in,
+// or nothing if the type is not a struct.
+func (typeDecl *TypeDeclaration) Fields() []*StructField {
+	out := make([]*StructField, 0)
+
+	structType, ok := typeDecl.Node.Type.(*ast.StructType)
+	if !ok || structType.Fields == nil {
+		return out
+	}
+
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 {
+			out = append(out, &StructField{Node: field, name: embeddedFieldName(field.Type), typ: typeDecl})
+			continue
+		}
+
+		for _, name := range field.Names {
+			out = append(out, &StructField{Node: field, name: name.Name, typ: typeDecl})
+		}
+	}
+
+	return out
+}
+
+// Returns the field called `name`. Embedded fields are called
+// by the name of their type without the package, Reader for io.Reader.
+func (typeDecl *TypeDeclaration) Field(name string) (*StructField, bool) {
+	for _, field := range typeDecl.Fields() {
+		if field.name == name {
+			return field, true
+		}
+	}
+
+	return nil, false
+}
+
+// Adds a field called `name` of type `typ`, like time.Duration, after the other fields.
+//
+// Returns error if the type is not a struct, if it already
+// has a field called `name` or if `typ` is not a valid type.
+func (typeDecl *TypeDeclaration) AddField(name, typ string) (*StructField, error) {
+	if !token.IsIdentifier(name) {
+		return nil, errors.Errorf("%q is not a valid field name", name)
+	}
+
+	return typeDecl.addField(name, typ)
+}
+
+// Adds an embedded field of type `typ`, like io.Reader or *Base, after the other fields.
+//
+// Returns error if the type is not a struct, if it already has
+// a field with the name of the embedded type or if `typ` is not a valid type.
+func (typeDecl *TypeDeclaration) AddEmbeddedField(typ string) (*StructField, error) {
+	return typeDecl.addField("", typ)
+}
+
+func (typeDecl *TypeDeclaration) addField(name, typ string) (*StructField, error) {
+	structType, ok := typeDecl.Node.Type.(*ast.StructType)
+	if !ok {
+		return nil, errors.Errorf("can't add field to %s: it is not a struct", typeDecl.Node.Name.Name)
+	}
+
+	typeExpr, err := parseType(typ)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid field type %s", typ)
+	}
+
+	field := &ast.Field{Type: typeExpr}
+
+	if name == "" {
+		name = embeddedFieldName(typeExpr)
+		if name == "" {
+			return nil, errors.Errorf("%s can't be embedded", typ)
+		}
+	} else {
+		field.Names = []*ast.Ident{{Name: name}}
+	}
+
+	if _, exists := typeDecl.Field(name); exists {
+		return nil, errors.Errorf("can't add field to %s: it already has a field called %s", typeDecl.Node.Name.Name, name)
+	}
+
+	if structType.Fields == nil {
+		structType.Fields = &ast.FieldList{}
+	}
+
+	structType.Fields.List = append(structType.Fields.List, field)
+
+	return &StructField{Node: field, name: name, typ: typeDecl}, nil
+}
+
+// Removes the field called `name`.
+//
+// Returns error if the type does not have a field called `name`.
+func (typeDecl *TypeDeclaration) RemoveField(name string) error {
+	field, ok := typeDecl.Field(name)
+	if !ok {
+		return errors.Errorf("can't remove field: %s does not have a field called %s", typeDecl.Node.Name.Name, name)
+	}
+
+	fields := typeDecl.Node.Type.(*ast.StructType).Fields
+
+	// Only the name is removed when other fields are declared together with it.
+	if len(field.Node.Names) > 1 {
+		names := make([]*ast.Ident, 0, len(field.Node.Names)-1)
+
+		for _, ident := range field.Node.Names {
+			if ident.Name != name {
+				names = append(names, ident)
+			}
+		}
+
+		field.Node.Names = names
+
+		return nil
+	}
+
+	list := make([]*ast.Field, 0, len(fields.List))
+
+	for _, existing := range fields.List {
+		if existing != field.Node {
+			list = append(list, existing)
+		}
+	}
+
+	fields.List = list
+
+	return nil
+}
+
+// Returns the name of the field, or the name of the type without the package for embedded fields.
+func (field *StructField) Name() string {
+	return field.name
+}
+
+// Returns true if the field is an embedded field, like io.Reader in struct{ io.Reader }.
+func (field *StructField) IsEmbedded() bool {
+	return len(field.Node.Names) == 0
+}
+
+// Returns the type of the field as it's written in the source code.
+func (field *StructField) Type() string {
+	return SourceCode(field.Node.Type)
+}
+
+// Changes the type of the field to `typ`, like map[string]int.
+//
+// Returns error if `typ` is not a valid type or if the field is embedded.
+func (field *StructField) SetType(typ string) error {
+	if field.IsEmbedded() {
+		return errors.Errorf("can't change the type of embedded field %s", field.name)
+	}
+
+	typeExpr, err := parseType(typ)
+	if err != nil {
+		return errors.Wrapf(err, "invalid field type %s", typ)
+	}
+
+	field.split()
+
+	field.typ.file.MoveComments(field.Node.Type, typeExpr)
+
+	field.Node.Type = typeExpr
+
+	return nil
+}
+
+// Returns the tag of the field, which is empty if the field does not have a tag.
+//
+// Returns error if the tag does not follow the key:"value" convention.
+func (field *StructField) Tag() (*Tag, error) {
+	if field.Node.Tag == nil {
+		return &Tag{}, nil
+	}
+
+	tag, err := ParseTag(field.Node.Tag.Value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "field %s", field.name)
+	}
+
+	return tag, nil
+}
+
+// Replaces the tag of the field with `tag`. The tag is removed if `tag` is empty.
+func (field *StructField) SetTag(tag *Tag) {
+	field.split()
+
+	if tag == nil || len(tag.entries) == 0 {
+		field.Node.Tag = nil
+		return
+	}
+
+	literal := &ast.BasicLit{Kind: token.STRING, Value: tag.literal()}
+
+	if field.Node.Tag != nil {
+		field.typ.file.MoveComments(field.Node.Tag, literal)
+	}
+
+	field.Node.Tag = literal
+}
+
+// Calls `f` with the tag of the field and replaces the tag with the tag changed by `f`:
+//
+//	field.UpdateTag(func(tag *codemod.Tag) {
+//		tag.Set("yaml", tag.Name("json"))
+//	})
+//
+// Returns error if the current tag does not follow the key:"value" convention.
+func (field *StructField) UpdateTag(f func(*Tag)) error {
+	tag, err := field.Tag()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f(tag)
+
+	field.SetTag(tag)
+
+	return nil
+}
+
+// Moves the field to a declaration of its own if it's declared together with other fields,
+// so it can be changed without changing the other fields.
+func (field *StructField) split() {
+	if len(field.Node.Names) < 2 {
+		return
+	}
+
+	fields := field.typ.Node.Type.(*ast.StructType).Fields
+
+	list := make([]*ast.Field, 0, len(fields.List)+len(field.Node.Names)-1)
+
+	for _, existing := range fields.List {
+		if existing != field.Node {
+			list = append(list, existing)
+			continue
+		}
+
+		// The existing declaration keeps the first name and its comments.
+		for i, name := range existing.Names {
+			declaration := existing
+
+			if i > 0 {
+				declaration = &ast.Field{Names: []*ast.Ident{name}, Type: cloneNode(existing.Type).(ast.Expr)}
+
+				if existing.Tag != nil {
+					declaration.Tag = &ast.BasicLit{Kind: token.STRING, Value: existing.Tag.Value}
+				}
+			}
+
+			if name.Name == field.name {
+				field.Node = declaration
+			}
+
+			list = append(list, declaration)
+		}
+
+		existing.Names = existing.Names[:1]
+	}
+
+	fields.List = list
+}
+
+// Returns the name of an embedded field of type `typ`, Reader for *io.Reader for example,
+// or an empty string if a field of type `typ` can't be embedded.
+func embeddedFieldName(typ ast.Expr) string {
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		return typ.Name
+	case *ast.StarExpr:
+		return embeddedFieldName(typ.X)
+	case *ast.SelectorExpr:
+		return typ.Sel.Name
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return embeddedFieldName(withoutTypeArgs(typ))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,147 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func findTypeDeclaration(t *testing.T, file *codemod.SourceFile, name string) codemod.TypeDeclaration {
+	t.Helper()
+
+	for _, typeDecl := range file.TypeDeclarations() {
+		if typeDecl.Node.Name.Name == name {
+			return typeDecl
+		}
+	}
+
+	assert.FailNow(t, "type not found: "+name)
+
+	return codemod.TypeDeclaration{}
+}
+
+func Test_ParseTag(t *testing.T) {
+	t.Parallel()
+
+	tag, err := codemod.ParseTag("`json:\"name,omitempty\" db:\"user_name\"`")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"json", "db"}, tag.Keys())
+	assert.Equal(t, "name", tag.Name("json"))
+	assert.Equal(t, []string{"omitempty"}, tag.Options("json"))
+	assert.True(t, tag.HasOption("json", "omitempty"))
+
+	value, ok := tag.Get("db")
+	assert.True(t, ok)
+	assert.Equal(t, "user_name", value)
+
+	tag.Set("yaml", tag.Name("json"))
+	tag.RemoveOption("json", "omitempty")
+	tag.AddOption("yaml", "omitempty")
+	tag.Remove("db")
+
+	assert.Equal(t, `json:"name" yaml:"name,omitempty"`, tag.String())
+
+	tag, err = codemod.ParseTag(`validate:"regexp=^\"[a-z]+\"$"`)
+	assert.NoError(t, err)
+
+	value, _ = tag.Get("validate")
+	assert.Equal(t, `regexp=^"[a-z]+"$`, value)
+	assert.Equal(t, `validate:"regexp=^\"[a-z]+\"$"`, tag.String())
+
+	for _, invalid := range []string{`json`, `json:name`, `json:"name`, `json:"name"db:"x"`, "`json:\"name\""} {
+		_, err := codemod.ParseTag(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func Test_TypeDeclaration_Fields(t *testing.T) {
+	t.Parallel()
+
+	t.Run("adds, removes and retags fields", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package config
+
+type Config struct {
+	io.Reader
+	// Deprecated: use Timeout.
+	TimeoutSeconds int    ` + "`json:\"timeout_seconds\"`" + `
+	Host, Port     string ` + "`json:\"address,omitempty\"`" + `
+	Debug          bool
+}
+`)})
+		assert.NoError(t, err)
+
+		config := findTypeDeclaration(t, file, "Config")
+
+		names := make([]string, 0)
+		for _, field := range config.Fields() {
+			names = append(names, field.Name())
+		}
+		assert.Equal(t, []string{"Reader", "TimeoutSeconds", "Host", "Port", "Debug"}, names)
+
+		reader, ok := config.Field("Reader")
+		assert.True(t, ok)
+		assert.True(t, reader.IsEmbedded())
+		assert.Equal(t, "io.Reader", reader.Type())
+
+		assert.NoError(t, config.RemoveField("TimeoutSeconds"))
+		assert.Error(t, config.RemoveField("TimeoutSeconds"))
+
+		timeout, err := config.AddField("Timeout", "time.Duration")
+		assert.NoError(t, err)
+		timeout.SetTag(&codemod.Tag{})
+		assert.NoError(t, timeout.UpdateTag(func(tag *codemod.Tag) {
+			tag.Set("json", "timeout")
+		}))
+
+		_, err = config.AddField("Debug", "bool")
+		assert.Error(t, err)
+
+		_, err = config.AddEmbeddedField("*sync.Mutex")
+		assert.NoError(t, err)
+
+		_, err = config.AddEmbeddedField("*Reader")
+		assert.Error(t, err)
+
+		port, _ := config.Field("Port")
+		assert.NoError(t, port.SetType("int"))
+		assert.NoError(t, port.UpdateTag(func(tag *codemod.Tag) {
+			tag.Set("json", "port")
+		}))
+
+		for _, field := range config.Fields() {
+			assert.NoError(t, field.UpdateTag(func(tag *codemod.Tag) {
+				if _, ok := tag.Get("json"); ok {
+					tag.Set("yaml", tag.Name("json"))
+				}
+			}))
+		}
+
+		expected := `package config
+
+type Config struct {
+	io.Reader
+	Host    string ` + "`json:\"address,omitempty\" yaml:\"address\"`" + `
+	Port    int    ` + "`json:\"port\" yaml:\"port\"`" + `
+	Debug   bool
+	Timeout time.Duration ` + "`json:\"timeout\" yaml:\"timeout\"`" + `
+	*sync.Mutex
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("returns error when the type is not a struct", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte("package a\n\ntype ID int\n")})
+		assert.NoError(t, err)
+
+		id := findTypeDeclaration(t, file, "ID")
+
+		assert.Empty(t, id.Fields())
+
+		_, err = id.AddField("Value", "int")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,557 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+)
+
+// Removes imports that are no longer used and adds imports for packages
+// that are used but not imported, in the Go files changed by codemods or replacements.
+//
+// Works like goimports without network access: packages are looked up
+// in the standard library, in the project and in the modules required
+// by the go.mod file at the root of the project that are in the module cache.
+// Blank imports, dot imports and cgo imports are never removed.
+func (project *Project) FixImports() {
+	resolver := newImportResolver(project)
+
+	for _, pkg := range project.Packages() {
+		declared := make(map[string]bool)
+
+		for _, sourceFile := range pkg.SourceFiles {
+			for name := range topLevelNames(sourceFile.file) {
+				declared[name] = true
+			}
+		}
+
+		for _, sourceFile := range pkg.SourceFiles {
+			file := project.files[project.relativePath(sourceFile.FilePath)]
+
+			if !file.changed && sourceFile.decorations.unchanged(sourceFile.file) {
+				continue
+			}
+
+			sourceFile.fixImports(resolver, declared)
+		}
+	}
+}
+
+// Removes unused imports from the file and imports the packages
+// `resolver` finds for the package names that are used but not imported.
+//
+// `declared` has the names declared at the top level of the package,
+// those are never package names.
+func (code *SourceFile) fixImports(resolver *importResolver, declared map[string]bool) {
+	used := code.packageReferences(declared)
+
+	imports := code.Imports()
+
+	imported := make(map[string]bool)
+
+	for _, i := range imports.List() {
+		if i.Name == "_" || i.Name == "." || i.Path == "C" {
+			continue
+		}
+
+		name := i.Name
+		if name == "" {
+			name = resolver.packageName(i.Path)
+		}
+
+		if _, ok := used[name]; !ok {
+			imports.RemoveNamed(i.Name, i.Path)
+			continue
+		}
+
+		imported[name] = true
+	}
+
+	for _, name := range sortedNames(used) {
+		if imported[name] {
+			continue
+		}
+
+		importPath, ok := resolver.resolve(name, used[name])
+		if !ok {
+			continue
+		}
+
+		if assumedPackageName(importPath) == name {
+			imports.Add(importPath)
+		} else {
+			imports.AddNamed(name, importPath)
+		}
+	}
+}
+
+// Returns the names declared at the top level of `file`.
+func topLevelNames(file *ast.File) map[string]bool {
+	out := make(map[string]bool)
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				out[decl.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					out[spec.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						out[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// Returns the names used as the left side of selector expressions, like fmt in fmt.Println,
+// that may refer to imported packages, and the selectors used with each name.
+//
+// Names that refer to something declared in the file, like a parameter, are not package names.
+// Types are used to find what a name refers to when the file is type checked, otherwise the names
+// resolved by the parser are used, and names in code created by codemods, that are not resolved,
+// are looked up in the scopes around them.
+func (code *SourceFile) packageReferences(declared map[string]bool) map[string]map[string]bool {
+	out := make(map[string]map[string]bool)
+
+	// The nodes from the file to the node being visited.
+	ancestors := make([]ast.Node, 0)
+
+	ast.Inspect(code.file, func(node ast.Node) bool {
+		if node == nil {
+			ancestors = ancestors[:len(ancestors)-1]
+			return true
+		}
+
+		ancestors = append(ancestors, node)
+
+		selector, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		ident, ok := selector.X.(*ast.Ident)
+		if !ok || declared[ident.Name] || !code.mayBePackageName(ident, ancestors) {
+			return true
+		}
+
+		if out[ident.Name] == nil {
+			out[ident.Name] = make(map[string]bool)
+		}
+
+		out[ident.Name][selector.Sel.Name] = true
+
+		return true
+	})
+
+	return out
+}
+
+// Returns true if `ident` does not refer to something declared in the file.
+//
+// `ancestors` are the nodes from the file to the node `ident` is in.
+func (code *SourceFile) mayBePackageName(ident *ast.Ident, ancestors []ast.Node) bool {
+	if code.pkg != nil {
+		if obj, ok := code.pkg.info.Uses[ident]; ok {
+			_, isPackage := obj.(*types.PkgName)
+			return isPackage
+		}
+	}
+
+	if ident.Obj != nil {
+		return false
+	}
+
+	for i := len(ancestors) - 2; i >= 0; i-- {
+		if declaredInScope(ancestors[i], ancestors[i+1], ident.Name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns true if `name` is declared by `scope`, a node that starts a scope,
+// before `child`, the node the name is used in.
+func declaredInScope(scope, child ast.Node, name string) bool {
+	var names []string
+
+	switch scope := scope.(type) {
+	case *ast.FuncDecl:
+		return funcNames(scope.Recv, scope.Type)[name]
+
+	case *ast.FuncLit:
+		return funcNames(nil, scope.Type)[name]
+
+	case *ast.BlockStmt:
+		names = declaredNames(stmtsBefore(scope.List, child))
+
+	case *ast.CaseClause:
+		names = declaredNames(stmtsBefore(scope.Body, child))
+
+	case *ast.CommClause:
+		names = declaredNames(stmtsBefore(scope.Body, child))
+		if scope.Comm != nil && child != scope.Comm {
+			names = append(names, declaredNames([]ast.Stmt{scope.Comm})...)
+		}
+
+	case *ast.IfStmt:
+		if scope.Init != nil && child != scope.Init {
+			names = declaredNames([]ast.Stmt{scope.Init})
+		}
+
+	case *ast.ForStmt:
+		if scope.Init != nil && child != scope.Init {
+			names = declaredNames([]ast.Stmt{scope.Init})
+		}
+
+	case *ast.SwitchStmt:
+		if scope.Init != nil && child != scope.Init {
+			names = declaredNames([]ast.Stmt{scope.Init})
+		}
+
+	case *ast.TypeSwitchStmt:
+		if scope.Init != nil && child != scope.Init {
+			names = declaredNames([]ast.Stmt{scope.Init})
+		}
+
+		if child == scope.Body {
+			names = append(names, declaredNames([]ast.Stmt{scope.Assign})...)
+		}
+
+	case *ast.RangeStmt:
+		if scope.Tok == token.DEFINE && child == scope.Body {
+			for _, expr := range []ast.Expr{scope.Key, scope.Value} {
+				if ident, ok := expr.(*ast.Ident); ok {
+					names = append(names, ident.Name)
+				}
+			}
+		}
+	}
+
+	for _, declared := range names {
+		if declared == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the statements in `list` that come before `child`, or none if `child` is not in `list`,
+// like an expression of a case clause.
+func stmtsBefore(list []ast.Stmt, child ast.Node) []ast.Stmt {
+	for i, stmt := range list {
+		if stmt == child {
+			return list[:i]
+		}
+	}
+
+	return nil
+}
+
+func sortedNames(m map[string]map[string]bool) []string {
+	out := make([]string, 0, len(m))
+
+	for key := range m {
+		out = append(out, key)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+// A package that can be imported.
+type importablePackage struct {
+	path string
+	name string
+	// Directory with the source code of the package, empty for packages in the project.
+	directory string
+	// Exported names of the package, loaded when needed.
+	exports map[string]bool
+	// Lower is preferred when more than one package has the same name.
+	priority int
+}
+
+// Finds the packages a package name refers to.
+type importResolver struct {
+	// Packages indexed by name.
+	packages map[string][]*importablePackage
+	// Packages indexed by import path.
+	paths map[string]*importablePackage
+}
+
+func newImportResolver(project *Project) *importResolver {
+	resolver := &importResolver{
+		packages: make(map[string][]*importablePackage),
+		paths:    make(map[string]*importablePackage),
+	}
+
+	for _, pkg := range standardLibrary() {
+		resolver.add(&importablePackage{path: pkg.path, name: pkg.name, directory: pkg.directory, priority: 0})
+	}
+
+	goMod, err := project.GoMod()
+	if err != nil {
+		return resolver
+	}
+
+	for _, pkg := range project.Packages() {
+		if strings.HasSuffix(pkg.Name, "_test") || pkg.Name == "main" {
+			continue
+		}
+
+		exports := make(map[string]bool)
+
+		for _, sourceFile := range pkg.SourceFiles {
+			for name := range topLevelNames(sourceFile.file) {
+				if ast.IsExported(name) {
+					exports[name] = true
+				}
+			}
+		}
+
+		resolver.add(&importablePackage{
+			path:     path.Join(goMod.ModulePath(), filepath.ToSlash(pkg.Directory)),
+			name:     pkg.Name,
+			exports:  exports,
+			priority: 1,
+		})
+	}
+
+	for _, require := range goMod.Requires() {
+		directory, err := moduleCacheDirectory(require)
+		if err != nil {
+			continue
+		}
+
+		for _, pkg := range packagesIn(directory, require.Path) {
+			resolver.add(&importablePackage{path: pkg.path, name: pkg.name, directory: pkg.directory, priority: 2})
+		}
+	}
+
+	return resolver
+}
+
+func (resolver *importResolver) add(pkg *importablePackage) {
+	if _, ok := resolver.paths[pkg.path]; ok {
+		return
+	}
+
+	resolver.paths[pkg.path] = pkg
+	resolver.packages[pkg.name] = append(resolver.packages[pkg.name], pkg)
+}
+
+// Returns the name of the package at `importPath`,
+// guessing it from the import path if the package is unknown.
+func (resolver *importResolver) packageName(importPath string) string {
+	if pkg, ok := resolver.paths[importPath]; ok {
+		return pkg.name
+	}
+
+	return assumedPackageName(importPath)
+}
+
+// Returns the import path of the package called `name` that exports every name in `selectors`
+// and false if there's no such package.
+func (resolver *importResolver) resolve(name string, selectors map[string]bool) (string, bool) {
+	candidates := make([]*importablePackage, 0)
+
+	for _, pkg := range resolver.packages[name] {
+		if pkg.exportsAll(selectors) {
+			candidates = append(candidates, pkg)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].priority != candidates[j].priority {
+			return candidates[i].priority < candidates[j].priority
+		}
+
+		if len(candidates[i].path) != len(candidates[j].path) {
+			return len(candidates[i].path) < len(candidates[j].path)
+		}
+
+		return candidates[i].path < candidates[j].path
+	})
+
+	return candidates[0].path, true
+}
+
+func (pkg *importablePackage) exportsAll(selectors map[string]bool) bool {
+	if pkg.exports == nil {
+		pkg.exports = exportedNames(pkg.directory)
+	}
+
+	for selector := range selectors {
+		if !pkg.exports[selector] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the exported names declared at the top level
+// of the Go files in `directory`, test files are ignored.
+func exportedNames(directory string) map[string]bool {
+	out := make(map[string]bool)
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return out
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isGoFile(entry.Name()) || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(directory, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		for name := range topLevelNames(file) {
+			if ast.IsExported(name) {
+				out[name] = true
+			}
+		}
+	}
+
+	return out
+}
+
+var (
+	standardLibraryOnce     sync.Once
+	standardLibraryPackages []importablePackage
+)
+
+// Returns the packages in the standard library that can be imported.
+func standardLibrary() []importablePackage {
+	standardLibraryOnce.Do(func() {
+		standardLibraryPackages = packagesIn(filepath.Join(build.Default.GOROOT, "src"), "")
+	})
+
+	return standardLibraryPackages
+}
+
+// Returns the packages that can be imported from `directory`,
+// where the import path of the package in `directory` is `importPath`.
+//
+// Internal packages, commands, test data and vendored packages are skipped.
+func packagesIn(directory, importPath string) []importablePackage {
+	out := make([]importablePackage, 0)
+
+	_ = filepath.WalkDir(directory, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+
+		switch entry.Name() {
+		case "internal", "testdata", "vendor", "cmd":
+			if current != directory {
+				return filepath.SkipDir
+			}
+		}
+
+		if strings.HasPrefix(entry.Name(), ".") || strings.HasPrefix(entry.Name(), "_") {
+			return filepath.SkipDir
+		}
+
+		relativePath, err := filepath.Rel(directory, current)
+		if err != nil {
+			return nil
+		}
+
+		name, ok := packageNameIn(current)
+		if !ok || name == "main" {
+			return nil
+		}
+
+		out = append(out, importablePackage{
+			path:      strings.TrimPrefix(path.Join(importPath, filepath.ToSlash(relativePath)), "/"),
+			name:      name,
+			directory: current,
+		})
+
+		return nil
+	})
+
+	return out
+}
+
+// Returns the package name in the package clause of the first Go file in `directory`
+// that is not a test file, and false if there's no such file.
+func packageNameIn(directory string) (string, bool) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return "", false
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isGoFile(entry.Name()) || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(directory, entry.Name()), nil, parser.PackageClauseOnly)
+		if err != nil || file.Name.Name == "documentation" {
+			continue
+		}
+
+		return file.Name.Name, true
+	}
+
+	return "", false
+}
+
+// Returns the directory the module cache keeps `version` of the module at `path` in.
+func moduleCacheDirectory(version ModuleVersion) (string, error) {
+	cache := os.Getenv("GOMODCACHE")
+	if cache == "" {
+		cache = filepath.Join(strings.Split(build.Default.GOPATH, string(filepath.ListSeparator))[0], "pkg", "mod")
+	}
+
+	escapedPath, err := module.EscapePath(version.Path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	escapedVersion, err := module.EscapeVersion(version.Version)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	directory := filepath.Join(cache, escapedPath+"@"+escapedVersion)
+
+	if _, err := os.Stat(directory); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return directory, nil
+}
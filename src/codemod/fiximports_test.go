@@ -0,0 +1,166 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Project_FixImports(t *testing.T) {
+	t.Parallel()
+
+	t.Run("removes unused imports and adds missing imports", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{
+			"go.mod": "module example.com/project\n\nrequire github.com/pkg/errors v0.9.1\n",
+			"main.go": `package main
+
+import (
+	"github.com/pkg/errors"
+)
+
+func find(id int) error {
+	return errors.Wrapf(errNotFound, "finding %d", id)
+}
+`,
+			"errors.go": `package main
+
+import "errors"
+
+var errNotFound = errors.New("not found")
+`,
+		})
+
+		for _, file := range project.SourceFiles() {
+			codemod.Rewrite(file, "errors.Wrapf($err, $format, $args...)", `fmt.Errorf($format + ": %w", $args..., $err)`)
+		}
+
+		project.FixImports()
+
+		assert.NoError(t, project.Save(codemod.SaveOptions{}))
+
+		expected := `package main
+
+import "fmt"
+
+func find(id int) error {
+	return fmt.Errorf("finding %d: %w", id, errNotFound)
+}
+`
+
+		assert.Equal(t, expected, readProjectFile(t, project, "main.go"))
+	})
+
+	t.Run("imports packages of the project", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{
+			"go.mod": "module example.com/project\n",
+			"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(lookup())
+}
+`,
+			"users/users.go": "package users\n\nfunc Find() string { return \"\" }\n",
+		})
+
+		codemod.Rewrite(project.SourceFiles()[0], "lookup()", "users.Find()")
+
+		project.FixImports()
+
+		expected := `package main
+
+import (
+	"fmt"
+
+	"example.com/project/users"
+)
+
+func main() {
+	fmt.Println(users.Find())
+}
+`
+
+		contents, err := project.ReadFile("main.go")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(contents))
+	})
+
+	t.Run("does not change names that are not packages", func(t *testing.T) {
+		sourceCode := `package main
+
+import "errors"
+
+func wrap(err error) error {
+	return err
+}
+
+func check(errors []error) error {
+	return wrap(errors[0])
+}
+
+var errFailed = errors.New("failed")
+`
+
+		project := newTestProject(t, map[string]string{"main.go": sourceCode})
+
+		file := project.SourceFiles()[0]
+
+		codemod.Rewrite(file, "wrap($err)", "context.Wrap($err)")
+
+		project.FixImports()
+
+		imports := file.Imports()
+
+		assert.Equal(t, []string{"errors"}, imports.Paths())
+	})
+
+	t.Run("imports packages whose names are only declared as locals in other functions", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{
+			"main.go": `package main
+
+func g(url string) {
+	println(url)
+}
+
+func f() {
+	parse()
+}
+`,
+		})
+
+		file := project.SourceFiles()[0]
+
+		codemod.Rewrite(file, "parse()", `url.Parse("x")`)
+
+		project.FixImports()
+
+		expected := `package main
+
+import "net/url"
+
+func g(url string) {
+	println(url)
+}
+
+func f() {
+	url.Parse("x")
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("does not change files that were not changed", func(t *testing.T) {
+		sourceCode := "package main\n\nimport \"fmt\"\n"
+
+		project := newTestProject(t, map[string]string{"main.go": sourceCode})
+
+		project.FixImports()
+
+		contents, err := project.ReadFile("main.go")
+		assert.NoError(t, err)
+		assert.Equal(t, sourceCode, string(contents))
+	})
+}
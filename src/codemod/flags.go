@@ -0,0 +1,592 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Removes the feature flag read by `accessor`, like flags.IsEnabled("new_checkout"),
+// as if it always evaluated to `enabled`.
+//
+// The accessor is replaced by true or false and the conditions that use it are folded:
+// flags.IsEnabled("new_checkout") && user.Beta becomes user.Beta when the flag is enabled
+// and false when it's not. If statements whose condition becomes constant are replaced by the branch
+// that would run, including else if chains, and the other branches are removed.
+// Operands that may have side effects, like f() in f() || flag, are never removed.
+//
+// Variables and imports that were used before the flag was removed and are not used anymore are removed.
+// Calls in the values of removed variables are kept, so v := f() becomes f().
+//
+// Returns error if `accessor` is not a valid expression.
+func (code *SourceFile) RemoveFeatureFlag(accessor string, enabled bool) error {
+	accessorExpr, err := parser.ParseExpr(accessor)
+	if err != nil {
+		return errors.Wrapf(err, "invalid flag accessor %s", accessor)
+	}
+
+	removal := &flagRemoval{
+		code:      code,
+		accessor:  accessorExpr,
+		source:    SourceCode(accessorExpr),
+		constants: make(map[ast.Expr]bool),
+	}
+
+	variables := code.usedVariables()
+	packages := code.packageReferences(topLevelNames(code.file))
+
+	rewriteTree(code.file, func(node ast.Node) ast.Node {
+		expr, ok := node.(ast.Expr)
+		if !ok {
+			return node
+		}
+
+		if removal.isAccessor(expr) {
+			return removal.constant(enabled)
+		}
+
+		return removal.fold(expr)
+	})
+
+	removal.foldBlocks(code.file)
+
+	code.removeUnusedVariables(variables)
+	code.removeUnusedImports(packages)
+
+	return nil
+}
+
+type flagRemoval struct {
+	code     *SourceFile
+	accessor ast.Expr
+	// The accessor as it's printed, used to compare expressions with it.
+	source string
+	// The expressions known to evaluate to a constant because of the flag and their values.
+	// Expressions that were constant before the flag was removed, like true in for true {}, are not included.
+	constants map[ast.Expr]bool
+}
+
+// Returns true if `expr` reads the flag.
+func (removal *flagRemoval) isAccessor(expr ast.Expr) bool {
+	return reflect.TypeOf(expr) == reflect.TypeOf(removal.accessor) && SourceCode(expr) == removal.source
+}
+
+// Returns a new true or false identifier.
+func (removal *flagRemoval) constant(value bool) ast.Expr {
+	expr := ast.NewIdent(strconv.FormatBool(value))
+
+	removal.constants[expr] = value
+
+	return expr
+}
+
+// Returns `expr` simplified if one of its operands is constant because of the flag.
+// Operands have already been simplified.
+func (removal *flagRemoval) fold(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		if _, ok := removal.constants[expr.X]; ok {
+			return expr.X
+		}
+
+	case *ast.UnaryExpr:
+		if value, ok := removal.constants[expr.X]; ok && expr.Op == token.NOT {
+			return removal.constant(!value)
+		}
+
+	case *ast.BinaryExpr:
+		x, xIsConstant := removal.constants[expr.X]
+		y, yIsConstant := removal.constants[expr.Y]
+
+		switch {
+		case xIsConstant && yIsConstant && (expr.Op == token.EQL || expr.Op == token.NEQ):
+			return removal.constant((x == y) == (expr.Op == token.EQL))
+
+		case expr.Op == token.LAND && xIsConstant:
+			if !x {
+				return removal.constant(false)
+			}
+			return expr.Y
+
+		case expr.Op == token.LAND && yIsConstant:
+			if y {
+				return expr.X
+			}
+			if !hasSideEffects(expr.X) {
+				return removal.constant(false)
+			}
+
+		case expr.Op == token.LOR && xIsConstant:
+			if x {
+				return removal.constant(true)
+			}
+			return expr.Y
+
+		case expr.Op == token.LOR && yIsConstant:
+			if !y {
+				return expr.X
+			}
+			if !hasSideEffects(expr.X) {
+				return removal.constant(true)
+			}
+		}
+	}
+
+	return expr
+}
+
+// Replaces the if statements in `node` whose condition is constant by the branch that would run.
+func (removal *flagRemoval) foldBlocks(node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncDecl:
+			if node.Body != nil {
+				node.Body.List = removal.foldStmts(node.Body.List, funcNames(node.Recv, node.Type))
+			}
+			return false
+
+		case *ast.FuncLit:
+			node.Body.List = removal.foldStmts(node.Body.List, funcNames(nil, node.Type))
+			return false
+
+		case *ast.BlockStmt:
+			node.List = removal.foldStmts(node.List, nil)
+			return false
+
+		case *ast.CaseClause:
+			node.Body = removal.foldStmts(node.Body, nil)
+			return false
+
+		case *ast.CommClause:
+			node.Body = removal.foldStmts(node.Body, nil)
+			return false
+		}
+
+		return true
+	})
+}
+
+// Returns `list` with the if statements whose condition is constant replaced by the branch that would run.
+//
+// `outer` has the names declared in the same scope as the statements, like the parameters of a function.
+func (removal *flagRemoval) foldStmts(list []ast.Stmt, outer map[string]bool) []ast.Stmt {
+	for i := 0; i < len(list); {
+		ifStmt, ok := list[i].(*ast.IfStmt)
+		if !ok {
+			removal.foldBlocks(list[i])
+			i++
+			continue
+		}
+
+		ifStmt.Else = removal.foldElse(ifStmt.Else)
+
+		value, ok := removal.constants[ifStmt.Cond]
+		if !ok {
+			removal.foldBlocks(ifStmt)
+			i++
+			continue
+		}
+
+		// The statements that replace the if statement are visited next,
+		// they may have if statements that can be folded as well.
+		list = removal.code.inlineBlock(list, i, ifStmt, keptBranch(ifStmt, value), outer)
+	}
+
+	return list
+}
+
+// Returns the else branch `stmt` with the else if statements whose condition is constant
+// replaced by the branch that would run.
+func (removal *flagRemoval) foldElse(stmt ast.Stmt) ast.Stmt {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok {
+		return stmt
+	}
+
+	ifStmt.Else = removal.foldElse(ifStmt.Else)
+
+	value, ok := removal.constants[ifStmt.Cond]
+	if !ok {
+		return ifStmt
+	}
+
+	block := keptBranch(ifStmt, value)
+
+	if len(block.List) == 0 {
+		return nil
+	}
+
+	if next, ok := block.List[0].(*ast.IfStmt); ok && len(block.List) == 1 {
+		removal.code.MoveComments(ifStmt, next)
+		return next
+	}
+
+	removal.code.MoveComments(ifStmt, block)
+
+	return block
+}
+
+// Returns the statements that run when the condition of `stmt` evaluates to `value`:
+// the init statement followed by the body or by the else branch.
+func keptBranch(stmt *ast.IfStmt, value bool) *ast.BlockStmt {
+	var branch *ast.BlockStmt
+
+	switch {
+	case value:
+		branch = stmt.Body
+	case stmt.Else == nil:
+		branch = &ast.BlockStmt{}
+	default:
+		if block, ok := stmt.Else.(*ast.BlockStmt); ok {
+			branch = block
+		} else {
+			branch = &ast.BlockStmt{List: []ast.Stmt{stmt.Else}}
+		}
+	}
+
+	if stmt.Init == nil {
+		return branch
+	}
+
+	return &ast.BlockStmt{List: append([]ast.Stmt{stmt.Init}, branch.List...)}
+}
+
+// Returns `list` with the statement `stmt` at `index` replaced by the statements in `block`.
+//
+// The block itself replaces the statement when the statements in it declare names
+// that are used by the other statements in the list or that are in `outer`,
+// since the names would refer to something else or be declared twice.
+func (code *SourceFile) inlineBlock(list []ast.Stmt, index int, stmt ast.Stmt, block *ast.BlockStmt, outer map[string]bool) []ast.Stmt {
+	used := make(map[string]bool)
+
+	for name := range outer {
+		used[name] = true
+	}
+
+	for i, other := range list {
+		if i == index {
+			continue
+		}
+
+		ast.Inspect(other, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+			return true
+		})
+	}
+
+	for _, name := range declaredNames(block.List) {
+		if used[name] {
+			code.MoveComments(stmt, block)
+			list[index] = block
+			return list
+		}
+	}
+
+	if len(block.List) > 0 {
+		code.MoveComments(stmt, block.List[0])
+	}
+
+	out := make([]ast.Stmt, 0, len(list)+len(block.List))
+	out = append(out, list[:index]...)
+	out = append(out, block.List...)
+	out = append(out, list[index+1:]...)
+
+	return out
+}
+
+// Returns the names the statements in `list` declare in the scope they are in.
+func declaredNames(list []ast.Stmt) []string {
+	out := make([]string, 0)
+
+	for _, stmt := range list {
+		switch stmt := stmt.(type) {
+		case *ast.AssignStmt:
+			if stmt.Tok != token.DEFINE {
+				continue
+			}
+
+			for _, expr := range stmt.Lhs {
+				if ident, ok := expr.(*ast.Ident); ok && ident.Name != "_" {
+					out = append(out, ident.Name)
+				}
+			}
+
+		case *ast.DeclStmt:
+			genDecl, ok := stmt.Decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						out = append(out, name.Name)
+					}
+				case *ast.TypeSpec:
+					out = append(out, spec.Name.Name)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// Returns the names of the receiver, type parameters, parameters and results of a function.
+func funcNames(recv *ast.FieldList, typ *ast.FuncType) map[string]bool {
+	out := make(map[string]bool)
+
+	for _, list := range []*ast.FieldList{recv, typ.TypeParams, typ.Params, typ.Results} {
+		for _, param := range flattenParams(list) {
+			if param.name != nil {
+				out[param.name.Name] = true
+			}
+		}
+	}
+
+	return out
+}
+
+// A variable declared inside a function.
+type localVariable struct {
+	name *ast.Ident
+	// The *ast.AssignStmt or *ast.DeclStmt that declares the variable.
+	stmt ast.Stmt
+	// The top level declaration the variable is in.
+	decl ast.Decl
+}
+
+// Returns the variables declared inside functions that are used.
+func (code *SourceFile) usedVariables() []localVariable {
+	out := make([]localVariable, 0)
+
+	for _, decl := range code.file.Decls {
+		ast.Inspect(decl, func(node ast.Node) bool {
+			stmt, ok := node.(ast.Stmt)
+			if !ok {
+				return true
+			}
+
+			for _, name := range code.declaredVariables(stmt) {
+				if len(code.referencesTo(name, decl)) > 0 {
+					out = append(out, localVariable{name: name, stmt: stmt, decl: decl})
+				}
+			}
+
+			return true
+		})
+	}
+
+	return out
+}
+
+// Returns the identifiers of the variables `stmt` declares,
+// variables that are assigned to but were declared elsewhere are not included.
+func (code *SourceFile) declaredVariables(stmt ast.Stmt) []*ast.Ident {
+	out := make([]*ast.Ident, 0)
+
+	declares := func(ident *ast.Ident, decl ast.Node) bool {
+		if ident.Name == "_" {
+			return false
+		}
+
+		if code.pkg != nil {
+			return code.pkg.info.Defs[ident] != nil
+		}
+
+		return ident.Obj != nil && ident.Obj.Decl == decl
+	}
+
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		if stmt.Tok != token.DEFINE {
+			break
+		}
+
+		for _, expr := range stmt.Lhs {
+			if ident, ok := expr.(*ast.Ident); ok && declares(ident, stmt) {
+				out = append(out, ident)
+			}
+		}
+
+	case *ast.DeclStmt:
+		genDecl, ok := stmt.Decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			break
+		}
+
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+
+			for _, name := range valueSpec.Names {
+				if declares(name, valueSpec) {
+					out = append(out, name)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// Removes the variables in `variables` that are not used anymore.
+func (code *SourceFile) removeUnusedVariables(variables []localVariable) {
+	removed := make(map[*ast.Ident]bool)
+
+	// Removing a variable may leave variables used in its value unused.
+	for changed := true; changed; {
+		changed = false
+
+		for _, variable := range variables {
+			if removed[variable.name] || len(code.referencesTo(variable.name, variable.decl)) > 0 {
+				continue
+			}
+
+			removed[variable.name] = true
+			changed = true
+
+			variable.name.Name = "_"
+
+			code.removeBlankDeclaration(variable.stmt)
+		}
+	}
+}
+
+// Removes the declaration `stmt` if it only declares blank identifiers, like _ := f(),
+// keeping the values that may have side effects.
+func (code *SourceFile) removeBlankDeclaration(stmt ast.Stmt) {
+	cursor := code.cursorOf(stmt)
+
+	// The declaration was in a branch that was removed.
+	if cursor == nil {
+		return
+	}
+
+	switch stmt := stmt.(type) {
+	case *ast.AssignStmt:
+		if len(code.declaredVariables(stmt)) > 0 {
+			return
+		}
+
+		if !isBlank(stmt.Lhs) {
+			// Variables that were declared before are assigned to.
+			stmt.Tok = token.ASSIGN
+			return
+		}
+
+		code.replaceWithSideEffects(cursor, stmt.Rhs, func() { stmt.Tok = token.ASSIGN })
+
+	case *ast.DeclStmt:
+		genDecl := stmt.Decl.(*ast.GenDecl)
+
+		specs := make([]ast.Spec, 0, len(genDecl.Specs))
+		values := make([]ast.Expr, 0)
+
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+
+			if !isBlank(identsToExprs(valueSpec.Names)) || anyHasSideEffects(valueSpec.Values) {
+				specs = append(specs, spec)
+				values = append(values, valueSpec.Values...)
+			}
+		}
+
+		genDecl.Specs = specs
+
+		if len(specs) > 1 || len(specs) == 1 && !isBlank(identsToExprs(specs[0].(*ast.ValueSpec).Names)) {
+			return
+		}
+
+		// var _ = f() is valid, but f() is what was meant.
+		code.replaceWithSideEffects(cursor, values, func() {})
+	}
+}
+
+// Replaces the statement `cursor` points to by the expressions in `values` that may have side effects:
+// the statement is removed if there are none and replaced by the expression if there's only one call or receive.
+// `keep` is called when the statement has to be kept.
+func (code *SourceFile) replaceWithSideEffects(cursor *Cursor, values []ast.Expr, keep func()) {
+	if !anyHasSideEffects(values) {
+		_ = removeStmt(cursor)
+		return
+	}
+
+	if len(values) == 1 {
+		switch value := unparen(values[0]).(type) {
+		case *ast.CallExpr:
+			_ = cursor.Replace(&ast.ExprStmt{X: value})
+			return
+		case *ast.UnaryExpr:
+			if value.Op == token.ARROW {
+				_ = cursor.Replace(&ast.ExprStmt{X: value})
+				return
+			}
+		}
+	}
+
+	keep()
+}
+
+func anyHasSideEffects(exprs []ast.Expr) bool {
+	for _, expr := range exprs {
+		if hasSideEffects(expr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if every expression in `exprs` is the blank identifier.
+func isBlank(exprs []ast.Expr) bool {
+	for _, expr := range exprs {
+		if ident, ok := expr.(*ast.Ident); !ok || ident.Name != "_" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func identsToExprs(idents []*ast.Ident) []ast.Expr {
+	out := make([]ast.Expr, 0, len(idents))
+
+	for _, ident := range idents {
+		out = append(out, ident)
+	}
+
+	return out
+}
+
+// Removes the imports of the packages in `packages`, the package references found before
+// the file was changed, that are not referenced anymore.
+//
+// Blank and dot imports are never removed.
+func (code *SourceFile) removeUnusedImports(packages map[string]map[string]bool) {
+	used := code.packageReferences(topLevelNames(code.file))
+
+	imports := code.Imports()
+
+	for _, i := range imports.List() {
+		if i.Name == "_" || i.Name == "." {
+			continue
+		}
+
+		name, _ := imports.LocalName(i.Path)
+
+		if _, wasUsed := packages[name]; !wasUsed {
+			continue
+		}
+
+		if _, ok := used[name]; !ok {
+			imports.RemoveNamed(i.Name, i.Path)
+		}
+	}
+}
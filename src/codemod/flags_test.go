@@ -0,0 +1,174 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SourceFile_RemoveFeatureFlag(t *testing.T) {
+	t.Parallel()
+
+	sourceCode := `package checkout
+
+import (
+	"fmt"
+	"log"
+
+	"example.com/project/flags"
+	"example.com/project/legacy"
+)
+
+func Checkout(user User, cart Cart) error {
+	client := legacy.NewClient()
+	total := cart.Total()
+
+	// Remove once the new checkout is rolled out.
+	if flags.IsEnabled("new_checkout") && user.Beta {
+		log.Println("new checkout")
+		return newCheckout(cart)
+	} else if !flags.IsEnabled("new_checkout") {
+		return client.Charge(total)
+	} else {
+		fmt.Println("beta users only")
+	}
+
+	if (flags.IsEnabled("new_checkout")) {
+		fmt.Println("done")
+	}
+
+	if debug || flags.IsEnabled("new_checkout") {
+		log.Println(cart)
+	}
+
+	if flags.IsEnabled("other") {
+		return nil
+	}
+
+	return nil
+}
+`
+
+	t.Run("flag is enabled", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(sourceCode)})
+		assert.NoError(t, err)
+
+		assert.NoError(t, file.RemoveFeatureFlag(`flags.IsEnabled("new_checkout")`, true))
+
+		expected := `package checkout
+
+import (
+	"fmt"
+	"log"
+
+	"example.com/project/flags"
+	"example.com/project/legacy"
+)
+
+func Checkout(user User, cart Cart) error {
+	legacy.NewClient()
+	cart.Total()
+
+	// Remove once the new checkout is rolled out.
+	if user.Beta {
+		log.Println("new checkout")
+		return newCheckout(cart)
+	} else {
+		fmt.Println("beta users only")
+	}
+
+	fmt.Println("done")
+
+	log.Println(cart)
+
+	if flags.IsEnabled("other") {
+		return nil
+	}
+
+	return nil
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("flag is disabled", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(sourceCode)})
+		assert.NoError(t, err)
+
+		assert.NoError(t, file.RemoveFeatureFlag(`flags.IsEnabled("new_checkout")`, false))
+
+		expected := `package checkout
+
+import (
+	"log"
+
+	"example.com/project/flags"
+	"example.com/project/legacy"
+)
+
+func Checkout(user User, cart Cart) error {
+	client := legacy.NewClient()
+	total := cart.Total()
+
+	// Remove once the new checkout is rolled out.
+	return client.Charge(total)
+
+	if debug {
+		log.Println(cart)
+	}
+
+	if flags.IsEnabled("other") {
+		return nil
+	}
+
+	return nil
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("keeps the branch in a block when its declarations would clash", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import "example.com/project/flags"
+
+func main() {
+	if flags.Enabled {
+		x := 1
+		println(x)
+	}
+
+	x := 2
+	println(x)
+}
+`)})
+		assert.NoError(t, err)
+
+		assert.NoError(t, file.RemoveFeatureFlag("flags.Enabled", true))
+
+		expected := `package main
+
+func main() {
+	{
+		x := 1
+		println(x)
+	}
+
+	x := 2
+	println(x)
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("returns error if the accessor is not an expression", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte("package main\n")})
+		assert.NoError(t, err)
+
+		assert.Error(t, file.RemoveFeatureFlag("flags.IsEnabled(", true))
+	})
+}
@@ -0,0 +1,389 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/pkg/errors"
+)
+
+// A type parameter, like K comparable in [K comparable, V any].
+type TypeParam struct {
+	Name string
+	// The constraint as it's written in the source code, like any or ~int | ~string.
+	// Empty for the type parameters of receivers, like K in func (m *Map[K, V]) Get().
+	Constraint string
+}
+
+// Returns the type parameters of the function, like T in func Map[T any](), in the order they are declared in.
+func (function *Function) TypeParams() []TypeParam {
+	return typeParamsOf(function.Node.Type.TypeParams)
+}
+
+// Adds the type parameter `name` with the constraint `constraint`, like any, after the other type parameters.
+//
+// Calls that pass type arguments explicitly are not changed.
+//
+// Returns error if the function already has a type parameter called `name`
+// or if `constraint` is not valid.
+func (function *Function) AddTypeParam(name, constraint string) error {
+	if function.Node.Recv != nil {
+		return errors.Errorf("can't add type parameter to method %s: methods can't have type parameters", function.Node.Name.Name)
+	}
+
+	_, err := addTypeParam(&function.Node.Type.TypeParams, name, constraint)
+
+	return errors.WithStack(err)
+}
+
+// Removes the type parameter `name`.
+//
+// Calls that pass type arguments explicitly are not changed.
+//
+// Returns error if the function does not have the type parameter or if the function uses it.
+func (function *Function) RemoveTypeParam(name string) error {
+	ident := typeParamIdent(function.Node.Type.TypeParams, name)
+	if ident == nil {
+		return errors.Errorf("can't remove type parameter: %s does not have a type parameter called %s", function.Node.Name.Name, name)
+	}
+
+	if uses := function.file.referencesTo(ident, function.Node); len(uses) > 0 {
+		return errors.Errorf("can't remove type parameter %s, it is used at %s", name, function.file.position(uses[0].Pos()))
+	}
+
+	removeTypeParam(&function.Node.Type.TypeParams, name)
+
+	return nil
+}
+
+// Changes the constraint of the type parameter `name` to `constraint`, like comparable.
+//
+// Returns error if the function does not have the type parameter or if `constraint` is not valid.
+func (function *Function) SetTypeParamConstraint(name, constraint string) error {
+	return errors.WithStack(setTypeParamConstraint(function.Node.Type.TypeParams, function.Node.Name.Name, name, constraint))
+}
+
+// Returns the type parameters of the type, like K and V in type Map[K comparable, V any] struct{},
+// in the order they are declared in.
+func (typeDecl *TypeDeclaration) TypeParams() []TypeParam {
+	return typeParamsOf(typeDecl.Node.TypeParams)
+}
+
+// Adds the type parameter `name` with the constraint `constraint`, like any, after the other type parameters.
+// The receivers of the methods of the type in the file get the type parameter as well.
+//
+// Instantiations of the type, like Map[string, int], are not changed.
+//
+// Returns error if the type already has a type parameter called `name`
+// or if `constraint` is not valid.
+func (typeDecl *TypeDeclaration) AddTypeParam(name, constraint string) error {
+	index, err := addTypeParam(&typeDecl.Node.TypeParams, name, constraint)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, decl := range typeDecl.methodDecls() {
+		recv := decl.Recv.List[0]
+
+		recv.Type = withTypeArgs(recv.Type, func(args []ast.Expr) []ast.Expr {
+			if len(args) != index {
+				return args
+			}
+
+			return append(args, ast.NewIdent(name))
+		})
+	}
+
+	return nil
+}
+
+// Removes the type parameter `name` from the type and from the receivers of the methods of the type in the file.
+//
+// Instantiations of the type, like Map[string, int], are not changed.
+//
+// Returns error if the type does not have the type parameter
+// or if the type or one of its methods uses it.
+func (typeDecl *TypeDeclaration) RemoveTypeParam(name string) error {
+	ident := typeParamIdent(typeDecl.Node.TypeParams, name)
+	if ident == nil {
+		return errors.Errorf("can't remove type parameter: %s does not have a type parameter called %s", typeDecl.Node.Name.Name, name)
+	}
+
+	code := typeDecl.file
+
+	if uses := code.referencesTo(ident, typeDecl.Node); len(uses) > 0 {
+		return errors.Errorf("can't remove type parameter %s, it is used at %s", name, code.position(uses[0].Pos()))
+	}
+
+	index := 0
+	for i, param := range typeDecl.TypeParams() {
+		if param.Name == name {
+			index = i
+		}
+	}
+
+	decls := typeDecl.methodDecls()
+
+	// Methods may call the type parameter something else, like func (m Map[A, B]) Get().
+	for _, decl := range decls {
+		args := typeArgs(receiverType(decl))
+		if index >= len(args) {
+			continue
+		}
+
+		recvIdent, ok := args[index].(*ast.Ident)
+		if !ok {
+			continue
+		}
+
+		if uses := code.referencesTo(recvIdent, decl); len(uses) > 0 {
+			return errors.Errorf("can't remove type parameter %s, method %s uses it at %s", name, decl.Name.Name, code.position(uses[0].Pos()))
+		}
+	}
+
+	removeTypeParam(&typeDecl.Node.TypeParams, name)
+
+	for _, decl := range decls {
+		recv := decl.Recv.List[0]
+
+		recv.Type = withTypeArgs(recv.Type, func(args []ast.Expr) []ast.Expr {
+			if index >= len(args) {
+				return args
+			}
+
+			return append(args[:index:index], args[index+1:]...)
+		})
+	}
+
+	return nil
+}
+
+// Changes the constraint of the type parameter `name` to `constraint`, like comparable.
+//
+// Returns error if the type does not have the type parameter or if `constraint` is not valid.
+func (typeDecl *TypeDeclaration) SetTypeParamConstraint(name, constraint string) error {
+	return errors.WithStack(setTypeParamConstraint(typeDecl.Node.TypeParams, typeDecl.Node.Name.Name, name, constraint))
+}
+
+// Returns the type parameters of the method's receiver, like K and V in func (m *Map[K, V]) Get(),
+// which don't have constraints. Interface methods don't have type parameters.
+//
+// The type parameters of methods are changed with the type they belong to, see TypeDeclaration.AddTypeParam.
+func (method *Method) TypeParams() []TypeParam {
+	out := make([]TypeParam, 0)
+
+	decl, ok := method.Node.(*ast.FuncDecl)
+	if !ok {
+		return out
+	}
+
+	for _, arg := range typeArgs(receiverType(decl)) {
+		if ident, ok := arg.(*ast.Ident); ok {
+			out = append(out, TypeParam{Name: ident.Name})
+		}
+	}
+
+	return out
+}
+
+// Returns the explicit type arguments of the call, like int and string in Convert[int, string](x).
+//
+// Type arguments that are inferred are not returned. When the source file has not been
+// type checked, calls of functions stored in slices or maps, like handlers[i](), can't be told apart
+// from instantiations and their index is returned.
+func (call *FunctionCall) TypeArgs() []ast.Expr {
+	fun := unparen(call.Node.Fun)
+
+	if index, ok := fun.(*ast.IndexExpr); ok && call.file != nil && call.file.pkg != nil {
+		if typeAndValue, ok := call.file.pkg.info.Types[index.Index]; ok && !typeAndValue.IsType() {
+			return make([]ast.Expr, 0)
+		}
+	}
+
+	return typeArgs(fun)
+}
+
+// Returns `expr` without its type arguments, Map for Map[string, int].
+func withoutTypeArgs(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.IndexExpr:
+		return expr.X
+	case *ast.IndexListExpr:
+		return expr.X
+	default:
+		return expr
+	}
+}
+
+// Returns the type arguments of `expr`, string and int for Map[string, int].
+func typeArgs(expr ast.Expr) []ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.IndexExpr:
+		return []ast.Expr{expr.Index}
+	case *ast.IndexListExpr:
+		return expr.Indices
+	default:
+		return make([]ast.Expr, 0)
+	}
+}
+
+// Returns the type `typ`, like T, *T[K] or T[K, V], with the type arguments returned by `f`.
+func withTypeArgs(typ ast.Expr, f func([]ast.Expr) []ast.Expr) ast.Expr {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		star.X = withTypeArgs(star.X, f)
+		return star
+	}
+
+	args := f(typeArgs(typ))
+
+	switch len(args) {
+	case 0:
+		return withoutTypeArgs(typ)
+	case 1:
+		return &ast.IndexExpr{X: withoutTypeArgs(typ), Index: args[0]}
+	default:
+		return &ast.IndexListExpr{X: withoutTypeArgs(typ), Indices: args}
+	}
+}
+
+// Returns the type of the method's receiver without the pointer, like T[K] for func (t *T[K]) M().
+func receiverType(decl *ast.FuncDecl) ast.Expr {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return nil
+	}
+
+	typ := decl.Recv.List[0].Type
+
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+
+	return typ
+}
+
+func typeParamsOf(list *ast.FieldList) []TypeParam {
+	out := make([]TypeParam, 0)
+
+	for _, param := range flattenParams(list) {
+		out = append(out, TypeParam{Name: param.name.Name, Constraint: SourceCode(param.field.Type)})
+	}
+
+	return out
+}
+
+// Returns the identifier that declares the type parameter `name` in `list` or nil if there's none.
+func typeParamIdent(list *ast.FieldList, name string) *ast.Ident {
+	for _, param := range flattenParams(list) {
+		if param.name.Name == name {
+			return param.name
+		}
+	}
+
+	return nil
+}
+
+// Adds the type parameter `name` to the end of `list` and returns its index.
+func addTypeParam(list **ast.FieldList, name, constraint string) (int, error) {
+	if !token.IsIdentifier(name) {
+		return 0, errors.Errorf("%q is not a valid type parameter name", name)
+	}
+
+	if typeParamIdent(*list, name) != nil {
+		return 0, errors.Errorf("can't add type parameter %s: it already exists", name)
+	}
+
+	constraintExpr, err := parseType(constraint)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid constraint %s", constraint)
+	}
+
+	if *list == nil {
+		*list = &ast.FieldList{}
+	}
+
+	index := len(flattenParams(*list))
+
+	(*list).List = append((*list).List, &ast.Field{Names: []*ast.Ident{ast.NewIdent(name)}, Type: constraintExpr})
+
+	return index, nil
+}
+
+// Removes the type parameter `name` from `list`, the list is removed when it becomes empty.
+func removeTypeParam(list **ast.FieldList, name string) {
+	fields := make([]*ast.Field, 0, len((*list).List))
+
+	for _, field := range (*list).List {
+		names := make([]*ast.Ident, 0, len(field.Names))
+
+		for _, ident := range field.Names {
+			if ident.Name != name {
+				names = append(names, ident)
+			}
+		}
+
+		if len(names) > 0 {
+			field.Names = names
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		*list = nil
+		return
+	}
+
+	(*list).List = fields
+}
+
+// Changes the constraint of the type parameter `name` in `list`, which belongs to `owner`.
+//
+// A type parameter that shares its constraint with others, like K in [K, V any],
+// is moved to a field of its own.
+func setTypeParamConstraint(list *ast.FieldList, owner, name, constraint string) error {
+	ident := typeParamIdent(list, name)
+	if ident == nil {
+		return errors.Errorf("can't change constraint: %s does not have a type parameter called %s", owner, name)
+	}
+
+	constraintExpr, err := parseType(constraint)
+	if err != nil {
+		return errors.Wrapf(err, "invalid constraint %s", constraint)
+	}
+
+	fields := make([]*ast.Field, 0, len(list.List)+2)
+
+	for _, field := range list.List {
+		index := -1
+		for i, existing := range field.Names {
+			if existing == ident {
+				index = i
+			}
+		}
+
+		if index == -1 {
+			fields = append(fields, field)
+			continue
+		}
+
+		if len(field.Names) == 1 {
+			field.Type = constraintExpr
+			fields = append(fields, field)
+			continue
+		}
+
+		// The names before and after the type parameter keep the current constraint.
+		if index > 0 {
+			fields = append(fields, &ast.Field{Names: field.Names[:index], Type: cloneNode(field.Type).(ast.Expr)})
+		}
+
+		fields = append(fields, &ast.Field{Names: []*ast.Ident{ident}, Type: constraintExpr})
+
+		if index < len(field.Names)-1 {
+			fields = append(fields, &ast.Field{Names: field.Names[index+1:], Type: field.Type})
+		}
+	}
+
+	list.List = fields
+
+	return nil
+}
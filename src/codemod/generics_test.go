@@ -0,0 +1,205 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Function_TypeParams(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func Convert[From, To any, N ~int | ~int64](from From, n N) To {
+	var to To
+	return to
+}
+`)})
+	assert.NoError(t, err)
+
+	convert := file.Functions()[0]
+
+	assert.Equal(t, []codemod.TypeParam{
+		{Name: "From", Constraint: "any"},
+		{Name: "To", Constraint: "any"},
+		{Name: "N", Constraint: "~int | ~int64"},
+	}, convert.TypeParams())
+
+	assert.Error(t, convert.RemoveTypeParam("To"))
+	assert.Error(t, convert.RemoveTypeParam("Missing"))
+	assert.Error(t, convert.AddTypeParam("N", "any"))
+	assert.Error(t, convert.AddTypeParam("E", "[]"))
+
+	assert.NoError(t, convert.SetTypeParamConstraint("From", "comparable"))
+	assert.NoError(t, convert.AddTypeParam("E", "error"))
+	assert.NoError(t, convert.RemoveTypeParam("E"))
+
+	expected := `package main
+
+func Convert[From comparable, To any, N ~int | ~int64](from From, n N) To {
+	var to To
+	return to
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
+
+func Test_TypeDeclaration_TypeParams(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+type Map[K comparable, V any] struct {
+	values map[K]V
+}
+
+func (m *Map[K, V]) Get(key K) V {
+	return m.values[key]
+}
+
+func (m Map[A, B]) Len() int {
+	return len(m.values)
+}
+
+type List[T any] []T
+
+func (l List[T]) Len() int {
+	return len(l)
+}
+`)})
+	assert.NoError(t, err)
+
+	typeDecls := file.TypeDeclarations()
+
+	m := typeDecls[0]
+
+	methods := m.Methods()
+	assert.Equal(t, 2, len(methods))
+	assert.Equal(t, "Get", methods[0].Name())
+	assert.Equal(t, 1, len(methods[0].Params()))
+	assert.Equal(t, []codemod.TypeParam{{Name: "K"}, {Name: "V"}}, methods[0].TypeParams())
+	assert.Equal(t, []codemod.TypeParam{{Name: "A"}, {Name: "B"}}, methods[1].TypeParams())
+
+	assert.Error(t, m.RemoveTypeParam("V"))
+
+	assert.NoError(t, m.AddTypeParam("S", "fmt.Stringer"))
+	assert.NoError(t, m.SetTypeParamConstraint("K", "~string"))
+
+	list := typeDecls[1]
+	assert.Equal(t, 1, len(list.Methods()))
+
+	assert.Error(t, list.RemoveTypeParam("T"))
+
+	expected := `package main
+
+type Map[K ~string, V any, S fmt.Stringer] struct {
+	values map[K]V
+}
+
+func (m *Map[K, V, S]) Get(key K) V {
+	return m.values[key]
+}
+
+func (m Map[A, B, S]) Len() int {
+	return len(m.values)
+}
+
+type List[T any] []T
+
+func (l List[T]) Len() int {
+	return len(l)
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+
+	assert.NoError(t, m.RemoveTypeParam("S"))
+
+	set, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+type Set[T comparable] struct{}
+
+func (s *Set[T]) Len() int {
+	return 0
+}
+`)})
+	assert.NoError(t, err)
+
+	assert.NoError(t, set.TypeDeclarations()[0].RemoveTypeParam("T"))
+
+	expected = `package main
+
+type Set struct{}
+
+func (s *Set) Len() int {
+	return 0
+}
+`
+
+	assert.Equal(t, expected, string(set.SourceCode()))
+}
+
+func Test_FunctionCall_TypeArgs(t *testing.T) {
+	t.Parallel()
+
+	sourceCode := `package main
+
+func Map[T, U any](xs []T, f func(T) U) []U {
+	return nil
+}
+
+func Keys[K comparable, V any](m map[K]V) []K {
+	return nil
+}
+
+func main() {
+	_ = Map[int, string](nil, nil)
+	_ = Keys[string](nil)
+	_ = Keys(map[int]int{})
+	handlers := []func(){}
+	handlers[0]()
+}
+`
+
+	t.Run("without type information", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(sourceCode)})
+		assert.NoError(t, err)
+
+		calls := callsByName(file)
+
+		mapCall := calls["Map[int, string]"]
+
+		typeArgs := make([]string, 0)
+		for _, arg := range mapCall.TypeArgs() {
+			typeArgs = append(typeArgs, codemod.SourceCode(arg))
+		}
+		assert.Equal(t, []string{"int", "string"}, typeArgs)
+
+		keys := calls["Keys"]
+		assert.Empty(t, keys.TypeArgs())
+
+		assert.Equal(t, 2, len(file.Find(codemod.IsCall("Keys"))))
+		assert.Equal(t, 1, len(file.Find(codemod.IsCall("Keys[string]"))))
+		assert.Equal(t, 1, len(file.Find(codemod.IsCall("Map"))))
+	})
+
+	t.Run("with type information", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{"main.go": sourceCode}))
+		assert.NoError(t, err)
+
+		calls := callsByName(findFile(t, files, "main.go"))
+
+		mapCall := calls["Map[int, string]"]
+		assert.Equal(t, "Map", mapCall.Callee().Name())
+
+		keysCall := calls["Keys[string]"]
+		assert.Equal(t, "Keys", keysCall.Callee().Name())
+		assert.Equal(t, 1, len(keysCall.TypeArgs()))
+
+		handler := calls["handlers[0]"]
+		assert.Empty(t, handler.TypeArgs())
+	})
+}
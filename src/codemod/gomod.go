@@ -0,0 +1,197 @@
+package codemod
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+)
+
+// The go.mod file of a project.
+//
+// Changes are written to the project right away, in the format
+// used by the go command, and to disk when the project is saved.
+type GoMod struct {
+	project *Project
+	// Path of the go.mod file relative to the project root.
+	path string
+	file *modfile.File
+}
+
+// A module and its version.
+type ModuleVersion struct {
+	Path    string
+	Version string
+}
+
+// Returns the go.mod file at the root of the project.
+func (project *Project) GoMod() (*GoMod, error) {
+	return project.GoModAt("go.mod")
+}
+
+// Returns the go.mod file at `path`, useful in repositories with more than one module.
+func (project *Project) GoModAt(path string) (*GoMod, error) {
+	contents, err := project.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	file, err := modfile.Parse(path, contents, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &GoMod{project: project, path: path, file: file}, nil
+}
+
+// Returns the path in the module directive.
+func (goMod *GoMod) ModulePath() string {
+	if goMod.file.Module == nil {
+		return ""
+	}
+
+	return goMod.file.Module.Mod.Path
+}
+
+// Returns the version in the go directive or an empty string if there's no go directive.
+func (goMod *GoMod) GoVersion() string {
+	if goMod.file.Go == nil {
+		return ""
+	}
+
+	return goMod.file.Go.Version
+}
+
+// Sets the version in the go directive, adding the directive if needed.
+func (goMod *GoMod) SetGoVersion(version string) error {
+	if err := goMod.file.AddGoStmt(version); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return goMod.write()
+}
+
+// Returns the modules in require directives.
+func (goMod *GoMod) Requires() []ModuleVersion {
+	out := make([]ModuleVersion, 0, len(goMod.file.Require))
+
+	for _, require := range goMod.file.Require {
+		out = append(out, ModuleVersion{Path: require.Mod.Path, Version: require.Mod.Version})
+	}
+
+	return out
+}
+
+// Returns the version of the module at `path` in the require directives
+// and false if the module is not required.
+func (goMod *GoMod) RequiredVersion(path string) (string, bool) {
+	for _, require := range goMod.file.Require {
+		if require.Mod.Path == path {
+			return require.Mod.Version, true
+		}
+	}
+
+	return "", false
+}
+
+// Requires `version` of the module at `path`.
+//
+// The version is changed if the module is already required.
+func (goMod *GoMod) Require(path, version string) error {
+	if err := goMod.file.AddRequire(path, version); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return goMod.write()
+}
+
+// Removes the require directive for the module at `path`.
+func (goMod *GoMod) DropRequire(path string) error {
+	if err := goMod.file.DropRequire(path); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return goMod.write()
+}
+
+// Returns the replace directives as pairs of the module being replaced and its replacement.
+//
+// Versions are empty when the directive applies to every version
+// or when the replacement is a directory.
+func (goMod *GoMod) Replaces() [][2]ModuleVersion {
+	out := make([][2]ModuleVersion, 0, len(goMod.file.Replace))
+
+	for _, replace := range goMod.file.Replace {
+		out = append(out, [2]ModuleVersion{
+			{Path: replace.Old.Path, Version: replace.Old.Version},
+			{Path: replace.New.Path, Version: replace.New.Version},
+		})
+	}
+
+	return out
+}
+
+// Replaces `oldVersion` of the module at `oldPath` with `newVersion` of the module at `newPath`.
+//
+// `oldVersion` may be empty to replace every version and `newVersion`
+// must be empty if `newPath` is a directory.
+func (goMod *GoMod) Replace(oldPath, oldVersion, newPath, newVersion string) error {
+	if err := goMod.file.AddReplace(oldPath, oldVersion, newPath, newVersion); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return goMod.write()
+}
+
+// Removes the replace directive for `oldVersion` of the module at `oldPath`.
+func (goMod *GoMod) DropReplace(oldPath, oldVersion string) error {
+	if err := goMod.file.DropReplace(oldPath, oldVersion); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return goMod.write()
+}
+
+// Returns the module versions in exclude directives.
+func (goMod *GoMod) Excludes() []ModuleVersion {
+	out := make([]ModuleVersion, 0, len(goMod.file.Exclude))
+
+	for _, exclude := range goMod.file.Exclude {
+		out = append(out, ModuleVersion{Path: exclude.Mod.Path, Version: exclude.Mod.Version})
+	}
+
+	return out
+}
+
+// Excludes `version` of the module at `path`.
+func (goMod *GoMod) Exclude(path, version string) error {
+	if err := goMod.file.AddExclude(path, version); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return goMod.write()
+}
+
+// Removes the exclude directive for `version` of the module at `path`.
+func (goMod *GoMod) DropExclude(path, version string) error {
+	if err := goMod.file.DropExclude(path, version); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return goMod.write()
+}
+
+// Writes the go.mod file to the project, sorted and formatted like the go command does.
+func (goMod *GoMod) write() error {
+	goMod.file.SortBlocks()
+	goMod.file.Cleanup()
+
+	contents, err := goMod.file.Format()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := goMod.project.CreateFile(goMod.path, contents); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
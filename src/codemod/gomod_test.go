@@ -0,0 +1,105 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGoMod = `module example.com/project
+
+go 1.16
+
+require (
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.7.0
+)
+
+exclude github.com/pkg/errors v0.8.0
+`
+
+func Test_GoMod(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reads the module path, go version and directives", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{"go.mod": testGoMod})
+
+		goMod, err := project.GoMod()
+		assert.NoError(t, err)
+
+		assert.Equal(t, "example.com/project", goMod.ModulePath())
+		assert.Equal(t, "1.16", goMod.GoVersion())
+		assert.Equal(t, []codemod.ModuleVersion{
+			{Path: "github.com/pkg/errors", Version: "v0.9.1"},
+			{Path: "github.com/stretchr/testify", Version: "v1.7.0"},
+		}, goMod.Requires())
+		assert.Equal(t, []codemod.ModuleVersion{{Path: "github.com/pkg/errors", Version: "v0.8.0"}}, goMod.Excludes())
+
+		version, ok := goMod.RequiredVersion("github.com/pkg/errors")
+		assert.True(t, ok)
+		assert.Equal(t, "v0.9.1", version)
+
+		_, ok = goMod.RequiredVersion("github.com/google/uuid")
+		assert.False(t, ok)
+	})
+
+	t.Run("changes directives", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{"go.mod": testGoMod})
+
+		goMod, err := project.GoMod()
+		assert.NoError(t, err)
+
+		assert.NoError(t, goMod.SetGoVersion("1.18"))
+		assert.NoError(t, goMod.Require("github.com/stretchr/testify", "v1.8.0"))
+		assert.NoError(t, goMod.Require("github.com/google/uuid", "v1.3.0"))
+		assert.NoError(t, goMod.DropRequire("github.com/pkg/errors"))
+		assert.NoError(t, goMod.DropExclude("github.com/pkg/errors", "v0.8.0"))
+		assert.NoError(t, goMod.Exclude("github.com/google/uuid", "v1.2.0"))
+		assert.NoError(t, goMod.Replace("github.com/google/uuid", "", "../uuid", ""))
+
+		assert.NoError(t, project.Save(codemod.SaveOptions{}))
+
+		expected := `module example.com/project
+
+go 1.18
+
+require (
+	github.com/google/uuid v1.3.0
+	github.com/stretchr/testify v1.8.0
+)
+
+exclude github.com/google/uuid v1.2.0
+
+replace github.com/google/uuid => ../uuid
+`
+
+		assert.Equal(t, expected, readProjectFile(t, project, "go.mod"))
+	})
+
+	t.Run("drops replace directives", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{"go.mod": testGoMod + "\nreplace github.com/pkg/errors => ../errors\n"})
+
+		goMod, err := project.GoMod()
+		assert.NoError(t, err)
+
+		assert.Equal(t, [][2]codemod.ModuleVersion{
+			{{Path: "github.com/pkg/errors"}, {Path: "../errors"}},
+		}, goMod.Replaces())
+
+		assert.NoError(t, goMod.DropReplace("github.com/pkg/errors", ""))
+
+		assert.Empty(t, goMod.Replaces())
+
+		contents, err := project.ReadFile("go.mod")
+		assert.NoError(t, err)
+		assert.Equal(t, testGoMod, string(contents))
+	})
+
+	t.Run("returns error when there's no go.mod", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{})
+
+		_, err := project.GoMod()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,476 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An import in a source file.
+type Import struct {
+	// Name the package is imported as. Empty if the import does not have a name,
+	// _ for blank imports and . for dot imports.
+	Name string
+	Path string
+}
+
+// The imports of a source file.
+//
+// New imports are added to the group of imports they belong to:
+// standard library packages, third-party packages or packages
+// of the module the file is in, in that order.
+type Imports struct {
+	code *SourceFile
+}
+
+func (code *SourceFile) Imports() *Imports {
+	return &Imports{code: code}
+}
+
+// Kinds of imports, in the order their groups appear in.
+type importGroup int
+
+const (
+	standardLibraryImport importGroup = iota
+	thirdPartyImport
+	localImport
+)
+
+// Returns the imports of the file in the order they appear in.
+func (imports *Imports) List() []Import {
+	out := make([]Import, 0)
+
+	for _, spec := range imports.specs() {
+		out = append(out, importOf(spec))
+	}
+
+	return out
+}
+
+// Returns the paths of the imported packages in the order they appear in.
+func (imports *Imports) Paths() []string {
+	out := make([]string, 0)
+
+	for _, spec := range imports.specs() {
+		out = append(out, importOf(spec).Path)
+	}
+
+	return out
+}
+
+// Returns true if the package at `importPath` is imported, with or without a name.
+func (imports *Imports) Contains(importPath string) bool {
+	_, ok := imports.Name(importPath)
+	return ok
+}
+
+// Returns true if the package at `importPath` is imported with `name`.
+//
+// An empty name matches imports without a name.
+func (imports *Imports) ContainsNamed(name, importPath string) bool {
+	for _, spec := range imports.specs() {
+		if importOf(spec) == (Import{Name: name, Path: importPath}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the name the package at `importPath` is imported as
+// and false if the package is not imported.
+//
+// The name is empty if the import does not have a name.
+func (imports *Imports) Name(importPath string) (string, bool) {
+	for _, spec := range imports.specs() {
+		if i := importOf(spec); i.Path == importPath {
+			return i.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the identifier the file uses to refer to the package at `importPath`
+// and false if the package is not imported.
+//
+// For imports without a name, that's the name of the package when the file
+// was type checked, otherwise the name is guessed from the import path,
+// "github.com/go-redis/redis/v8" is assumed to be package redis for example.
+func (imports *Imports) LocalName(importPath string) (string, bool) {
+	for _, spec := range imports.specs() {
+		if importOf(spec).Path != importPath {
+			continue
+		}
+
+		if spec.Name != nil {
+			return spec.Name.Name, true
+		}
+
+		if imports.code.pkg != nil {
+			if pkgName, ok := imports.code.pkg.info.Implicits[spec]; ok {
+				return pkgName.Name(), true
+			}
+		}
+
+		return assumedPackageName(importPath), true
+	}
+
+	return "", false
+}
+
+// Returns the import path of the package that is referred to as `name` in the file.
+//
+// Blank and dot imports are ignored.
+func (imports *Imports) pathOf(name string) (string, bool) {
+	for _, i := range imports.List() {
+		if i.Name == "_" || i.Name == "." {
+			continue
+		}
+
+		if localName, _ := imports.LocalName(i.Path); localName == name {
+			return i.Path, true
+		}
+	}
+
+	return "", false
+}
+
+// Imports the package at `importPath` if it's not imported yet.
+//
+// An import declaration is created if the file does not have one.
+func (imports *Imports) Add(importPath string) {
+	if imports.Contains(importPath) {
+		return
+	}
+
+	imports.add(Import{Path: importPath})
+}
+
+// Imports the package at `importPath` as `name` if it's not imported as `name` yet.
+//
+// `name` may be _ for blank imports, . for dot imports or empty for an import without a name.
+func (imports *Imports) AddNamed(name, importPath string) {
+	if imports.ContainsNamed(name, importPath) {
+		return
+	}
+
+	imports.add(Import{Name: name, Path: importPath})
+}
+
+// Changes the name the package at `importPath` is imported as.
+// An empty name removes the name from the import.
+//
+// Code that refers to the package by its old name is not changed.
+func (imports *Imports) SetName(importPath, name string) {
+	for _, spec := range imports.specs() {
+		if importOf(spec).Path != importPath {
+			continue
+		}
+
+		if name == "" {
+			spec.Name = nil
+		} else {
+			spec.Name = ast.NewIdent(name)
+		}
+	}
+}
+
+// Removes every import of the package at `importPath`.
+func (imports *Imports) Remove(importPath string) {
+	imports.remove(func(i Import) bool { return i.Path == importPath })
+}
+
+// Removes the import of the package at `importPath` as `name`,
+// imports of the package with other names are kept.
+func (imports *Imports) RemoveNamed(name, importPath string) {
+	imports.remove(func(i Import) bool { return i == Import{Name: name, Path: importPath} })
+}
+
+func importOf(spec *ast.ImportSpec) Import {
+	out := Import{Path: Unquote(spec.Path.Value)}
+
+	if unquoted, err := strconv.Unquote(spec.Path.Value); err == nil {
+		out.Path = unquoted
+	}
+
+	if spec.Name != nil {
+		out.Name = spec.Name.Name
+	}
+
+	return out
+}
+
+// Returns the name a package is likely to have given its import path.
+func assumedPackageName(importPath string) string {
+	name := path.Base(importPath)
+
+	if strings.HasPrefix(name, "v") {
+		if _, err := strconv.Atoi(name[1:]); err == nil && path.Dir(importPath) != "." {
+			name = path.Base(path.Dir(importPath))
+		}
+	}
+
+	name = strings.TrimPrefix(name, "go-")
+
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}); i >= 0 {
+		name = name[:i]
+	}
+
+	return name
+}
+
+func (imports *Imports) importDecls() []*ast.GenDecl {
+	out := make([]*ast.GenDecl, 0)
+
+	for _, decl := range imports.code.file.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			out = append(out, decl)
+		}
+	}
+
+	return out
+}
+
+func (imports *Imports) specs() []*ast.ImportSpec {
+	out := make([]*ast.ImportSpec, 0)
+
+	for _, decl := range imports.importDecls() {
+		for _, spec := range decl.Specs {
+			out = append(out, spec.(*ast.ImportSpec))
+		}
+	}
+
+	return out
+}
+
+// Returns the group the import of `importPath` belongs to.
+func (imports *Imports) groupOf(importPath string) importGroup {
+	if imports.code.FilePath != "" {
+		if _, modulePath, ok := findModule(filepath.Dir(imports.code.FilePath)); ok {
+			if importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/") {
+				return localImport
+			}
+		}
+	}
+
+	if isStandardLibrary(importPath) {
+		return standardLibraryImport
+	}
+
+	return thirdPartyImport
+}
+
+// Returns true if the spec at index `i` of `specs` is separated
+// from the spec before it by an empty line.
+func (imports *Imports) startsGroup(specs []ast.Spec, i int) bool {
+	if i == 0 {
+		return false
+	}
+
+	d := imports.code.decorations
+
+	if d.emptyLines[specs[i]] {
+		return true
+	}
+
+	if !d.parsed[specs[i]] || !d.parsed[specs[i-1]] {
+		return false
+	}
+
+	for line := d.line(specs[i-1].End()) + 1; line < d.line(specs[i].Pos()); line++ {
+		if !d.occupied[line] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (imports *Imports) add(i Import) {
+	spec := &ast.ImportSpec{
+		Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(i.Path)},
+	}
+
+	if i.Name != "" {
+		spec.Name = ast.NewIdent(i.Name)
+	}
+
+	decls := imports.importDecls()
+
+	if len(decls) == 0 {
+		decl := &ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}}
+
+		imports.code.file.Decls = append([]ast.Decl{decl}, imports.code.file.Decls...)
+		imports.code.decorations.setEmptyLineBefore(decl)
+
+		imports.sync()
+
+		return
+	}
+
+	decl := decls[0]
+
+	if !decl.Lparen.IsValid() {
+		decl = imports.addParens(decl)
+	}
+
+	group := imports.groupOf(i.Path)
+
+	// The new spec goes after the last group of the same kind
+	// or starts a new group before the first group of a kind that comes after it.
+	index := len(decl.Specs)
+	startsGroup := false
+
+	for j := len(decl.Specs) - 1; j >= 0; j-- {
+		specGroup := imports.groupOf(importOf(decl.Specs[j].(*ast.ImportSpec)).Path)
+
+		if specGroup == group {
+			index = imports.sortedIndex(decl.Specs, j, i.Path)
+			startsGroup = false
+			break
+		}
+
+		if specGroup < group {
+			break
+		}
+
+		if j == 0 || imports.startsGroup(decl.Specs, j) {
+			index = j
+			startsGroup = true
+		}
+	}
+
+	if index == len(decl.Specs) && !startsGroup && len(decl.Specs) > 0 {
+		startsGroup = imports.groupOf(importOf(decl.Specs[index-1].(*ast.ImportSpec)).Path) != group
+	}
+
+	if index < len(decl.Specs) && imports.startsGroup(decl.Specs, index) && !startsGroup {
+		// The new spec is the first of a group that already exists.
+		startsGroup = true
+
+		delete(imports.code.decorations.emptyLines, decl.Specs[index])
+	} else if startsGroup && index < len(decl.Specs) {
+		imports.code.decorations.setEmptyLineBefore(decl.Specs[index])
+	}
+
+	if startsGroup && index > 0 {
+		imports.code.decorations.setEmptyLineBefore(spec)
+	}
+
+	specs := make([]ast.Spec, 0, len(decl.Specs)+1)
+	specs = append(specs, decl.Specs[:index]...)
+	specs = append(specs, spec)
+	specs = append(specs, decl.Specs[index:]...)
+
+	decl.Specs = specs
+
+	imports.sync()
+}
+
+// Returns the index `importPath` should be inserted at in the group
+// that ends with the spec at index `last`.
+//
+// The import is added in order if the group is sorted by path
+// and at the end of the group otherwise.
+func (imports *Imports) sortedIndex(specs []ast.Spec, last int, importPath string) int {
+	first := last
+	for first > 0 && !imports.startsGroup(specs, first) {
+		first--
+	}
+
+	for i := first + 1; i <= last; i++ {
+		if importOf(specs[i-1].(*ast.ImportSpec)).Path > importOf(specs[i].(*ast.ImportSpec)).Path {
+			return last + 1
+		}
+	}
+
+	index := last + 1
+	for index > first && importOf(specs[index-1].(*ast.ImportSpec)).Path > importPath {
+		index--
+	}
+
+	return index
+}
+
+// Replaces `decl`, an import declaration without parentheses,
+// with a declaration that has parentheses and returns the new declaration.
+func (imports *Imports) addParens(decl *ast.GenDecl) *ast.GenDecl {
+	parenthesized := &ast.GenDecl{
+		Doc:    decl.Doc,
+		Tok:    token.IMPORT,
+		Lparen: 1,
+		Specs:  decl.Specs,
+		Rparen: 1,
+	}
+
+	for i, existing := range imports.code.file.Decls {
+		if existing == decl {
+			imports.code.file.Decls[i] = parenthesized
+		}
+	}
+
+	// Comments after the declaration are about its only spec and stay next to it.
+	for _, decoration := range imports.code.decorations.comments[decl] {
+		if decoration.placement == commentAfter && len(decl.Specs) == 1 {
+			imports.code.decorations.comments[decl.Specs[0]] = append(imports.code.decorations.comments[decl.Specs[0]], decoration)
+		} else {
+			imports.code.decorations.comments[parenthesized] = append(imports.code.decorations.comments[parenthesized], decoration)
+		}
+	}
+
+	delete(imports.code.decorations.comments, decl)
+	imports.code.decorations.setEmptyLineBefore(parenthesized)
+
+	return parenthesized
+}
+
+// Removes the imports `shouldRemove` returns true for
+// and the declarations that end up without imports.
+func (imports *Imports) remove(shouldRemove func(Import) bool) {
+	decls := make([]ast.Decl, 0, len(imports.code.file.Decls))
+
+	for _, decl := range imports.code.file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+
+		specs := make([]ast.Spec, 0, len(genDecl.Specs))
+
+		for i, spec := range genDecl.Specs {
+			if !shouldRemove(importOf(spec.(*ast.ImportSpec))) {
+				specs = append(specs, spec)
+				continue
+			}
+
+			// The spec after the first spec of a group starts the group when the first spec is removed.
+			if len(specs) > 0 && imports.startsGroup(genDecl.Specs, i) &&
+				i+1 < len(genDecl.Specs) && !imports.startsGroup(genDecl.Specs, i+1) {
+				imports.code.decorations.setEmptyLineBefore(genDecl.Specs[i+1])
+			}
+		}
+
+		genDecl.Specs = specs
+
+		if len(specs) > 0 {
+			decls = append(decls, decl)
+		}
+	}
+
+	imports.code.file.Decls = decls
+
+	imports.sync()
+}
+
+// Updates the list of imports the parser keeps in the file.
+func (imports *Imports) sync() {
+	imports.code.file.Imports = imports.specs()
+}
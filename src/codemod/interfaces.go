@@ -0,0 +1,444 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A type declared in a type checked package that implements an interface.
+type implementation struct {
+	typeName *types.TypeName
+	// True if only a pointer to the type implements the interface.
+	pointer bool
+	pkg     *packageInfo
+}
+
+// Adds the method `name` with `signature`, like (ctx context.Context, id string) (*User, error),
+// to the interface.
+//
+// Types declared in the packages type checked with the source file that implement
+// the interface get a method with the same signature that panics, so they keep implementing it.
+// Types that already have a method or field called `name` are left as they are.
+// Types the signature refers to are qualified and their packages are imported
+// in the files the methods are added to.
+//
+// Returns error if the type is not an interface, if it already has a method called `name`,
+// if `signature` is not valid or if the source file has not been type checked.
+func (typeDecl *TypeDeclaration) AddMethod(name, signature string) error {
+	interfaceType, ok := typeDecl.Node.Type.(*ast.InterfaceType)
+	if !ok {
+		return errors.Errorf("can't add method to %s: it is not an interface", typeDecl.Node.Name.Name)
+	}
+
+	if !token.IsIdentifier(name) {
+		return errors.Errorf("%q is not a valid method name", name)
+	}
+
+	if typeDecl.interfaceMethod(name) != nil {
+		return errors.Errorf("can't add method to %s: it already has a method called %s", typeDecl.Node.Name.Name, name)
+	}
+
+	expr, err := parser.ParseExpr("func" + signature)
+	if err != nil {
+		return errors.Wrapf(err, "invalid signature %s", signature)
+	}
+
+	funcType, ok := expr.(*ast.FuncType)
+	if !ok {
+		return errors.Errorf("invalid signature %s", signature)
+	}
+
+	implementations, err := typeDecl.implementations()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	interfaceType.Methods.List = append(interfaceType.Methods.List, &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent(name)},
+		Type:  cloneNode(funcType).(*ast.FuncType),
+	})
+
+	for _, implementation := range implementations {
+		if obj, _, _ := types.LookupFieldOrMethod(implementation.typeName.Type(), true, implementation.typeName.Pkg(), name); obj != nil {
+			continue
+		}
+
+		typeDecl.addStub(implementation, name, funcType)
+	}
+
+	return nil
+}
+
+// Removes the method `name` from the interface.
+//
+// The methods of the types that implement the interface are kept, other code may still call them.
+//
+// Returns error if the type is not an interface or if it does not have a method called `name`.
+func (typeDecl *TypeDeclaration) RemoveMethod(name string) error {
+	field := typeDecl.interfaceMethod(name)
+	if field == nil {
+		return errors.Errorf("can't remove method: %s is not an interface with a method called %s", typeDecl.Node.Name.Name, name)
+	}
+
+	methods := typeDecl.Node.Type.(*ast.InterfaceType).Methods
+
+	list := make([]*ast.Field, 0, len(methods.List))
+
+	for _, existing := range methods.List {
+		if existing != field {
+			list = append(list, existing)
+		}
+	}
+
+	methods.List = list
+
+	return nil
+}
+
+// Changes the parameters of the interface method `name` to `params`, like Function.ChangeSignature,
+// along with the methods of every type in the packages type checked with the source file
+// that implements the interface and the calls to all of them.
+//
+// New parameters are written in the methods as they are in `params`,
+// use Project.FixImports to import the packages they refer to.
+//
+// Returns the references that were left as they were, including implementations
+// whose methods are declared outside of the type checked packages.
+// Nothing is changed if an error is returned.
+func (typeDecl *TypeDeclaration) ChangeMethodSignature(name string, params []Parameter) ([]SkippedCall, error) {
+	field := typeDecl.interfaceMethod(name)
+	if field == nil {
+		return nil, errors.Errorf("can't change method: %s is not an interface with a method called %s", typeDecl.Node.Name.Name, name)
+	}
+
+	implementations, err := typeDecl.implementations()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	code := typeDecl.file
+
+	functions := make([]*Function, 0, len(implementations))
+	skipped := make([]SkippedCall, 0)
+	seen := make(map[*ast.FuncDecl]bool)
+
+	for _, implementation := range implementations {
+		method, _, _ := types.LookupFieldOrMethod(implementation.typeName.Type(), true, implementation.typeName.Pkg(), name)
+
+		function := code.pkg.function(method)
+		if function == nil {
+			skipped = append(skipped, SkippedCall{
+				Position: position(code.pkg.packages, method),
+				Reason:   fmt.Sprintf("%s implements %s but its method %s is not declared in the type checked packages", implementation.typeName.Name(), typeDecl.Node.Name.Name, name),
+			})
+
+			continue
+		}
+
+		// Methods promoted from an embedded type are shared by every type that embeds it.
+		if !seen[function.Node] {
+			seen[function.Node] = true
+			functions = append(functions, function)
+		}
+	}
+
+	change, err := newSignatureChange(code, name, field.Type.(*ast.FuncType).Params, functions, params)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	change.interfaceMethods = append(change.interfaceMethods, field)
+	change.callees[code.pkg.info.Defs[field.Names[0]]] = true
+	change.skipped = skipped
+
+	if err := change.findReferences(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := change.apply(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return change.skipped, nil
+}
+
+// Returns the declaration of the interface method `name`
+// or nil if the type is not an interface or does not have the method.
+func (typeDecl *TypeDeclaration) interfaceMethod(name string) *ast.Field {
+	interfaceType, ok := typeDecl.Node.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil
+	}
+
+	for _, field := range interfaceType.Methods.List {
+		for _, ident := range field.Names {
+			if ident.Name == name {
+				return field
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the types declared at the top level of the packages type checked
+// with the source file that implement the interface. Generic types are left out.
+//
+// Interfaces without methods are implemented by every type by accident, nothing is returned for them.
+func (typeDecl *TypeDeclaration) implementations() ([]implementation, error) {
+	code := typeDecl.file
+
+	if code.pkg == nil {
+		return nil, errors.Errorf("can't find the implementations of %s without type information: source file has not been type checked", typeDecl.Node.Name.Name)
+	}
+
+	typeName, ok := code.pkg.info.Defs[typeDecl.Node.Name].(*types.TypeName)
+	if !ok {
+		return nil, errors.Errorf("no type information for %s", typeDecl.Node.Name.Name)
+	}
+
+	iface, ok := typeName.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, errors.Errorf("%s is not an interface", typeDecl.Node.Name.Name)
+	}
+
+	out := make([]implementation, 0)
+
+	if iface.NumMethods() == 0 {
+		return out, nil
+	}
+
+	for _, pkg := range code.pkg.packages {
+		scope := pkg.types.Scope()
+
+		for _, name := range scope.Names() {
+			candidate, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || candidate.IsAlias() || types.IsInterface(candidate.Type()) {
+				continue
+			}
+
+			// Generic types only implement interfaces once they are instantiated.
+			if named, ok := candidate.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+
+			if types.Implements(candidate.Type(), iface) {
+				out = append(out, implementation{typeName: candidate, pkg: pkg})
+			} else if types.Implements(types.NewPointer(candidate.Type()), iface) {
+				out = append(out, implementation{typeName: candidate, pointer: true, pkg: pkg})
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// Adds a method called `name` of type `funcType`, written in the file the interface is declared in,
+// to `implementation`. The method panics when it's called.
+//
+// The method is added after the last method of the type in the file that declares the type.
+func (typeDecl *TypeDeclaration) addStub(implementation implementation, name string, funcType *ast.FuncType) {
+	file := implementation.pkg.fileAt(implementation.typeName.Pos())
+	if file == nil {
+		return
+	}
+
+	typeName := implementation.typeName.Name()
+
+	recvName, pointer := receiverOf(implementation)
+
+	stubType := adaptType(funcType, typeDecl.file, file).(*ast.FuncType)
+
+	// The receiver is left without a name if a parameter already uses it.
+	for _, list := range []*ast.FieldList{stubType.Params, stubType.Results} {
+		for _, param := range flattenParams(list) {
+			if param.name != nil && param.name.Name == recvName {
+				recvName = ""
+			}
+		}
+	}
+
+	recv := &ast.Field{Type: ast.NewIdent(typeName)}
+	if pointer {
+		recv.Type = &ast.StarExpr{X: recv.Type}
+	}
+
+	if recvName != "" {
+		recv.Names = []*ast.Ident{ast.NewIdent(recvName)}
+	}
+
+	stub := &ast.FuncDecl{
+		Recv: &ast.FieldList{List: []*ast.Field{recv}},
+		Name: ast.NewIdent(name),
+		Type: stubType,
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun:  ast.NewIdent("panic"),
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("TODO: implement " + name)}},
+			}},
+		}},
+	}
+
+	index := len(file.file.Decls)
+
+	for i, decl := range file.file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			if decl.Pos() <= implementation.typeName.Pos() && implementation.typeName.Pos() < decl.End() {
+				index = i + 1
+			}
+
+		case *ast.FuncDecl:
+			if receiverTypeName(decl) == typeName {
+				index = i + 1
+			}
+		}
+	}
+
+	decls := make([]ast.Decl, 0, len(file.file.Decls)+1)
+	decls = append(decls, file.file.Decls[:index]...)
+	decls = append(decls, stub)
+	decls = append(decls, file.file.Decls[index:]...)
+
+	file.file.Decls = decls
+
+	file.decorations.setEmptyLineBefore(stub)
+}
+
+// Returns the name the methods of `implementation` use for their receiver
+// and true if they use a pointer receiver.
+func receiverOf(implementation implementation) (string, bool) {
+	name := ""
+	pointer := implementation.pointer
+
+	if named, ok := implementation.typeName.Type().(*types.Named); ok {
+		for i := 0; i < named.NumMethods(); i++ {
+			recv := named.Method(i).Type().(*types.Signature).Recv()
+
+			if _, ok := recv.Type().(*types.Pointer); ok {
+				pointer = true
+			}
+
+			if name == "" && recv.Name() != "" && recv.Name() != "_" {
+				name = recv.Name()
+			}
+		}
+	}
+
+	if name == "" {
+		name = strings.ToLower(implementation.typeName.Name()[:1])
+	}
+
+	return name, pointer
+}
+
+// Returns the name of the type the method `decl` belongs to, like T for func (t *T) M(),
+// or an empty string if `decl` is not a method.
+func receiverTypeName(decl *ast.FuncDecl) string {
+	if ident, ok := withoutTypeArgs(receiverType(decl)).(*ast.Ident); ok {
+		return ident.Name
+	}
+
+	return ""
+}
+
+// Returns a copy of `typ`, written in the file `from`, that means the same in the file `to`.
+//
+// Types declared in the package of `from` are qualified when `to` belongs to another package
+// and the packages `typ` refers to are imported in `to`.
+func adaptType(typ ast.Expr, from, to *SourceFile) ast.Expr {
+	typ = cloneNode(typ).(ast.Expr)
+
+	return rewriteTree(typ, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			pkg, ok := node.X.(*ast.Ident)
+			if !ok {
+				return node
+			}
+
+			importPath, ok := from.Imports().pathOf(pkg.Name)
+			if !ok {
+				return node
+			}
+
+			pkg.Name = to.importName(importPath, pkg.Name)
+
+		case *ast.Ident:
+			// Parameter names and selectors can't be replaced by a selector so they stay as they are.
+			if from.pkg == nil || from.pkg == to.pkg {
+				return node
+			}
+
+			if _, ok := from.pkg.types.Scope().Lookup(node.Name).(*types.TypeName); !ok {
+				return node
+			}
+
+			return &ast.SelectorExpr{
+				X:   ast.NewIdent(to.importName(from.pkg.path, from.pkg.types.Name())),
+				Sel: ast.NewIdent(node.Name),
+			}
+		}
+
+		return node
+	}).(ast.Expr)
+}
+
+// Returns the name the file uses for the package at `importPath`,
+// importing the package as `packageName` if the file does not import it yet.
+func (code *SourceFile) importName(importPath, packageName string) string {
+	imports := code.Imports()
+
+	if name, ok := imports.LocalName(importPath); ok {
+		return name
+	}
+
+	if assumedPackageName(importPath) == packageName {
+		imports.Add(importPath)
+	} else {
+		imports.AddNamed(packageName, importPath)
+	}
+
+	return packageName
+}
+
+// Returns the file of the package that contains `pos` or nil if there's none.
+func (pkg *packageInfo) fileAt(pos token.Pos) *SourceFile {
+	for _, sourceFile := range pkg.files {
+		if sourceFile.fileSet.File(sourceFile.file.Pos()) == sourceFile.fileSet.File(pos) {
+			return sourceFile
+		}
+	}
+
+	return nil
+}
+
+// Returns the declaration of the function or method `obj`, which is nil
+// if it's not declared in the packages type checked with the package.
+func (pkg *packageInfo) function(obj types.Object) *Function {
+	if obj == nil {
+		return nil
+	}
+
+	for _, other := range pkg.packages {
+		sourceFile := other.fileAt(obj.Pos())
+		if sourceFile == nil {
+			continue
+		}
+
+		for _, decl := range sourceFile.file.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok && other.info.Defs[funcDecl.Name] == obj {
+				return &Function{Node: funcDecl, file: sourceFile}
+			}
+		}
+	}
+
+	return nil
+}
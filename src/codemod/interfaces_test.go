@@ -0,0 +1,236 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TypeDeclaration_InterfaceMethods(t *testing.T) {
+	t.Parallel()
+
+	module := map[string]string{
+		"service/service.go": `package service
+
+import "context"
+
+type User struct{}
+
+type Filter struct{}
+
+type Store interface {
+	Get(ctx context.Context, id string) (*User, error)
+}
+
+type memoryStore struct{}
+
+func (store memoryStore) Get(ctx context.Context, id string) (*User, error) {
+	return nil, nil
+}
+`,
+		"postgres/postgres.go": `package postgres
+
+import (
+	"context"
+
+	"example.com/project/service"
+)
+
+type Store struct{}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	return nil, nil
+}
+
+func (s *Store) Close() {}
+
+type List struct{}
+
+func (l *List) List() {}
+
+type Get struct{}
+
+func (g Get) Get(id string) {}
+`,
+	}
+
+	t.Run("AddMethod adds methods to the implementations", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, module))
+		assert.NoError(t, err)
+
+		store := findTypeDeclaration(t, findFile(t, files, "service.go"), "Store")
+
+		assert.Error(t, store.AddMethod("Get", "()"))
+		assert.Error(t, store.AddMethod("List", "int"))
+
+		assert.NoError(t, store.AddMethod("List", "(ctx context.Context, filter Filter) ([]*User, error)"))
+
+		expected := `package service
+
+import "context"
+
+type User struct{}
+
+type Filter struct{}
+
+type Store interface {
+	Get(ctx context.Context, id string) (*User, error)
+	List(ctx context.Context, filter Filter) ([]*User, error)
+}
+
+type memoryStore struct{}
+
+func (store memoryStore) Get(ctx context.Context, id string) (*User, error) {
+	return nil, nil
+}
+
+func (store memoryStore) List(ctx context.Context, filter Filter) ([]*User, error) {
+	panic("TODO: implement List")
+}
+`
+		assert.Equal(t, expected, string(findFile(t, files, "service.go").SourceCode()))
+
+		expected = `package postgres
+
+import (
+	"context"
+
+	"example.com/project/service"
+)
+
+type Store struct{}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	return nil, nil
+}
+
+func (s *Store) Close() {}
+
+func (s *Store) List(ctx context.Context, filter service.Filter) ([]*service.User, error) {
+	panic("TODO: implement List")
+}
+
+type List struct{}
+
+func (l *List) List() {}
+
+type Get struct{}
+
+func (g Get) Get(id string) {}
+`
+		assert.Equal(t, expected, string(findFile(t, files, "postgres.go").SourceCode()))
+	})
+
+	t.Run("RemoveMethod removes the method from the interface", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, module))
+		assert.NoError(t, err)
+
+		store := findTypeDeclaration(t, findFile(t, files, "service.go"), "Store")
+
+		assert.NoError(t, store.RemoveMethod("Get"))
+		assert.Error(t, store.RemoveMethod("Get"))
+		assert.Empty(t, store.Methods())
+
+		user := findTypeDeclaration(t, findFile(t, files, "service.go"), "User")
+		assert.Error(t, user.RemoveMethod("Get"))
+	})
+
+	t.Run("ChangeMethodSignature changes the implementations and the calls", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"service/service.go": `package service
+
+type Store interface {
+	Get(id string) error
+}
+
+type memoryStore struct{}
+
+func (store memoryStore) Get(key string) error {
+	return nil
+}
+
+type cachedStore struct {
+	memoryStore
+}
+
+func Find(store Store) error {
+	return store.Get("1")
+}
+`,
+			"postgres/postgres.go": `package postgres
+
+type Store struct{}
+
+func (s *Store) Get(id string) error {
+	return nil
+}
+
+func (s *Store) Close() {
+	_ = s.Get("2")
+}
+`,
+		}))
+		assert.NoError(t, err)
+
+		store := findTypeDeclaration(t, findFile(t, files, "service.go"), "Store")
+
+		_, err = store.ChangeMethodSignature("Find", nil)
+		assert.Error(t, err)
+
+		skipped, err := store.ChangeMethodSignature("Get", []codemod.Parameter{
+			codemod.AddParameter("ctx", "context.Context", "context.TODO()"),
+			codemod.KeepParameter(0),
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, skipped)
+
+		expected := `package service
+
+type Store interface {
+	Get(ctx context.Context, id string) error
+}
+
+type memoryStore struct{}
+
+func (store memoryStore) Get(ctx context.Context, key string) error {
+	return nil
+}
+
+type cachedStore struct {
+	memoryStore
+}
+
+func Find(store Store) error {
+	return store.Get(context.TODO(), "1")
+}
+`
+		assert.Equal(t, expected, string(findFile(t, files, "service.go").SourceCode()))
+
+		expected = `package postgres
+
+type Store struct{}
+
+func (s *Store) Get(ctx context.Context, id string) error {
+	return nil
+}
+
+func (s *Store) Close() {
+	_ = s.Get(context.TODO(), "2")
+}
+`
+		assert.Equal(t, expected, string(findFile(t, files, "postgres.go").SourceCode()))
+	})
+
+	t.Run("returns error without type information", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(module["service/service.go"])})
+		assert.NoError(t, err)
+
+		store := findTypeDeclaration(t, file, "Store")
+
+		assert.Error(t, store.AddMethod("List", "()"))
+
+		_, err = store.ChangeMethodSignature("Get", []codemod.Parameter{codemod.KeepParameter(1)})
+		assert.Error(t, err)
+	})
+}
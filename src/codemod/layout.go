@@ -0,0 +1,693 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type lineHint int
+
+const (
+	// The token may be in the same line as the token before it.
+	noLineHint lineHint = iota
+	// The token starts a new line if it was moved or created.
+	newLineIfMoved
+	// The token starts a new line.
+	newLine
+)
+
+// Where the first token of a node was placed.
+type nodeStart struct {
+	line int
+	// True if the token was not where the parser found it.
+	moved bool
+}
+
+type placedPosition struct {
+	pos    *token.Pos
+	line   int
+	column int
+	// Length of the text of the token or comment at the position.
+	width int
+}
+
+// Computes positions for a copy of a tree after the tree has been changed.
+//
+// Tokens that are in the same order they were parsed in keep the lines and columns
+// they had relative to each other, new and moved tokens are placed after the token before them
+// and comments are placed next to the nodes they are attached to.
+type layout struct {
+	decorations *decorations
+	// Maps copies to the nodes they were copied from.
+	origins map[ast.Node]ast.Node
+
+	positions []placedPosition
+	comments  []*ast.CommentGroup
+	// Maps comment groups in the original tree to the groups placed in the copy.
+	placed map[*ast.CommentGroup]*ast.CommentGroup
+	// Comments that will be placed before the next token.
+	pending []decoration
+	// Nodes that will start at the next token.
+	entered []ast.Node
+	starts  map[ast.Node]nodeStart
+	// Field lists that have one field per line: the fields of structs and interfaces.
+	blocks map[*ast.FieldList]bool
+	// Order of the tokens from the source code that are still in the tree.
+	order map[token.Pos]int
+	// Number of lines before each line of the source code that only had removed tokens or comments.
+	removedLines []int
+
+	// Line and column of the last character of the last token or comment placed.
+	line, column int
+	// Line where the last token placed ends.
+	tokenLine int
+	// Last token placed that came from the parser,
+	// the line where it ended in the source code and the line where it ended after being placed.
+	lastPos              token.Pos
+	lastLine, lastPlaced int
+	// True if a line comment was placed and the next token must start in a new line.
+	lineBreak bool
+	hint      lineHint
+	// True if the next token starts an element of a list, that is not the first element,
+	// where each element is in a line of its own.
+	keepEmptyLine bool
+	// True if the next token starts a node that starts after an empty line.
+	emptyLine bool
+}
+
+func newLayout(decorations *decorations) *layout {
+	return &layout{
+		decorations: decorations,
+		origins:     make(map[ast.Node]ast.Node),
+		placed:      make(map[*ast.CommentGroup]*ast.CommentGroup),
+		starts:      make(map[ast.Node]nodeStart),
+		blocks:      make(map[*ast.FieldList]bool),
+		order:       make(map[token.Pos]int),
+	}
+}
+
+// Returns a copy of `file` with new positions and the file set the positions belong to.
+func (l *layout) print(file *ast.File) (*token.FileSet, *ast.File) {
+	cloner := &cloner{keepPositions: true, origins: l.origins}
+
+	fileCopy := cloner.copy(file).(*ast.File)
+
+	l.findRemovedLines(fileCopy)
+
+	l.visit(fileCopy, nil)
+	l.placePending(l.line+1, false)
+
+	fileCopy.Comments = l.comments
+
+	l.attachDocComments()
+
+	return l.setPositions(), fileCopy
+}
+
+// Finds the tokens of the source code that are still in the tree
+// and the lines that only had tokens or comments that were removed.
+//
+// Lines are counted as if removed lines were deleted from the source code.
+func (l *layout) findRemovedLines(file *ast.File) {
+	keptTokens := make(map[token.Pos]bool)
+	keptComments := make(map[*ast.CommentGroup]bool)
+
+	var walk func(node, parent ast.Node)
+
+	walk = func(node, parent ast.Node) {
+		for _, decoration := range l.decorations.comments[l.original(node)] {
+			keptComments[decoration.group] = true
+		}
+
+		original := l.decorations.parsed[l.original(node)]
+
+		for _, part := range partsOf(node, parent) {
+			if part.node != nil {
+				walk(part.node, node)
+			} else if original && part.pos.IsValid() {
+				keptTokens[*part.pos] = true
+			}
+		}
+	}
+
+	walk(file, nil)
+
+	positions := make([]token.Pos, 0, len(keptTokens))
+
+	for pos := range keptTokens {
+		positions = append(positions, pos)
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+
+	for i, pos := range positions {
+		l.order[pos] = i
+	}
+
+	removed := make([]bool, l.decorations.lines+2)
+	kept := make([]bool, l.decorations.lines+2)
+
+	mark := func(lines []bool, from, to int) {
+		for line := from; line <= to && line < len(lines); line++ {
+			lines[line] = true
+		}
+	}
+
+	for pos, endLine := range l.decorations.tokens {
+		if keptTokens[pos] {
+			mark(kept, l.decorations.line(pos), endLine)
+		} else {
+			mark(removed, l.decorations.line(pos), endLine)
+		}
+	}
+
+	for _, decorations := range l.decorations.comments {
+		for _, decoration := range decorations {
+			from, to := l.decorations.line(decoration.group.Pos()), l.decorations.line(decoration.group.End())
+
+			if keptComments[decoration.group] {
+				mark(kept, from, to)
+			} else {
+				mark(removed, from, to)
+			}
+		}
+	}
+
+	l.removedLines = make([]int, len(removed))
+
+	for line := 1; line < len(removed); line++ {
+		l.removedLines[line] = l.removedLines[line-1]
+
+		if removed[line-1] && !kept[line-1] {
+			l.removedLines[line]++
+		}
+	}
+}
+
+// Returns true if there was an empty line right before the token at `pos`
+// and the comments that will be placed before it in the source code,
+// and the number of lines the comments take.
+func (l *layout) emptyLineBefore(pos token.Pos) (int, bool) {
+	line := l.decorations.line(pos)
+	top := line
+
+	for _, decoration := range l.pending {
+		if commentLine := l.decorations.line(decoration.group.Pos()); commentLine < top {
+			top = commentLine
+		}
+	}
+
+	return line - top, top > 1 && !l.decorations.occupied[top-1]
+}
+
+// Returns true if the token at `pos` comes right after the last token placed in the source code.
+func (l *layout) follows(pos token.Pos) bool {
+	index, ok := l.order[pos]
+	if !ok {
+		return false
+	}
+
+	if !l.lastPos.IsValid() {
+		return index == 0
+	}
+
+	return index == l.order[l.lastPos]+1
+}
+
+// Returns the line of the source code as if removed lines were deleted.
+func (l *layout) compressedLine(line int) int {
+	if line < len(l.removedLines) {
+		return line - l.removedLines[line]
+	}
+
+	return line
+}
+
+func (l *layout) original(node ast.Node) ast.Node {
+	return l.origins[node]
+}
+
+func (l *layout) decorationsOf(node ast.Node, placement commentPlacement, token string) []decoration {
+	out := make([]decoration, 0)
+
+	for _, decoration := range l.decorations.comments[l.original(node)] {
+		if decoration.placement == placement && decoration.token == token {
+			out = append(out, decoration)
+		}
+	}
+
+	return out
+}
+
+func (l *layout) visit(node, parent ast.Node) {
+	if isNilNode(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.StructType:
+		l.blocks[node.Fields] = true
+	case *ast.InterfaceType:
+		l.blocks[node.Methods] = true
+	}
+
+	l.entered = append(l.entered, node)
+	l.pending = append(l.pending, l.decorationsOf(node, commentBefore, "")...)
+
+	if l.decorations.emptyLines[l.original(node)] {
+		l.emptyLine = true
+	}
+
+	open, list, close := listFields(node)
+
+	// Line of the token that opens the list.
+	opening := 0
+
+	elements := make([]ast.Node, 0)
+
+	for _, part := range partsOf(node, parent) {
+		if part.node != nil {
+			if part.field == list {
+				l.setHint(l.elementHint(node, part.index, opening, elements))
+
+				l.keepEmptyLine = part.index > 0 && l.isBlock(node)
+
+				elements = append(elements, part.node)
+			}
+
+			l.visit(part.node, node)
+
+			continue
+		}
+
+		if part.field == close && len(elements) > 0 {
+			l.setHint(l.closingHint(node, opening, elements))
+		}
+
+		l.pending = append(l.pending, l.decorationsOf(node, commentBefore, part.field)...)
+
+		l.placeToken(node, part)
+
+		if part.field == open {
+			opening = l.tokenLine
+		}
+	}
+
+	l.placeAfter(l.decorationsOf(node, commentAfter, ""))
+}
+
+// Returns the names of the fields that hold the token that opens a list of elements,
+// the list and the token that closes the list.
+func listFields(node ast.Node) (string, string, string) {
+	switch node.(type) {
+	case *ast.File:
+		return "", "Decls", ""
+	case *ast.BlockStmt:
+		return "Lbrace", "List", "Rbrace"
+	case *ast.CompositeLit:
+		return "Lbrace", "Elts", "Rbrace"
+	case *ast.CaseClause, *ast.CommClause:
+		return "Colon", "Body", ""
+	case *ast.GenDecl:
+		return "Lparen", "Specs", "Rparen"
+	case *ast.FieldList:
+		return "Opening", "List", "Closing"
+	case *ast.CallExpr:
+		return "Lparen", "Args", "Rparen"
+	}
+
+	return "", "", ""
+}
+
+// Returns true if each element of the list is printed in a line of its own.
+func (l *layout) isBlock(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.File, *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+		return true
+	case *ast.GenDecl:
+		return node.Lparen.IsValid() || len(node.Specs) > 1
+	case *ast.FieldList:
+		return l.blocks[node]
+	}
+
+	return false
+}
+
+// Returns true if the elements of the list started in a line after the token
+// that opens the list in the source code.
+func (l *layout) brokenInSource(node ast.Node) bool {
+	original := l.original(node)
+
+	if !l.decorations.parsed[original] {
+		return false
+	}
+
+	var opening token.Pos
+
+	var elements []ast.Node
+
+	switch original := original.(type) {
+	case *ast.CompositeLit:
+		opening = original.Lbrace
+		for _, element := range original.Elts {
+			elements = append(elements, element)
+		}
+	case *ast.CallExpr:
+		opening = original.Lparen
+		for _, element := range original.Args {
+			elements = append(elements, element)
+		}
+	case *ast.FieldList:
+		opening = original.Opening
+		for _, element := range original.List {
+			elements = append(elements, element)
+		}
+	}
+
+	if !opening.IsValid() || len(elements) == 0 || !elements[0].Pos().IsValid() {
+		return false
+	}
+
+	return l.decorations.line(elements[0].Pos()) > l.decorations.line(opening)
+}
+
+func (l *layout) elementHint(node ast.Node, index int, opening int, elements []ast.Node) lineHint {
+	if l.isBlock(node) {
+		return newLineIfMoved
+	}
+
+	if index == 0 {
+		if l.brokenInSource(node) {
+			return newLineIfMoved
+		}
+
+		return noLineHint
+	}
+
+	if opening > 0 && l.starts[elements[0]].line > opening {
+		return newLineIfMoved
+	}
+
+	return noLineHint
+}
+
+func (l *layout) closingHint(node ast.Node, opening int, elements []ast.Node) lineHint {
+	last := l.starts[elements[len(elements)-1]]
+
+	if opening == 0 || last.line <= opening {
+		return noLineHint
+	}
+
+	if l.isBlock(node) || last.moved {
+		return newLine
+	}
+
+	return noLineHint
+}
+
+func (l *layout) setHint(hint lineHint) {
+	if hint > l.hint {
+		l.hint = hint
+	}
+}
+
+// Returns the text of the token at the position held by `field`.
+//
+// Only identifiers and literals are returned, other tokens are assumed
+// to be one character long because only their order matters.
+func tokenText(node ast.Node, field string) string {
+	switch node := node.(type) {
+	case *ast.Ident:
+		if field == "NamePos" && node.Name != "" {
+			return node.Name
+		}
+	case *ast.BasicLit:
+		if field == "ValuePos" && node.Value != "" {
+			return node.Value
+		}
+	}
+
+	return " "
+}
+
+func (l *layout) placeToken(node ast.Node, part part) {
+	original := l.decorations.parsed[l.original(node)]
+
+	from := *part.pos
+
+	if !from.IsValid() && (original || presencePositions[reflect.TypeOf(node).Elem()][part.field]) {
+		// The token is not in the source code or the position only tells that the token is not present.
+		return
+	}
+
+	inOrder := original && l.follows(from)
+
+	line, column := l.line, l.column+1
+
+	if inOrder {
+		position := l.decorations.fileSet.Position(from)
+
+		if l.lastPos.IsValid() {
+			line = l.lastPlaced + l.compressedLine(position.Line) - l.compressedLine(l.lastLine)
+		} else {
+			line = l.compressedLine(position.Line)
+		}
+
+		column = position.Column
+	}
+
+	if l.hint == newLine || (l.hint == newLineIfMoved && !inOrder) || l.lineBreak {
+		if line <= l.line {
+			line = l.line + 1
+
+			// Statements and declarations that were moved keep the empty line that was before them.
+			if original && l.keepEmptyLine {
+				if lines, ok := l.emptyLineBefore(from); ok {
+					line = l.line + 2 + lines
+				}
+			}
+		}
+	}
+
+	l.hint = noLineHint
+	l.keepEmptyLine = false
+
+	if line < l.line {
+		line = l.line
+	}
+
+	if line < 1 {
+		line = 1
+	}
+
+	line = l.placePending(line, l.emptyLine && l.line > 0)
+
+	l.emptyLine = false
+
+	if line > l.line && !inOrder {
+		column = 1
+	}
+
+	if line == l.line && column <= l.column {
+		column = l.column + 1
+	}
+
+	text := tokenText(node, part.field)
+
+	l.positions = append(l.positions, placedPosition{pos: part.pos, line: line, column: column, width: len(text)})
+
+	for _, node := range l.entered {
+		l.starts[node] = nodeStart{line: line, moved: !inOrder}
+	}
+
+	l.entered = l.entered[:0]
+
+	l.line, l.column = line, column
+	l.advance(text)
+	l.tokenLine = l.line
+	l.lineBreak = false
+
+	if original && from.IsValid() {
+		l.lastPos = from
+		l.lastLine = l.decorations.fileSet.Position(from).Line + strings.Count(text, "\n")
+		l.lastPlaced = l.line
+	}
+}
+
+// Moves the current line and column to the last character of `text`,
+// which starts at the current line and column.
+func (l *layout) advance(text string) {
+	if newLines := strings.Count(text, "\n"); newLines > 0 {
+		l.line += newLines
+		l.column = len(text) - strings.LastIndex(text, "\n") - 1
+		return
+	}
+
+	l.column += len(text) - 1
+}
+
+// Places the comments that come before a token that will be placed at `line`
+// and returns the line the token should be placed at.
+//
+// If `emptyLine` is true, the comments and the token start after an empty line.
+func (l *layout) placePending(line int, emptyLine bool) int {
+	pending := l.pending
+	l.pending = nil
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].group.Pos() < pending[j].group.Pos()
+	})
+
+	for _, decoration := range pending {
+		if l.placed[decoration.group] != nil {
+			continue
+		}
+
+		start := line - decoration.lines
+
+		minimum := l.line
+		if decoration.ownLine || l.lineBreak || l.line == 0 {
+			minimum = l.line + 1
+		}
+
+		if emptyLine {
+			minimum = l.line + 2
+			emptyLine = false
+		}
+
+		if start < minimum {
+			line += minimum - start
+			start = minimum
+		}
+
+		l.placeGroup(decoration.group, start)
+	}
+
+	if emptyLine && line < l.line+2 {
+		line = l.line + 2
+	}
+
+	if l.lineBreak && line <= l.line {
+		line = l.line + 1
+	}
+
+	return line
+}
+
+// Places the comments that come after a node.
+func (l *layout) placeAfter(decorations []decoration) {
+	for _, decoration := range decorations {
+		if l.placed[decoration.group] != nil {
+			continue
+		}
+
+		start := l.tokenLine + decoration.lines
+
+		minimum := l.line
+		if decoration.lines > 0 || l.lineBreak {
+			minimum = l.line + 1
+		}
+
+		if start < minimum {
+			start = minimum
+		}
+
+		l.placeGroup(decoration.group, start)
+	}
+}
+
+// Places a copy of `group` starting at `line`.
+func (l *layout) placeGroup(group *ast.CommentGroup, line int) {
+	groupCopy := &ast.CommentGroup{List: make([]*ast.Comment, 0, len(group.List))}
+
+	first := l.decorations.fileSet.Position(group.Pos()).Line
+
+	for _, comment := range group.List {
+		position := l.decorations.fileSet.Position(comment.Pos())
+
+		commentLine := line + position.Line - first
+		column := position.Column
+
+		if commentLine < l.line {
+			commentLine = l.line
+		}
+
+		if commentLine == l.line && column <= l.column {
+			column = l.column + 1
+		}
+
+		commentCopy := &ast.Comment{Text: comment.Text}
+
+		groupCopy.List = append(groupCopy.List, commentCopy)
+
+		l.positions = append(l.positions, placedPosition{pos: &commentCopy.Slash, line: commentLine, column: column, width: len(comment.Text)})
+
+		l.line, l.column = commentLine, column
+		l.advance(comment.Text)
+		l.lineBreak = strings.HasPrefix(comment.Text, "//")
+	}
+
+	l.comments = append(l.comments, groupCopy)
+	l.placed[group] = groupCopy
+}
+
+// Makes doc comments and line comments of the copies point to the placed comments.
+func (l *layout) attachDocComments() {
+	for copied, original := range l.origins {
+		copiedValue := reflect.ValueOf(copied).Elem()
+		originalValue := reflect.ValueOf(original).Elem()
+
+		for i := 0; i < originalValue.NumField(); i++ {
+			if originalValue.Type().Field(i).Type != commentGroupType || originalValue.Field(i).IsNil() {
+				continue
+			}
+
+			if group := l.placed[originalValue.Field(i).Interface().(*ast.CommentGroup)]; group != nil {
+				copiedValue.Field(i).Set(reflect.ValueOf(group))
+			}
+		}
+	}
+}
+
+// Creates a file with the lines tokens and comments were placed at and sets their positions.
+func (l *layout) setPositions() *token.FileSet {
+	lines := 0
+
+	for _, position := range l.positions {
+		if position.line > lines {
+			lines = position.line
+		}
+	}
+
+	// Lines are wide enough for their tokens and comments, even the ones that span multiple lines.
+	widths := make([]int, lines+1)
+
+	for _, position := range l.positions {
+		width := position.column + position.width + 1
+
+		if width > widths[position.line] {
+			widths[position.line] = width
+		}
+	}
+
+	offsets := make([]int, 0, lines)
+	size := 0
+
+	for line := 1; line <= lines; line++ {
+		offsets = append(offsets, size)
+		size += widths[line] + 1
+	}
+
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", -1, size+1)
+	file.SetLines(offsets)
+
+	for _, position := range l.positions {
+		*position.pos = file.Pos(offsets[position.line-1] + position.column - 1)
+	}
+
+	return fileSet
+}
@@ -0,0 +1,693 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A composite literal, like []int{1, 2} or map[string]int{"a": 1}.
+type CompositeLiteral struct {
+	Node   *ast.CompositeLit
+	file   *SourceFile
+	cursor *Cursor
+}
+
+// A struct literal, like http.Server{Addr: ":8080"}.
+type Struct struct {
+	CompositeLiteral
+}
+
+// A map literal, like map[string]int{"a": 1}.
+type Map struct {
+	CompositeLiteral
+	Expr NodeWithParent
+}
+
+// A slice or array literal, like []string{"a", "b"} or [2]int{1, 2}.
+type Slice struct {
+	CompositeLiteral
+}
+
+type Value struct {
+	Expr ast.Expr
+}
+
+// Returns the cursor that points to the literal.
+func (literal *CompositeLiteral) Cursor() *Cursor {
+	return literal.cursor
+}
+
+// Returns the innermost scope the literal is in.
+func (literal *CompositeLiteral) Scope() Scope {
+	cursor := literal.cursor
+	// Literals returned by Assignment.Struct don't have a cursor.
+	if cursor == nil {
+		cursor = literal.file.cursorOf(literal.Node)
+	}
+
+	if cursor == nil {
+		return Scope{file: literal.file}
+	}
+
+	return scopeOf(cursor)
+}
+
+// Returns the file, line and column where the literal starts.
+func (literal *CompositeLiteral) Position() token.Position {
+	return literal.file.nodePosition(literal.Node)
+}
+
+// Returns the elements of the literal, which are Key: value pairs in map literals.
+func (literal *CompositeLiteral) Elements() []ast.Expr {
+	return literal.Node.Elts
+}
+
+// Returns the number of elements in the literal.
+func (literal *CompositeLiteral) Len() int {
+	return len(literal.Node.Elts)
+}
+
+// Adds `elements` after the other elements.
+func (literal *CompositeLiteral) Append(elements ...ast.Expr) {
+	literal.Node.Elts = append(literal.Node.Elts, elements...)
+}
+
+// Sorts the elements of the literal with `less`, keeping equal elements in the order they were in.
+func (literal *CompositeLiteral) Sort(less func(a, b ast.Expr) bool) {
+	sort.SliceStable(literal.Node.Elts, func(i, j int) bool {
+		return less(literal.Node.Elts[i], literal.Node.Elts[j])
+	})
+}
+
+// Returns the map literals of type `mapType`, like map[string]int,
+// in the order they appear in the file.
+//
+// Types are compared as they are written in the source code,
+// map literals with elided types, like the values of map[string]map[string]int{"a": {}},
+// are found as well.
+//
+// Panics if `mapType` is not a valid map type.
+func (code *SourceFile) MapLiterals(mapType string, matchers ...Matcher) []Map {
+	typ := mustParseLiteralType(mapType)
+	if _, ok := typ.(*ast.MapType); !ok {
+		panic(errors.Errorf("%s is not a map type", mapType))
+	}
+
+	out := make([]Map, 0)
+
+	for _, match := range code.find("CompositeLit", matchers) {
+		if hasLiteralType(match.Cursor, typ) {
+			out = append(out, newMap(code, match.Cursor))
+		}
+	}
+
+	return out
+}
+
+// Returns the map literal of type `mapType` that appears first in the file and the scope it is in.
+//
+// Returns nil if there's no map literal of type `mapType`.
+func (code *SourceFile) FindMapLiteral(mapType string, matchers ...Matcher) (*Scope, *Map) {
+	literals := code.MapLiterals(mapType, matchers...)
+	if len(literals) == 0 {
+		return nil, nil
+	}
+
+	scope := literals[0].Scope()
+
+	return &scope, &literals[0]
+}
+
+// Returns the map literals of type `mapType`, like map[string]int, grouped by the innermost scope they are in.
+//
+// See MapLiterals to visit them in the order they appear in.
+func (code *SourceFile) FindMapLiterals(mapType string, matchers ...Matcher) map[Scope][]Map {
+	out := make(map[Scope][]Map)
+
+	for _, m := range code.MapLiterals(mapType, matchers...) {
+		scope := m.Scope()
+		out[scope] = append(out[scope], m)
+	}
+
+	return out
+}
+
+// Returns the slice and array literals of type `sliceType`, like []string or [2]int,
+// in the order they appear in the file.
+//
+// Types are compared as they are written in the source code, slice literals with elided types,
+// like the elements of [][]int{{1}, {2}}, are found as well.
+//
+// Panics if `sliceType` is not a valid slice or array type.
+func (code *SourceFile) SliceLiterals(sliceType string, matchers ...Matcher) []Slice {
+	typ := mustParseLiteralType(sliceType)
+	if _, ok := typ.(*ast.ArrayType); !ok {
+		panic(errors.Errorf("%s is not a slice or array type", sliceType))
+	}
+
+	out := make([]Slice, 0)
+
+	for _, match := range code.find("CompositeLit", matchers) {
+		if hasLiteralType(match.Cursor, typ) {
+			out = append(out, Slice{CompositeLiteral{Node: match.Node.(*ast.CompositeLit), file: code, cursor: match.Cursor}})
+		}
+	}
+
+	return out
+}
+
+func newMap(code *SourceFile, cursor *Cursor) Map {
+	return Map{
+		CompositeLiteral: CompositeLiteral{Node: cursor.Node().(*ast.CompositeLit), file: code, cursor: cursor},
+		Expr:             cursor.nodeWithParent(),
+	}
+}
+
+// Returns true if the map has an element with the key `key`.
+//
+// Keys are written as Go source code, like "name", 1 or http.MethodGet.
+// The quotes may be left out for string keys, name matches "name".
+func (m *Map) Has(key string) bool {
+	return m.element(key) != nil
+}
+
+// Returns the value of the element with the key `key`. See Has.
+func (m *Map) Get(key string) (ast.Expr, bool) {
+	element := m.element(key)
+	if element == nil {
+		return nil, false
+	}
+
+	return element.Value, true
+}
+
+// Sets the value of the element with the key `key` to `value`,
+// adding the element after the other elements if the map does not have it.
+//
+// Keys of elements that are added are written as Go source code, like "name" or http.MethodGet.
+// The quotes may be left out in maps with string keys, Set("name", value) adds "name",
+// unless name is declared where the map is, like a constant.
+//
+// Returns error if the key of an element that is added is not a valid expression.
+func (m *Map) Set(key string, value ast.Expr) error {
+	if element := m.element(key); element != nil {
+		m.file.MoveComments(element.Value, value)
+
+		element.Value = value
+
+		return nil
+	}
+
+	keyExpr, err := m.newKey(key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	m.Append(&ast.KeyValueExpr{Key: keyExpr, Value: value})
+
+	return nil
+}
+
+// Removes the element with the key `key`. See Has.
+//
+// Returns false if the map does not have the element.
+func (m *Map) Delete(key string) bool {
+	element := m.element(key)
+	if element == nil {
+		return false
+	}
+
+	elements := make([]ast.Expr, 0, len(m.Node.Elts))
+
+	for _, existing := range m.Node.Elts {
+		if existing != element {
+			elements = append(elements, existing)
+		}
+	}
+
+	m.Node.Elts = elements
+
+	return true
+}
+
+// Changes the key of the element with the key `currentKey` to `newKey`,
+// does nothing if the map does not have the element. See Has and Set.
+//
+// String keys matched without quotes are renamed without quotes as well:
+// RenameKey("a", "b") changes "a" to "b".
+//
+// Returns error if `newKey` is not a valid expression.
+func (m *Map) RenameKey(currentKey string, newKey string) error {
+	element := m.element(currentKey)
+	if element == nil {
+		return nil
+	}
+
+	// The literal is changed instead of replaced to keep the element where it is.
+	if value, ok := stringKey(element.Key); ok && value == currentKey {
+		element.Key.(*ast.BasicLit).Value = strconv.Quote(newKey)
+		return nil
+	}
+
+	keyExpr, err := m.newKey(newKey)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	m.file.MoveComments(element.Key, keyExpr)
+
+	element.Key = keyExpr
+
+	return nil
+}
+
+// Sorts the elements of the map by their keys as they are written in the source code.
+func (m *Map) SortByKey() {
+	m.Sort(func(a, b ast.Expr) bool {
+		return keySource(a) < keySource(b)
+	})
+}
+
+// Returns `key`, written as Go source code, as the key of an element of the map.
+//
+// In maps with string keys, names that are not declared where the map is are quoted.
+func (m *Map) newKey(key string) (ast.Expr, error) {
+	expr, err := parseType(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid map key %s", key)
+	}
+
+	if ident, ok := expr.(*ast.Ident); ok && m.hasStringKeys() && !m.declares(ident.Name) {
+		return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(key)}, nil
+	}
+
+	return expr, nil
+}
+
+// Returns true if the key type of the map is string or if one of its keys is a string literal.
+func (m *Map) hasStringKeys() bool {
+	typ := m.Node.Type
+	if m.cursor != nil {
+		typ = literalType(m.cursor)
+	}
+
+	if mapType, ok := typ.(*ast.MapType); ok {
+		if ident, ok := mapType.Key.(*ast.Ident); ok && ident.Name == "string" {
+			return true
+		}
+	}
+
+	for _, element := range m.Node.Elts {
+		if keyValue, ok := element.(*ast.KeyValueExpr); ok {
+			if _, ok := stringKey(keyValue.Key); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns true if `name` is declared in the package or in a scope the map is in.
+func (m *Map) declares(name string) bool {
+	if topLevelNames(m.file.file)[name] {
+		return true
+	}
+
+	if m.file.pkg != nil && m.file.pkg.types.Scope().Lookup(name) != nil {
+		return true
+	}
+
+	for cursor := m.cursor; cursor != nil && cursor.parent != nil; cursor = cursor.parent {
+		if declaredInScope(cursor.parent.node, cursor.node, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the element with the key `key` or nil if there's none. See Has.
+func (m *Map) element(key string) *ast.KeyValueExpr {
+	for _, element := range m.Node.Elts {
+		keyValue, ok := element.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+
+		if value, ok := stringKey(keyValue.Key); ok && value == key {
+			return keyValue
+		}
+
+		if SourceCode(keyValue.Key) == key {
+			return keyValue
+		}
+	}
+
+	return nil
+}
+
+// Returns the value of `key` if it is a string literal.
+func stringKey(key ast.Expr) (string, bool) {
+	literal, ok := key.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false
+	}
+
+	value, err := strconv.Unquote(literal.Value)
+	if err != nil {
+		return "", false
+	}
+
+	return value, true
+}
+
+// Returns the key of a map element as it is written in the source code.
+func keySource(element ast.Expr) string {
+	keyValue, ok := element.(*ast.KeyValueExpr)
+	if !ok {
+		return SourceCode(element)
+	}
+
+	if value, ok := stringKey(keyValue.Key); ok {
+		return value
+	}
+
+	return SourceCode(keyValue.Key)
+}
+
+// Returns true if the slice has an element written as `element` in the source code, like "a" or 1.
+func (slice *Slice) Contains(element string) bool {
+	return slice.Index(element) != -1
+}
+
+// Returns the index of the first element written as `element` in the source code,
+// like "a" or 1, or -1 if the slice does not have the element.
+func (slice *Slice) Index(element string) int {
+	for i, existing := range slice.Node.Elts {
+		if SourceCode(existing) == element {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Replaces the element at `index` with `element`.
+//
+// Returns error if `index` is out of range.
+func (slice *Slice) Set(index int, element ast.Expr) error {
+	if index < 0 || index >= len(slice.Node.Elts) {
+		return errors.Errorf("can't set element %d: the literal has %d elements", index, len(slice.Node.Elts))
+	}
+
+	slice.file.MoveComments(slice.Node.Elts[index], element)
+
+	slice.Node.Elts[index] = element
+
+	return nil
+}
+
+// Removes the element at `index`.
+//
+// Returns error if `index` is out of range.
+func (slice *Slice) Delete(index int) error {
+	if index < 0 || index >= len(slice.Node.Elts) {
+		return errors.Errorf("can't delete element %d: the literal has %d elements", index, len(slice.Node.Elts))
+	}
+
+	elements := make([]ast.Expr, 0, len(slice.Node.Elts)-1)
+	elements = append(elements, slice.Node.Elts[:index]...)
+	elements = append(elements, slice.Node.Elts[index+1:]...)
+
+	slice.Node.Elts = elements
+
+	return nil
+}
+
+// Returns the struct literals of type `typeName` in the order they appear in the file,
+// wherever they are: in assignments, return statements, call arguments,
+// other literals and behind &, like &http.Server{}.
+//
+// `typeName` is the import path of the package that declares the type followed by
+// the name of the type, like net/http.Server, or only the name of the type
+// for types declared in the package the file belongs to.
+//
+// Literals with elided types, like the elements of []http.Server{{}, {}}, are found as well.
+// Type information is used when the file has been type checked, so literals of named slice
+// and map types and types declared in other files of a dot imported package are found too.
+//
+// Panics if `typeName` is not a valid type name.
+func (code *SourceFile) StructLiterals(typeName string, matchers ...Matcher) []Struct {
+	importPath, name := splitTypeName(typeName)
+	if !token.IsIdentifier(name) {
+		panic(errors.Errorf("invalid type name: %s", typeName))
+	}
+
+	out := make([]Struct, 0)
+
+	for _, match := range code.find("CompositeLit", matchers) {
+		if code.isLiteralOf(match.Cursor, importPath, name) {
+			out = append(out, Struct{CompositeLiteral{Node: match.Node.(*ast.CompositeLit), file: code, cursor: match.Cursor}})
+		}
+	}
+
+	return out
+}
+
+// Returns the value of the field `key` or a Value without an expression
+// if the literal does not set the field.
+func (struct_ *Struct) Field(key string) Value {
+	if element := struct_.element(key); element != nil {
+		return Value{Expr: element.Value}
+	}
+
+	return Value{}
+}
+
+// Returns true if the literal sets the field `key`.
+func (struct_ *Struct) HasField(key string) bool {
+	return struct_.element(key) != nil
+}
+
+// Sets the field `key` to `value`, adding the field after the other fields
+// if the literal does not set it yet.
+//
+// Returns error if the literal does not use field names, like T{1, 2}.
+func (struct_ *Struct) SetField(key string, value ast.Expr) error {
+	if element := struct_.element(key); element != nil {
+		struct_.file.MoveComments(element.Value, value)
+
+		element.Value = value
+
+		return nil
+	}
+
+	return struct_.AddField(key, value)
+}
+
+// Adds the field `key` with `value` after the other fields.
+//
+// Returns error if the literal already sets the field
+// or if it does not use field names, like T{1, 2}.
+func (struct_ *Struct) AddField(key string, value ast.Expr) error {
+	if !token.IsIdentifier(key) {
+		return errors.Errorf("%q is not a valid field name", key)
+	}
+
+	if struct_.HasField(key) {
+		return errors.Errorf("can't add field %s: the literal already sets it", key)
+	}
+
+	if !struct_.isKeyed() {
+		return errors.Errorf("can't add field %s: the literal does not use field names", key)
+	}
+
+	struct_.Node.Elts = append(struct_.Node.Elts, &ast.KeyValueExpr{Key: ast.NewIdent(key), Value: value})
+
+	return nil
+}
+
+// Removes the field `key` from the literal.
+//
+// Returns error if the literal does not set the field.
+func (struct_ *Struct) RemoveField(key string) error {
+	element := struct_.element(key)
+	if element == nil {
+		return errors.Errorf("can't remove field %s: the literal does not set it", key)
+	}
+
+	elements := make([]ast.Expr, 0, len(struct_.Node.Elts))
+
+	for _, existing := range struct_.Node.Elts {
+		if existing != element {
+			elements = append(elements, existing)
+		}
+	}
+
+	struct_.Node.Elts = elements
+
+	return nil
+}
+
+// Returns the element that sets the field `key`, like Key: value, or nil if there's none.
+func (struct_ *Struct) element(key string) *ast.KeyValueExpr {
+	for _, element := range struct_.Node.Elts {
+		keyValue, ok := element.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+
+		if ident, ok := keyValue.Key.(*ast.Ident); ok && ident.Name == key {
+			return keyValue
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the elements of the literal are Key: value pairs.
+// Empty literals are keyed.
+func (struct_ *Struct) isKeyed() bool {
+	for _, element := range struct_.Node.Elts {
+		if _, ok := element.(*ast.KeyValueExpr); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Splits a type name like net/http.Server into the import path and the name of the type.
+func splitTypeName(typeName string) (string, string) {
+	i := strings.LastIndex(typeName, ".")
+	if i == -1 || i < strings.LastIndex(typeName, "/") {
+		return "", typeName
+	}
+
+	return typeName[:i], typeName[i+1:]
+}
+
+// Returns true if the composite literal at `cursor` is a literal of the type `name`
+// declared in the package at `importPath`, or in the package the file belongs to
+// if `importPath` is empty.
+func (code *SourceFile) isLiteralOf(cursor *Cursor, importPath, name string) bool {
+	if typ := code.TypeOf(cursor.Node().(*ast.CompositeLit)); typ != nil {
+		// Literals with elided types in []*T{{}} have type *T.
+		if pointer, ok := typ.(*types.Pointer); ok {
+			typ = pointer.Elem()
+		}
+
+		named, ok := typ.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil || named.Obj().Name() != name {
+			return false
+		}
+
+		if importPath == "" {
+			return named.Obj().Pkg() == code.pkg.types
+		}
+
+		return named.Obj().Pkg().Path() == importPath
+	}
+
+	typ := literalType(cursor)
+
+	// Type arguments don't change which type it is.
+	switch typ := withoutTypeArgs(typ).(type) {
+	case *ast.Ident:
+		if typ.Name != name {
+			return false
+		}
+
+		dotImportPath, ok := code.dotImportedPackage(typ)
+		if importPath == "" {
+			return !ok
+		}
+
+		return ok && dotImportPath == importPath
+
+	case *ast.SelectorExpr:
+		pkg, ok := typ.X.(*ast.Ident)
+		if !ok || typ.Sel.Name != name {
+			return false
+		}
+
+		path, ok := code.importedPackage(pkg)
+
+		return ok && path == importPath
+
+	default:
+		return false
+	}
+}
+
+// Returns the type of the composite literal at `cursor` as it's written in the source code.
+//
+// The type of literals nested in slice, array and map literals may be elided,
+// like {} in []T{{}}, in which case the type comes from the type of the outer literal.
+func literalType(cursor *Cursor) ast.Expr {
+	literal := cursor.Node().(*ast.CompositeLit)
+	if literal.Type != nil {
+		return literal.Type
+	}
+
+	parent := cursor.ParentCursor()
+	isKey := false
+
+	if keyValue, ok := parent.Node().(*ast.KeyValueExpr); ok {
+		isKey = keyValue.Key == literal
+		parent = parent.ParentCursor()
+	}
+
+	if _, ok := parent.Node().(*ast.CompositeLit); !ok {
+		return nil
+	}
+
+	var elementType ast.Expr
+
+	switch typ := literalType(parent).(type) {
+	case *ast.ArrayType:
+		elementType = typ.Elt
+	case *ast.MapType:
+		if isKey {
+			elementType = typ.Key
+		} else {
+			elementType = typ.Value
+		}
+	default:
+		return nil
+	}
+
+	// &T is elided as well in []*T{{}}.
+	if pointer, ok := elementType.(*ast.StarExpr); ok {
+		return pointer.X
+	}
+
+	return elementType
+}
+
+// Parses the type of a composite literal, like map[string]int.
+//
+// Panics if `typ` is not a valid type.
+func mustParseLiteralType(typ string) ast.Expr {
+	expr, err := parseType(typ)
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid type: %s", typ))
+	}
+
+	return expr
+}
+
+// Returns true if the composite literal at `cursor` has the type `typ`,
+// comparing the types as they are written in the source code.
+func hasLiteralType(cursor *Cursor, typ ast.Expr) bool {
+	literalType := literalType(cursor)
+
+	return literalType != nil && SourceCode(literalType) == SourceCode(typ)
+}
@@ -0,0 +1,360 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SourceFile_StructLiterals(t *testing.T) {
+	t.Parallel()
+
+	t.Run("finds literals of the type anywhere in the file", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import (
+	nethttp "net/http"
+	"time"
+)
+
+type Server struct{}
+
+var servers = []nethttp.Server{
+	{Addr: ":8080"},
+}
+
+var byName = map[string]*nethttp.Server{
+	"admin": {Addr: ":9090"},
+}
+
+func newServer() *nethttp.Server {
+	return &nethttp.Server{
+		Addr:        ":80",
+		ReadTimeout: time.Second,
+	}
+}
+
+func main() {
+	listen(nethttp.Server{Addr: ":443"})
+	_ = Server{}
+	_ = struct{ server nethttp.Server }{server: nethttp.Server{}}
+}
+`)})
+		assert.NoError(t, err)
+
+		literals := file.StructLiterals("net/http.Server")
+
+		sources := make([]string, 0, len(literals))
+		for _, literal := range literals {
+			sources = append(sources, codemod.SourceCode(literal.Node))
+		}
+
+		assert.Equal(t, []string{
+			`{Addr: ":8080"}`,
+			`{Addr: ":9090"}`,
+			`nethttp.Server{Addr: ":80", ReadTimeout: time.Second}`,
+			`nethttp.Server{Addr: ":443"}`,
+			`nethttp.Server{}`,
+		}, sources)
+
+		local := file.StructLiterals("Server")
+		assert.Equal(t, 1, len(local))
+		assert.Equal(t, "Server{}", codemod.SourceCode(local[0].Node))
+
+		inMain := file.StructLiterals("net/http.Server", codemod.InFunction(codemod.IsFunction("main")))
+		assert.Equal(t, 2, len(inMain))
+
+		assert.Panics(t, func() { file.StructLiterals("net/http.") })
+	})
+
+	t.Run("edits fields", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import (
+	"net/http"
+	"time"
+)
+
+func main() {
+	server := &http.Server{
+		Addr: ":8080",
+		// Deprecated.
+		ErrorLog: nil,
+	}
+
+	_ = http.Server{":8080", nil}
+
+	_ = server
+}
+`)})
+		assert.NoError(t, err)
+
+		literals := file.StructLiterals("net/http.Server")
+		assert.Equal(t, 2, len(literals))
+
+		server := literals[0]
+
+		assert.True(t, server.HasField("Addr"))
+		assert.Equal(t, `":8080"`, codemod.SourceCode(server.Field("Addr").Expr))
+
+		assert.False(t, server.HasField("ReadHeaderTimeout"))
+		assert.Nil(t, server.Field("ReadHeaderTimeout").Expr)
+
+		assert.NoError(t, server.AddField("ReadHeaderTimeout", codemod.Expr("10 * time.Second")))
+		assert.Error(t, server.AddField("ReadHeaderTimeout", codemod.Expr("time.Second")))
+
+		assert.NoError(t, server.SetField("Addr", codemod.Expr(`":80"`)))
+		assert.NoError(t, server.SetField("IdleTimeout", codemod.Expr("time.Minute")))
+
+		assert.NoError(t, server.RemoveField("ErrorLog"))
+		assert.Error(t, server.RemoveField("ErrorLog"))
+
+		unkeyed := literals[1]
+		assert.False(t, unkeyed.HasField("Addr"))
+		assert.Error(t, unkeyed.AddField("ReadHeaderTimeout", codemod.Expr("time.Second")))
+		assert.Error(t, unkeyed.SetField("ReadHeaderTimeout", codemod.Expr("time.Second")))
+
+		expected := `package main
+
+import (
+	"net/http"
+	"time"
+)
+
+func main() {
+	server := &http.Server{
+		Addr:              ":80",
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+
+	_ = http.Server{":8080", nil}
+
+	_ = server
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("uses type information when the file is type checked", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"main.go": `package main
+
+import "net/http"
+
+type Servers []http.Server
+
+type Server struct{}
+
+var servers = Servers{{Addr: ":8080"}}
+
+var pointers = []*http.Server{{}}
+
+var local = Local{}
+`,
+			"local.go": `package main
+
+type Local struct{}
+`,
+		}))
+		assert.NoError(t, err)
+
+		file := findFile(t, files, "main.go")
+
+		assert.Equal(t, 2, len(file.StructLiterals("net/http.Server")))
+		assert.Equal(t, 1, len(file.StructLiterals("Local")))
+		assert.Equal(t, 0, len(file.StructLiterals("Server")))
+		assert.Equal(t, 0, len(file.StructLiterals("net/http.Client")))
+	})
+}
+
+func Test_Assignment_Struct(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func main() {
+	config := &Config{Name: "app"}
+	_ = config
+}
+`)})
+	assert.NoError(t, err)
+
+	for _, assignments := range file.FindAssignments("config") {
+		config := assignments[0].Struct()
+
+		assert.Equal(t, `"app"`, codemod.SourceCode(config.Field("Name").Expr))
+		assert.Nil(t, config.Field("Version").Expr)
+	}
+}
+
+func Test_SourceFile_MapLiterals(t *testing.T) {
+	t.Parallel()
+
+	sourceCode := []byte(`package main
+
+import "net/http"
+
+const admin = "admin"
+
+var config = struct{ Name string }{Name: "app"}
+
+var ports = []int{80, 443}
+
+var handlers = map[string]http.HandlerFunc{
+	http.MethodPost: create,
+	// Lists the users.
+	http.MethodGet: list,
+}
+
+var roles = map[string]int{
+	"user": 1,
+	admin:  2,
+}
+
+var nested = map[int]map[string]int{
+	1: {"b": 2, "a": 1},
+}
+`)
+
+	t.Run("finds map literals by type", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: sourceCode})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1, len(file.MapLiterals("map[string]http.HandlerFunc")))
+		assert.Equal(t, 2, len(file.MapLiterals("map[string]int")))
+		assert.Equal(t, 0, len(file.MapLiterals("map[string]string")))
+
+		assert.Panics(t, func() { file.MapLiterals("[]string") })
+	})
+
+	t.Run("edits elements with any key", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: sourceCode})
+		assert.NoError(t, err)
+
+		handlers := file.MapLiterals("map[string]http.HandlerFunc")[0]
+
+		assert.True(t, handlers.Has("http.MethodGet"))
+		assert.False(t, handlers.Has("http.MethodPut"))
+
+		assert.NoError(t, handlers.Set("http.MethodPut", codemod.Expr("update")))
+		assert.NoError(t, handlers.Set("http.MethodGet", codemod.Expr("listAll")))
+		assert.Error(t, handlers.Set("http.", codemod.Expr("update")))
+
+		assert.True(t, handlers.Delete("http.MethodPost"))
+		assert.False(t, handlers.Delete("http.MethodPost"))
+
+		roles := file.MapLiterals("map[string]int")[0]
+
+		assert.True(t, roles.Has("user"))
+		assert.True(t, roles.Has(`"user"`))
+		assert.True(t, roles.Has("admin"))
+
+		value, ok := roles.Get("admin")
+		assert.True(t, ok)
+		assert.Equal(t, "2", codemod.SourceCode(value))
+
+		assert.NoError(t, roles.RenameKey("user", "member"))
+		assert.NoError(t, roles.RenameKey("admin", "administrator"))
+		assert.NoError(t, roles.Set(`"guest"`, codemod.Expr("0")))
+		assert.NoError(t, roles.Set("owner", codemod.Expr("3")))
+		assert.NoError(t, roles.Set("admin", codemod.Expr("2")))
+
+		assert.Error(t, handlers.RenameKey("http.MethodGet", "http."))
+
+		nested := file.MapLiterals("map[string]int")[1]
+		nested.SortByKey()
+
+		expected := `package main
+
+import "net/http"
+
+const admin = "admin"
+
+var config = struct{ Name string }{Name: "app"}
+
+var ports = []int{80, 443}
+
+var handlers = map[string]http.HandlerFunc{
+	// Lists the users.
+	http.MethodGet: listAll,
+	http.MethodPut: update,
+}
+
+var roles = map[string]int{
+	"member":        1,
+	"administrator": 2,
+	"guest":         0,
+	"owner":         3,
+	admin:           2,
+}
+
+var nested = map[int]map[string]int{
+	1: {"a": 1, "b": 2},
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+}
+
+func Test_SourceFile_SliceLiterals(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+var m = map[string]string{"a": "b"}
+
+var s = struct{}{}
+
+var names = []string{"c", "a", "b"}
+
+var matrix = [][]int{{3, 1}, {2}}
+
+var pair = [2]int{1, 2}
+`)})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(file.SliceLiterals("[2]int")))
+	assert.Equal(t, 1, len(file.SliceLiterals("[][]int")))
+	assert.Panics(t, func() { file.SliceLiterals("map[string]string") })
+
+	names := file.SliceLiterals("[]string")[0]
+
+	assert.True(t, names.Contains(`"a"`))
+	assert.Equal(t, 2, names.Index(`"b"`))
+	assert.Equal(t, -1, names.Index(`"d"`))
+
+	names.Append(codemod.Expr(`"d"`))
+	names.Sort(func(a, b ast.Expr) bool {
+		return codemod.SourceCode(a) < codemod.SourceCode(b)
+	})
+
+	assert.NoError(t, names.Delete(0))
+	assert.Error(t, names.Delete(10))
+	assert.NoError(t, names.Set(0, codemod.Expr(`"e"`)))
+	assert.Error(t, names.Set(-1, codemod.Expr(`"e"`)))
+	assert.Equal(t, 3, names.Len())
+
+	rows := file.SliceLiterals("[]int")
+	assert.Equal(t, 2, len(rows))
+	assert.NoError(t, rows[0].Delete(0))
+
+	expected := `package main
+
+var m = map[string]string{"a": "b"}
+
+var s = struct{}{}
+
+var names = []string{"e", "c", "d"}
+
+var matrix = [][]int{{1}, {2}}
+
+var pair = [2]int{1, 2}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
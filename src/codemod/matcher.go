@@ -0,0 +1,186 @@
+package codemod
+
+import (
+	"go/ast"
+)
+
+// Decides whether the node a cursor points to is a node a codemod is looking for.
+//
+// Matchers never panic, whatever the shape of the node is,
+// so they can be shared between codemods and combined with
+// Not, AnyOf, AllOf, HasArg, InFunction and Descendant.
+//
+// Matchers can be passed to Find, TraverseAst and to the finders,
+// like FunctionCalls, to only return the nodes they match.
+type Matcher func(cursor *Cursor) bool
+
+// Returns true if `cursor` points to a node matched by every matcher in `matchers`.
+func matchesAll(cursor *Cursor, matchers []Matcher) bool {
+	for _, matcher := range matchers {
+		if !matcher(cursor) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the nodes matched by every matcher in `matchers`,
+// in the order they appear in the source file.
+func (code *SourceFile) Find(matchers ...Matcher) []Match {
+	out := make([]Match, 0)
+
+	code.Walk(func(cursor *Cursor) bool {
+		if matchesAll(cursor, matchers) {
+			out = append(out, code.match(cursor))
+		}
+
+		return true
+	})
+
+	return out
+}
+
+// Returns the nodes matched by `query` and by every matcher in `matchers`.
+func (code *SourceFile) find(query string, matchers []Matcher) []Match {
+	out := make([]Match, 0)
+
+	for _, match := range code.mustQuery(query) {
+		if matchesAll(match.Cursor, matchers) {
+			out = append(out, match)
+		}
+	}
+
+	return out
+}
+
+// Matches calls to the function `name`, like fmt.Println or println.
+//
+// The function is compared as it appears in the source code,
+// so a call to fmt.Println from a file that imports fmt with another name
+// is not matched. Calls to generic functions are matched with or without
+// their type arguments, IsCall("Map") and IsCall("Map[int]") match Map[int](xs).
+func IsCall(name string) Matcher {
+	return func(cursor *Cursor) bool {
+		call, ok := cursor.Node().(*ast.CallExpr)
+
+		return ok && callsName(call, name)
+	}
+}
+
+// Returns true if `call` calls `name` as it appears in the source code,
+// with or without the type arguments of the call.
+func callsName(call *ast.CallExpr, name string) bool {
+	if call.Fun == nil {
+		return false
+	}
+
+	return SourceCode(call.Fun) == name || SourceCode(withoutTypeArgs(call.Fun)) == name
+}
+
+// Matches calls to `funcName` from the package at `importPath`,
+// whatever the name the package is imported as. See FunctionCall.IsCallTo.
+func IsCallTo(importPath, funcName string) Matcher {
+	return func(cursor *Cursor) bool {
+		call, ok := cursor.Node().(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+
+		functionCall := FunctionCall{Node: call, file: cursor.file, cursor: cursor}
+
+		return functionCall.IsCallTo(importPath, funcName)
+	}
+}
+
+// Matches function declarations called `name`.
+// Methods are matched by their name, without the receiver.
+func IsFunction(name string) Matcher {
+	return func(cursor *Cursor) bool {
+		funcDecl, ok := cursor.Node().(*ast.FuncDecl)
+
+		return ok && funcDecl.Name != nil && funcDecl.Name.Name == name
+	}
+}
+
+// Matches calls whose argument at `index` is matched by `matcher`.
+func HasArg(index int, matcher Matcher) Matcher {
+	return func(cursor *Cursor) bool {
+		call, ok := cursor.Node().(*ast.CallExpr)
+		if !ok || index < 0 || index >= len(call.Args) {
+			return false
+		}
+
+		return matcher(&Cursor{
+			node:   call.Args[index],
+			parent: cursor,
+			name:   "Args",
+			index:  index,
+			file:   cursor.file,
+		})
+	}
+}
+
+// Matches nodes that are inside of a function declaration or a function literal matched by `matcher`.
+func InFunction(matcher Matcher) Matcher {
+	return func(cursor *Cursor) bool {
+		for ancestor := cursor.parent; ancestor != nil; ancestor = ancestor.parent {
+			switch ancestor.Node().(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				if matcher(ancestor) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// Matches nodes that have a node matched by `matcher` inside of them.
+func Descendant(matcher Matcher) Matcher {
+	return func(cursor *Cursor) bool {
+		found := false
+
+		walk(cursor, func(descendant *Cursor) bool {
+			if found {
+				return false
+			}
+
+			if descendant != cursor && matcher(descendant) {
+				found = true
+			}
+
+			return !found
+		})
+
+		return found
+	}
+}
+
+// Matches nodes that are not matched by `matcher`.
+func Not(matcher Matcher) Matcher {
+	return func(cursor *Cursor) bool {
+		return !matcher(cursor)
+	}
+}
+
+// Matches nodes matched by at least one matcher in `matchers`.
+func AnyOf(matchers ...Matcher) Matcher {
+	return func(cursor *Cursor) bool {
+		for _, matcher := range matchers {
+			if matcher(cursor) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Matches nodes matched by every matcher in `matchers`.
+func AllOf(matchers ...Matcher) Matcher {
+	return func(cursor *Cursor) bool {
+		return matchesAll(cursor, matchers)
+	}
+}
@@ -0,0 +1,162 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Matchers(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import (
+	"context"
+	"log"
+)
+
+func Handle(ctx context.Context) {
+	log.Println(ctx)
+	find(context.Background(), 1)
+	find(ctx, 2)
+
+	go func() {
+		find(context.TODO(), 3)
+	}()
+}
+
+func main() {
+	find(context.Background(), 4)
+	(func() {})()
+	f := funcs[0]
+	f()
+}
+`)})
+	assert.NoError(t, err)
+
+	calls := func(matchers ...codemod.Matcher) []string {
+		out := make([]string, 0)
+
+		for _, match := range file.Find(matchers...) {
+			out = append(out, codemod.SourceCode(match.Node))
+		}
+
+		return out
+	}
+
+	contextCall := codemod.AnyOf(codemod.IsCall("context.Background"), codemod.IsCall("context.TODO"))
+
+	tests := []struct {
+		description string
+		matcher     codemod.Matcher
+		expected    []string
+	}{
+		{
+			description: "calls to a function",
+			matcher:     codemod.IsCall("log.Println"),
+			expected:    []string{"log.Println(ctx)"},
+		},
+		{
+			description: "calls with an argument that matches",
+			matcher:     codemod.AllOf(codemod.IsCall("find"), codemod.HasArg(0, contextCall)),
+			expected:    []string{"find(context.Background(), 1)", "find(context.TODO(), 3)", "find(context.Background(), 4)"},
+		},
+		{
+			description: "calls inside of a function, including function literals",
+			matcher:     codemod.AllOf(codemod.IsCall("find"), codemod.InFunction(codemod.IsFunction("Handle"))),
+			expected:    []string{"find(context.Background(), 1)", "find(ctx, 2)", "find(context.TODO(), 3)"},
+		},
+		{
+			description: "calls that don't match",
+			matcher: codemod.AllOf(
+				codemod.IsCall("find"),
+				codemod.Not(codemod.HasArg(0, contextCall)),
+			),
+			expected: []string{"find(ctx, 2)"},
+		},
+		{
+			description: "nodes that have a descendant that matches",
+			matcher: codemod.AllOf(
+				codemod.InFunction(codemod.IsFunction("Handle")),
+				codemod.Descendant(codemod.IsCall("context.TODO")),
+				func(cursor *codemod.Cursor) bool {
+					_, ok := cursor.Node().(*ast.GoStmt)
+					return ok
+				},
+			),
+			expected: []string{"go func() {\n\tfind(context.TODO(), 3)\n}()"},
+		},
+		{
+			description: "arguments that don't exist",
+			matcher:     codemod.HasArg(5, codemod.IsCall("context.Background")),
+			expected:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, calls(tt.matcher), tt.description)
+	}
+
+	t.Run("can be passed to finders", func(t *testing.T) {
+		found := 0
+
+		for _, scopedCalls := range file.FunctionCalls(codemod.IsCall("find"), codemod.InFunction(codemod.IsFunction("main"))) {
+			for _, call := range scopedCalls {
+				assert.Equal(t, "find", call.FunctionName())
+				found++
+			}
+		}
+
+		assert.Equal(t, 1, found)
+
+		functions := file.Functions(codemod.Descendant(codemod.IsCall("log.Println")))
+		assert.Equal(t, 1, len(functions))
+		assert.Equal(t, "Handle", functions[0].Node.Name.Name)
+
+		visited := 0
+
+		file.TraverseAst(func(node codemod.NodeWithParent) {
+			_, ok := node.Node.(*ast.CallExpr)
+			assert.True(t, ok)
+			visited++
+		}, codemod.IsCall("f"))
+
+		assert.Equal(t, 1, visited)
+	})
+
+	t.Run("can be passed to the finders that look for a target", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func setup() {
+	handlers := map[string]int{"a": 1}
+	handlers = nil
+}
+
+func main() {
+	handlers := map[string]int{"b": 2}
+	handlers = nil
+}
+`)})
+		assert.NoError(t, err)
+
+		inMain := codemod.InFunction(codemod.IsFunction("main"))
+
+		assignments := file.FindAssignmentsInOrder("handlers", inMain)
+		assert.Equal(t, 2, len(assignments))
+		assert.Equal(t, "main", assignments[0].Scope().Name())
+
+		for scope := range file.FindAssignments("handlers", inMain) {
+			assert.Equal(t, "main", scope.Name())
+		}
+
+		scope, literal := file.FindMapLiteral("map[string]int", inMain)
+		assert.Equal(t, "main", scope.Name())
+		assert.Equal(t, `map[string]int{"b": 2}`, codemod.SourceCode(literal.Node))
+
+		literals := file.FindMapLiterals("map[string]int", inMain)
+		assert.Equal(t, 1, len(literals))
+	})
+}
@@ -0,0 +1,369 @@
+package codemod
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// Returns the source code of the file changing only the parts of the
+// original source code that were changed.
+//
+// SourceCode formats the whole file, so every part of a file that was not
+// formatted with gofmt changes even if codemods did not touch it.
+// MinimalDiffSourceCode finds the top level declarations and statements that
+// changed and puts only them back into the original source code, the rest of
+// the file stays byte for byte the same.
+//
+// Returns the same as SourceCode if the changes can't be isolated.
+func (code *SourceFile) MinimalDiffSourceCode() []byte {
+	newSourceCode := code.SourceCode()
+
+	if code.source == nil {
+		return newSourceCode
+	}
+
+	sourceCode, ok := splice(code.source, newSourceCode)
+	if !ok {
+		return newSourceCode
+	}
+
+	return sourceCode
+}
+
+// Source code and the file it was parsed from, used to find where nodes are in the bytes.
+type sourceText struct {
+	bytes []byte
+	file  *token.File
+}
+
+// A range of bytes in the source code.
+type sourceRegion struct {
+	text  *sourceText
+	start int
+	end   int
+	// The node the region was created for, if any.
+	node ast.Node
+}
+
+// A list of nodes split into regions.
+//
+// Each node region starts where the previous region ends and ends at the end of the line
+// the node ends at, so regions include comments and empty lines that come before nodes.
+type sourceList struct {
+	// Everything before the first node.
+	header sourceRegion
+	nodes  []sourceRegion
+	// Everything after the last node.
+	trailer sourceRegion
+}
+
+func parseSourceText(sourceCode []byte) (*sourceText, *ast.File, bool) {
+	fileSet := token.NewFileSet()
+
+	file, err := parser.ParseFile(fileSet, "", sourceCode, parser.ParseComments)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return &sourceText{bytes: sourceCode, file: fileSet.File(file.Pos())}, file, true
+}
+
+// Replaces the parts of `oldSourceCode` that are different in `newSourceCode`.
+//
+// Returns false if the result would not be the same code as `newSourceCode`.
+func splice(oldSourceCode, newSourceCode []byte) ([]byte, bool) {
+	oldText, oldFile, ok := parseSourceText(oldSourceCode)
+	if !ok {
+		return nil, false
+	}
+
+	newText, newFile, ok := parseSourceText(newSourceCode)
+	if !ok {
+		return nil, false
+	}
+
+	oldList, ok := oldText.fileList(oldFile)
+	if !ok {
+		return nil, false
+	}
+
+	newList, ok := newText.fileList(newFile)
+	if !ok {
+		return nil, false
+	}
+
+	buffer := bytes.Buffer{}
+
+	spliceList(&buffer, oldList, newList)
+
+	// Regions are split at line ends, which is not enough to isolate every change
+	// in unusual code, like a comment that starts at the end of a declaration
+	// and ends after it.
+	if _, err := parser.ParseFile(token.NewFileSet(), "", buffer.Bytes(), parser.ParseComments); err != nil {
+		return nil, false
+	}
+
+	if tokensKey(buffer.Bytes()) != tokensKey(newSourceCode) {
+		return nil, false
+	}
+
+	return buffer.Bytes(), true
+}
+
+// Writes the regions of `newList` that changed and the regions of `oldList` that didn't.
+func spliceList(buffer *bytes.Buffer, oldList, newList sourceList) {
+	spliceRegion(buffer, oldList.header, newList.header)
+
+	oldKeys := make([]string, 0, len(oldList.nodes))
+	for _, region := range oldList.nodes {
+		oldKeys = append(oldKeys, region.key())
+	}
+
+	newKeys := make([]string, 0, len(newList.nodes))
+	for _, region := range newList.nodes {
+		newKeys = append(newKeys, region.key())
+	}
+
+	i, j := 0, 0
+
+	for _, match := range longestCommonSubsequence(oldKeys, newKeys) {
+		spliceChanged(buffer, oldList.nodes[i:match[0]], newList.nodes[j:match[1]])
+
+		buffer.Write(oldList.nodes[match[0]].bytes())
+
+		i, j = match[0]+1, match[1]+1
+	}
+
+	spliceChanged(buffer, oldList.nodes[i:], newList.nodes[j:])
+
+	spliceRegion(buffer, oldList.trailer, newList.trailer)
+}
+
+// Writes `oldRegion` if it has the same code as `newRegion`
+// and `newRegion` otherwise.
+func spliceRegion(buffer *bytes.Buffer, oldRegion, newRegion sourceRegion) {
+	if oldRegion.key() == newRegion.key() {
+		buffer.Write(oldRegion.bytes())
+	} else {
+		buffer.Write(newRegion.bytes())
+	}
+}
+
+// Writes regions that replaced `oldRegions`.
+//
+// When each old region was replaced by one new region, statements
+// in their bodies that did not change are kept.
+func spliceChanged(buffer *bytes.Buffer, oldRegions, newRegions []sourceRegion) {
+	if len(oldRegions) != len(newRegions) {
+		for _, region := range newRegions {
+			buffer.Write(region.bytes())
+		}
+
+		return
+	}
+
+	for i := range newRegions {
+		oldBody, ok := oldRegions[i].bodyList()
+		if !ok {
+			buffer.Write(newRegions[i].bytes())
+			continue
+		}
+
+		newBody, ok := newRegions[i].bodyList()
+		if !ok {
+			buffer.Write(newRegions[i].bytes())
+			continue
+		}
+
+		spliceList(buffer, oldBody, newBody)
+	}
+}
+
+// Splits the file into the package clause, one region per declaration
+// and whatever comes after the last declaration.
+func (text *sourceText) fileList(file *ast.File) (sourceList, bool) {
+	nodes := make([]ast.Node, 0, len(file.Decls))
+	for _, decl := range file.Decls {
+		nodes = append(nodes, decl)
+	}
+
+	headerEnd := text.lineEnd(text.offset(file.Name.End()))
+
+	return text.list(0, headerEnd, len(text.bytes), len(text.bytes), nodes)
+}
+
+// Splits text.bytes[start:end] into regions.
+//
+// The header ends at `headerEnd` and no node region may end after `limit`.
+func (text *sourceText) list(start, headerEnd, limit, end int, nodes []ast.Node) (sourceList, bool) {
+	list := sourceList{
+		header: sourceRegion{text: text, start: start, end: headerEnd},
+		nodes:  make([]sourceRegion, 0, len(nodes)),
+	}
+
+	regionStart := headerEnd
+
+	for _, node := range nodes {
+		if text.offset(node.Pos()) < regionStart {
+			return list, false
+		}
+
+		regionEnd := text.lineEnd(text.offset(node.End()))
+
+		list.nodes = append(list.nodes, sourceRegion{text: text, start: regionStart, end: regionEnd, node: node})
+
+		regionStart = regionEnd
+	}
+
+	if regionStart > limit {
+		return list, false
+	}
+
+	list.trailer = sourceRegion{text: text, start: regionStart, end: end}
+
+	return list, true
+}
+
+func (text *sourceText) offset(pos token.Pos) int {
+	return text.file.Offset(pos)
+}
+
+// Returns the offset right after the end of the line that contains `offset`.
+func (text *sourceText) lineEnd(offset int) int {
+	i := bytes.IndexByte(text.bytes[offset:], '\n')
+	if i == -1 {
+		return len(text.bytes)
+	}
+
+	return offset + i + 1
+}
+
+func (region sourceRegion) bytes() []byte {
+	return region.text.bytes[region.start:region.end]
+}
+
+func (region sourceRegion) key() string {
+	return tokensKey(region.bytes())
+}
+
+// Splits the statements in the body of the node the region was created for into regions.
+func (region sourceRegion) bodyList() (sourceList, bool) {
+	body := bodyOf(region.node)
+	if body == nil {
+		return sourceList{}, false
+	}
+
+	nodes := make([]ast.Node, 0, len(body.List))
+	for _, stmt := range body.List {
+		nodes = append(nodes, stmt)
+	}
+
+	text := region.text
+
+	lbrace := text.offset(body.Lbrace)
+	rbrace := text.offset(body.Rbrace)
+
+	if lbrace < region.start || rbrace >= region.end {
+		return sourceList{}, false
+	}
+
+	return text.list(region.start, text.lineEnd(lbrace), rbrace, region.end, nodes)
+}
+
+// Returns the block of statements of nodes that have one.
+func bodyOf(node ast.Node) *ast.BlockStmt {
+	switch node := node.(type) {
+	case *ast.FuncDecl:
+		return node.Body
+	case *ast.BlockStmt:
+		return node
+	case *ast.IfStmt:
+		return node.Body
+	case *ast.ForStmt:
+		return node.Body
+	case *ast.RangeStmt:
+		return node.Body
+	}
+
+	return nil
+}
+
+// Returns a string that is the same for two pieces of source code
+// if they only differ in white space.
+//
+// Semicolons are ignored because gofmt replaces them with line breaks.
+func tokensKey(sourceCode []byte) string {
+	fileSet := token.NewFileSet()
+
+	var s scanner.Scanner
+
+	s.Init(fileSet.AddFile("", -1, len(sourceCode)), sourceCode, nil, scanner.ScanComments)
+
+	builder := strings.Builder{}
+
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		if tok == token.SEMICOLON {
+			continue
+		}
+
+		if tok == token.COMMENT {
+			// gofmt changes the indentation of comments.
+			lit = strings.Join(strings.Fields(lit), "")
+		}
+
+		builder.WriteString(tok.String())
+		builder.WriteByte(' ')
+		builder.WriteString(lit)
+		builder.WriteByte('\n')
+	}
+
+	return builder.String()
+}
+
+// Returns the indexes of the elements that are in the longest common subsequence of `a` and `b`.
+func longestCommonSubsequence(a, b []string) [][2]int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	out := make([][2]int, 0, lengths[0][0])
+
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,148 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SourceFile_MinimalDiffSourceCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		description string
+		code        string
+		f           func(*codemod.SourceFile)
+		expected    string
+	}{
+		{
+			description: "keeps the file as is when nothing changes",
+			code: `package main
+func   main()  {
+    x :=   1
+  _ = x
+}
+`,
+			f: func(*codemod.SourceFile) {},
+			expected: `package main
+func   main()  {
+    x :=   1
+  _ = x
+}
+`,
+		},
+		{
+			description: "only changes declarations that changed",
+			code: `package main
+
+var  a  =  1
+
+func foo()  {
+      log.Printf("%d", a)
+}
+
+func   bar() { }
+`,
+			f: func(file *codemod.SourceFile) {
+				codemod.Rewrite(file, "log.Printf($args...)", "logger.Infof($args...)")
+			},
+			expected: `package main
+
+var  a  =  1
+
+func foo()  {
+	logger.Infof("%d", a)
+}
+
+func   bar() { }
+`,
+		},
+		{
+			description: "only changes statements that changed",
+			code: `package main
+
+func main()  {
+    x :=  1
+    // y is two
+    y :=  2
+    z :=  3
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				for _, assignments := range file.FindAssignments("y") {
+					assignments[0].Replace(codemod.Ast("y := 4").(ast.Stmt))
+				}
+			},
+			expected: `package main
+
+func main()  {
+    x :=  1
+	// y is two
+	y := 4
+    z :=  3
+}
+`,
+		},
+		{
+			description: "removes statements that were removed",
+			code: `package main
+
+func main()  {
+    x :=  1
+    y :=  2
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				for _, assignments := range file.FindAssignments("x") {
+					assignments[0].Remove()
+				}
+			},
+			expected: `package main
+
+func main()  {
+    y :=  2
+}
+`,
+		},
+		{
+			description: "adds imports without changing the rest of the file",
+			code: `package main
+
+import (
+	"os"
+)
+
+func main()  {
+    os.Exit(1)
+}
+`,
+			f: func(file *codemod.SourceFile) {
+				file.Imports().Add("fmt")
+			},
+			expected: `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main()  {
+    os.Exit(1)
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			file, err := codemod.New(codemod.NewInput{SourceCode: []byte(tt.code)})
+			assert.NoError(t, err)
+
+			tt.f(file)
+
+			assert.Equal(t, tt.expected, string(file.MinimalDiffSourceCode()))
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package codemod
+
+import (
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A repository codemods are applied to.
+//
+// Changes made to the project are kept in memory and written to disk by Save,
+// so codemods don't need to know where the project is in the machine.
+type Project struct {
+	// Directory the project is in.
+	root string
+	// Files in the project indexed by their path relative to the root.
+	files map[string]*projectFile
+	// Paths of the files that were in the project when it was created.
+	originalPaths map[string]bool
+	// Paths of directories that were deleted, relative to the root.
+	// Files that are not in the project, like vendored files, are deleted with them.
+	deletedDirectories map[string]bool
+}
+
+type projectFile struct {
+	contents []byte
+	mode     fs.FileMode
+	// True if the contents are not the contents of the file on disk.
+	changed bool
+	// Set if the file is a Go file.
+	sourceFile *SourceFile
+}
+
+// A file in the project.
+type ProjectFile struct {
+	// Path relative to the project root.
+	Path     string
+	Contents []byte
+	Mode     fs.FileMode
+	// True if the contents are not the contents of the file on disk,
+	// which is the case when regex replacements were applied to them.
+	Changed bool
+}
+
+type NewProjectInput struct {
+	// Directory the project is in.
+	Root string
+	// Every file in the project.
+	Files []ProjectFile
+	// When true, Go files are parsed and type checked package by package.
+	TypeCheck bool
+}
+
+// Go files that belong to the same package.
+type ProjectPackage struct {
+	// Directory the package is in, relative to the project root.
+	Directory string
+	// Name in the package clause of the files.
+	Name        string
+	SourceFiles []*SourceFile
+}
+
+// Controls how Save writes files.
+type SaveOptions struct {
+	// When true, only the parts of Go files that changed are written,
+	// the rest of the file is kept as it was.
+	MinimalDiff bool
+}
+
+// Creates a project with the files in `input`, Go files are parsed.
+func NewProject(input NewProjectInput) (*Project, error) {
+	project := &Project{
+		root:               input.Root,
+		files:              make(map[string]*projectFile),
+		originalPaths:      make(map[string]bool),
+		deletedDirectories: make(map[string]bool),
+	}
+
+	inputs := make([]NewInput, 0)
+
+	for _, file := range input.Files {
+		path := filepath.Clean(file.Path)
+
+		project.files[path] = &projectFile{contents: file.Contents, mode: file.Mode, changed: file.Changed}
+		project.originalPaths[path] = true
+
+		if isGoFile(path) {
+			inputs = append(inputs, NewInput{SourceCode: file.Contents, FilePath: project.absolutePath(path)})
+		}
+	}
+
+	sourceFiles := make([]*SourceFile, 0, len(inputs))
+
+	if input.TypeCheck {
+		typeChecked, err := NewTypeChecked(inputs)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		sourceFiles = typeChecked
+	} else {
+		for _, input := range inputs {
+			sourceFile, err := New(input)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing %s", input.FilePath)
+			}
+
+			sourceFiles = append(sourceFiles, sourceFile)
+		}
+	}
+
+	for _, sourceFile := range sourceFiles {
+		project.files[project.relativePath(sourceFile.FilePath)].sourceFile = sourceFile
+	}
+
+	return project, nil
+}
+
+func isGoFile(path string) bool {
+	return strings.HasSuffix(path, ".go")
+}
+
+// Returns the directory the project is in.
+func (project *Project) Root() string {
+	return project.root
+}
+
+// Returns the Go files in the project sorted by path.
+func (project *Project) SourceFiles() []*SourceFile {
+	out := make([]*SourceFile, 0)
+
+	for _, path := range project.sortedPaths() {
+		if sourceFile := project.files[path].sourceFile; sourceFile != nil {
+			out = append(out, sourceFile)
+		}
+	}
+
+	return out
+}
+
+// Returns the Go files in the project grouped by package.
+//
+// Packages are sorted by directory and files are sorted by path.
+// External test packages are separate from the package they test.
+func (project *Project) Packages() []ProjectPackage {
+	out := make([]ProjectPackage, 0)
+
+	indexes := make(map[string]int)
+
+	for _, sourceFile := range project.SourceFiles() {
+		directory := filepath.Dir(project.relativePath(sourceFile.FilePath))
+		key := directory + "#" + sourceFile.file.Name.Name
+
+		i, ok := indexes[key]
+		if !ok {
+			i = len(out)
+			indexes[key] = i
+			out = append(out, ProjectPackage{Directory: directory, Name: sourceFile.file.Name.Name})
+		}
+
+		out[i].SourceFiles = append(out[i].SourceFiles, sourceFile)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Directory != out[j].Directory {
+			return out[i].Directory < out[j].Directory
+		}
+
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+// Returns true if there's a file or directory at `path`.
+func (project *Project) Exists(path string) bool {
+	path, err := project.cleanPath(path)
+	if err != nil {
+		return false
+	}
+
+	if _, ok := project.files[path]; ok {
+		return true
+	}
+
+	return len(project.pathsIn(path)) > 0
+}
+
+// Returns the contents of the file at `path`.
+//
+// Go files are returned with the changes made by codemods.
+func (project *Project) ReadFile(path string) ([]byte, error) {
+	path, err := project.cleanPath(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	file, ok := project.files[path]
+	if !ok {
+		return nil, errors.Errorf("file not found: %s", path)
+	}
+
+	if file.sourceFile != nil {
+		return file.sourceFile.SourceCode(), nil
+	}
+
+	return file.contents, nil
+}
+
+// Creates a file at `path` with `contents` or replaces
+// the contents of the file if it already exists.
+//
+// Directories are created as needed. Go files are parsed
+// and can be changed by source file codemods.
+func (project *Project) CreateFile(path string, contents []byte) error {
+	path, err := project.cleanPath(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	file := &projectFile{contents: contents, mode: 0644, changed: true}
+
+	if existing, ok := project.files[path]; ok {
+		file.mode = existing.mode
+	}
+
+	if isGoFile(path) {
+		sourceFile, err := New(NewInput{SourceCode: contents, FilePath: project.absolutePath(path)})
+		if err != nil {
+			return errors.Wrapf(err, "parsing %s", path)
+		}
+
+		file.sourceFile = sourceFile
+	}
+
+	project.files[path] = file
+
+	return nil
+}
+
+// Moves the file or directory at `from` to `to`.
+//
+// Fails if there's already a file at `to`.
+func (project *Project) MoveFile(from, to string) error {
+	from, err := project.cleanPath(from)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	to, err = project.cleanPath(to)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if project.Exists(to) {
+		return errors.Errorf("can't move %s to %s: %s already exists", from, to, to)
+	}
+
+	if file, ok := project.files[from]; ok {
+		project.moveFile(file, from, to)
+		return nil
+	}
+
+	paths := project.pathsIn(from)
+	if len(paths) == 0 {
+		return errors.Errorf("file not found: %s", from)
+	}
+
+	for _, path := range paths {
+		project.moveFile(project.files[path], path, filepath.Join(to, strings.TrimPrefix(path, from+string(filepath.Separator))))
+	}
+
+	return nil
+}
+
+func (project *Project) moveFile(file *projectFile, from, to string) {
+	delete(project.files, from)
+
+	project.files[to] = file
+
+	file.changed = true
+
+	if file.sourceFile != nil {
+		file.sourceFile.FilePath = project.absolutePath(to)
+	}
+}
+
+// Renames the file or directory at `path` to `name`,
+// keeping it in the same directory.
+func (project *Project) RenameFile(path, name string) error {
+	if strings.ContainsRune(name, filepath.Separator) {
+		return errors.Errorf("new name should not contain a path separator: %s", name)
+	}
+
+	if err := project.MoveFile(path, filepath.Join(filepath.Dir(path), name)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Deletes the file at `path`.
+//
+// If `path` is a directory, the directory and everything in it are deleted.
+func (project *Project) DeleteFile(path string) error {
+	path, err := project.cleanPath(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, ok := project.files[path]; ok {
+		delete(project.files, path)
+		return nil
+	}
+
+	paths := project.pathsIn(path)
+
+	info, err := os.Stat(project.absolutePath(path))
+	if len(paths) == 0 && (err != nil || !info.IsDir()) {
+		return errors.Errorf("file not found: %s", path)
+	}
+
+	for _, path := range paths {
+		delete(project.files, path)
+	}
+
+	project.deletedDirectories[path] = true
+
+	return nil
+}
+
+// Writes the changes made to the project to disk.
+//
+// Files that were created or changed are written and files
+// and directories that were deleted or moved are removed.
+// Files that did not change are not written, so changes made to them
+// on disk while codemods were applied, by running go get for example, are kept.
+func (project *Project) Save(options SaveOptions) error {
+	for _, directory := range sortedSet(project.deletedDirectories) {
+		if err := os.RemoveAll(project.absolutePath(directory)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for _, path := range sortedSet(project.originalPaths) {
+		if _, ok := project.files[path]; ok {
+			continue
+		}
+
+		if err := os.Remove(project.absolutePath(path)); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+
+		if err := project.removeEmptyDirectories(filepath.Dir(path)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for _, path := range project.sortedPaths() {
+		file := project.files[path]
+
+		contents := file.contents
+
+		if file.sourceFile != nil {
+			if options.MinimalDiff {
+				contents = file.sourceFile.MinimalDiffSourceCode()
+			} else {
+				contents = file.sourceFile.SourceCode()
+			}
+		}
+
+		if !file.changed && bytes.Equal(contents, file.contents) {
+			continue
+		}
+
+		absolutePath := project.absolutePath(path)
+
+		if err := os.MkdirAll(filepath.Dir(absolutePath), os.ModePerm); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if err := ioutil.WriteFile(absolutePath, contents, file.mode); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// Removes `directory` and its parents, up to the project root, while they are empty.
+func (project *Project) removeEmptyDirectories(directory string) error {
+	for ; directory != "."; directory = filepath.Dir(directory) {
+		entries, err := ioutil.ReadDir(project.absolutePath(directory))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if len(entries) > 0 {
+			return nil
+		}
+
+		if err := os.Remove(project.absolutePath(directory)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// Returns `path` relative to the project root.
+//
+// `path` may be relative to the root or an absolute path inside the root.
+func (project *Project) cleanPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		root, err := filepath.Abs(project.root)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+
+		path = relativePath
+	}
+
+	path = filepath.Clean(path)
+
+	if path == "." {
+		return "", errors.New("path is the project root")
+	}
+
+	if path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("path is outside of the project: %s", path)
+	}
+
+	return path, nil
+}
+
+func (project *Project) absolutePath(path string) string {
+	return filepath.Join(project.root, path)
+}
+
+func (project *Project) relativePath(path string) string {
+	relativePath, err := filepath.Rel(project.root, path)
+	if err != nil {
+		return path
+	}
+
+	return relativePath
+}
+
+// Returns the paths of the files in `directory` and its subdirectories.
+func (project *Project) pathsIn(directory string) []string {
+	out := make([]string, 0)
+
+	prefix := directory + string(filepath.Separator)
+
+	for _, path := range project.sortedPaths() {
+		if strings.HasPrefix(path, prefix) {
+			out = append(out, path)
+		}
+	}
+
+	return out
+}
+
+func (project *Project) sortedPaths() []string {
+	paths := make([]string, 0, len(project.files))
+
+	for path := range project.files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+func sortedSet(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+
+	for key := range set {
+		out = append(out, key)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
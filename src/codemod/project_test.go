@@ -0,0 +1,154 @@
+package codemod_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProject(t *testing.T, files map[string]string) *codemod.Project {
+	root := t.TempDir()
+
+	input := codemod.NewProjectInput{Root: root}
+
+	for path, contents := range files {
+		absolutePath := filepath.Join(root, path)
+
+		assert.NoError(t, os.MkdirAll(filepath.Dir(absolutePath), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(absolutePath, []byte(contents), 0644))
+
+		input.Files = append(input.Files, codemod.ProjectFile{Path: path, Contents: []byte(contents), Mode: 0644})
+	}
+
+	project, err := codemod.NewProject(input)
+	assert.NoError(t, err)
+
+	return project
+}
+
+func readProjectFile(t *testing.T, project *codemod.Project, path string) string {
+	contents, err := ioutil.ReadFile(filepath.Join(project.Root(), path))
+	assert.NoError(t, err)
+
+	return string(contents)
+}
+
+func Test_Project_Packages(t *testing.T) {
+	t.Parallel()
+
+	project := newTestProject(t, map[string]string{
+		"go.mod":              "module example.com/project",
+		"main.go":             "package main",
+		"users/users.go":      "package users",
+		"users/repo.go":       "package users",
+		"users/users_test.go": "package users_test",
+	})
+
+	packages := project.Packages()
+
+	assert.Equal(t, 3, len(packages))
+
+	assert.Equal(t, ".", packages[0].Directory)
+	assert.Equal(t, "main", packages[0].Name)
+	assert.Equal(t, 1, len(packages[0].SourceFiles))
+
+	assert.Equal(t, "users", packages[1].Directory)
+	assert.Equal(t, "users", packages[1].Name)
+	assert.Equal(t, 2, len(packages[1].SourceFiles))
+	assert.Equal(t, filepath.Join(project.Root(), "users/repo.go"), packages[1].SourceFiles[0].FilePath)
+
+	assert.Equal(t, "users", packages[2].Directory)
+	assert.Equal(t, "users_test", packages[2].Name)
+
+	assert.Equal(t, 4, len(project.SourceFiles()))
+}
+
+func Test_Project_Save(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes created files", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{"main.go": "package main\n"})
+
+		assert.NoError(t, project.CreateFile(".github/CODEOWNERS", []byte("* @owner")))
+		assert.NoError(t, project.Save(codemod.SaveOptions{}))
+
+		assert.Equal(t, "* @owner", readProjectFile(t, project, ".github/CODEOWNERS"))
+	})
+
+	t.Run("writes changes made to Go files", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{"main.go": "package main\n"})
+
+		pkg := project.SourceFiles()[0].Package()
+		pkg.SetName("foo")
+
+		assert.NoError(t, project.Save(codemod.SaveOptions{}))
+
+		assert.Equal(t, "package foo\n", readProjectFile(t, project, "main.go"))
+	})
+
+	t.Run("does not write files that did not change", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{"go.mod": "module example.com/project\n"})
+
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(project.Root(), "go.mod"), []byte("changed on disk"), 0644))
+
+		assert.NoError(t, project.Save(codemod.SaveOptions{}))
+
+		assert.Equal(t, "changed on disk", readProjectFile(t, project, "go.mod"))
+	})
+
+	t.Run("moves and renames files", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{
+			"a.txt":        "a",
+			"pkg/b.go":     "package pkg\n",
+			"pkg/sub/c.go": "package sub\n",
+		})
+
+		assert.NoError(t, project.RenameFile("a.txt", "renamed.txt"))
+		assert.NoError(t, project.MoveFile("pkg", "internal/pkg"))
+		assert.Error(t, project.MoveFile("renamed.txt", "internal/pkg/b.go"))
+
+		assert.Equal(t, filepath.Join(project.Root(), "internal/pkg/b.go"), project.SourceFiles()[0].FilePath)
+
+		assert.NoError(t, project.Save(codemod.SaveOptions{}))
+
+		assert.Equal(t, "a", readProjectFile(t, project, "renamed.txt"))
+		assert.Equal(t, "package pkg\n", readProjectFile(t, project, "internal/pkg/b.go"))
+		assert.Equal(t, "package sub\n", readProjectFile(t, project, "internal/pkg/sub/c.go"))
+
+		_, err := os.Stat(filepath.Join(project.Root(), "a.txt"))
+		assert.True(t, os.IsNotExist(err))
+
+		_, err = os.Stat(filepath.Join(project.Root(), "pkg"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("deletes files and directories", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{
+			"main.go":           "package main",
+			"infra/errors/a.go": "package errors",
+			"infra/errors/b.go": "package errors",
+		})
+
+		assert.NoError(t, project.DeleteFile("infra/errors"))
+		assert.Error(t, project.DeleteFile("does/not/exist"))
+
+		assert.False(t, project.Exists("infra/errors/a.go"))
+		assert.Equal(t, 1, len(project.SourceFiles()))
+
+		assert.NoError(t, project.Save(codemod.SaveOptions{}))
+
+		_, err := os.Stat(filepath.Join(project.Root(), "infra/errors"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("paths outside of the project are not allowed", func(t *testing.T) {
+		project := newTestProject(t, map[string]string{})
+
+		assert.Error(t, project.CreateFile("../outside.txt", []byte("")))
+		assert.Error(t, project.DeleteFile("."))
+	})
+}
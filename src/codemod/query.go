@@ -0,0 +1,492 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A node found by SourceFile.Query.
+type Match struct {
+	// The matched node, like *ast.CallExpr.
+	Node ast.Node
+	// The innermost scope the node is in, like the function or the body of the if statement.
+	Scope Scope
+	// Where the node is in the source file.
+	// Nodes added by codemods don't have a position.
+	Position token.Position
+	// Points to the node so it can be replaced, deleted or have nodes inserted next to it.
+	Cursor *Cursor
+}
+
+// Returns the nodes that match `query`, in the order they appear in the source file.
+//
+// A query is a list of selectors separated by spaces, where each selector
+// matches a node that is inside of the node matched by the previous selector,
+// or that is a direct child of it when they are separated by >.
+//
+// A selector is the name of an ast node type, like CallExpr, or * to match any node,
+// followed by optional filters on the fields of the node:
+//
+//	[name]                 the field is set
+//	[name='main']          the source code of the field is main
+//	[name!='main']         the source code of the field is not main
+//	[name=/^Handle/]       the source code of the field matches the regular expression
+//	[tok=var]              quotes may be omitted if the value has no spaces or ]
+//
+// Field names are case insensitive. For example, calls to log.Printf in functions
+// whose names start with Handle:
+//
+//	FuncDecl[name=/^Handle/] CallExpr[fun='log.Printf']
+func (code *SourceFile) Query(query string) ([]Match, error) {
+	selectors, err := parseQuery(query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid query: %s", query)
+	}
+
+	out := make([]Match, 0)
+
+	code.Walk(func(cursor *Cursor) bool {
+		if matchesSelectors(selectors, cursor) {
+			out = append(out, code.match(cursor))
+		}
+
+		return true
+	})
+
+	return out, nil
+}
+
+// Like Query but panics if the query is invalid, for queries that are known to be valid.
+func (code *SourceFile) mustQuery(query string) []Match {
+	matches, err := code.Query(query)
+	if err != nil {
+		panic(err)
+	}
+
+	return matches
+}
+
+// Returns the match for the node `cursor` points to.
+func (code *SourceFile) match(cursor *Cursor) Match {
+	return Match{Node: cursor.Node(), Scope: scopeOf(cursor), Position: code.nodePosition(cursor.Node()), Cursor: cursor}
+}
+
+// Returns the file, line and column where `node` starts
+// or the zero position if the node was added by a codemod.
+func (code *SourceFile) nodePosition(node ast.Node) token.Position {
+	if node == nil || !node.Pos().IsValid() {
+		return token.Position{}
+	}
+
+	position := code.fileSet.Position(node.Pos())
+	position.Filename = code.FilePath
+
+	return position
+}
+
+// Node types that can be used in queries.
+var queryNodeTypes = typesByName(
+	ast.ArrayType{}, ast.AssignStmt{}, ast.BadDecl{}, ast.BadExpr{}, ast.BadStmt{},
+	ast.BasicLit{}, ast.BinaryExpr{}, ast.BlockStmt{}, ast.BranchStmt{}, ast.CallExpr{},
+	ast.CaseClause{}, ast.ChanType{}, ast.CommClause{}, ast.CompositeLit{}, ast.DeclStmt{},
+	ast.DeferStmt{}, ast.Ellipsis{}, ast.EmptyStmt{}, ast.ExprStmt{}, ast.Field{},
+	ast.FieldList{}, ast.File{}, ast.ForStmt{}, ast.FuncDecl{}, ast.FuncLit{},
+	ast.FuncType{}, ast.GenDecl{}, ast.GoStmt{}, ast.Ident{}, ast.IfStmt{},
+	ast.ImportSpec{}, ast.IncDecStmt{}, ast.IndexExpr{}, ast.IndexListExpr{}, ast.InterfaceType{}, ast.KeyValueExpr{},
+	ast.LabeledStmt{}, ast.MapType{}, ast.ParenExpr{}, ast.RangeStmt{}, ast.ReturnStmt{},
+	ast.SelectStmt{}, ast.SelectorExpr{}, ast.SendStmt{}, ast.SliceExpr{}, ast.StarExpr{},
+	ast.StructType{}, ast.SwitchStmt{}, ast.TypeAssertExpr{}, ast.TypeSpec{}, ast.TypeSwitchStmt{},
+	ast.UnaryExpr{}, ast.ValueSpec{},
+)
+
+func typesByName(values ...interface{}) map[string]reflect.Type {
+	out := make(map[string]reflect.Type, len(values))
+
+	for _, value := range values {
+		typ := reflect.TypeOf(value)
+		out[typ.Name()] = typ
+	}
+
+	return out
+}
+
+// Matches nodes of a type whose fields pass every filter.
+type selector struct {
+	// nil matches any node.
+	typ     reflect.Type
+	filters []filter
+	// True if the node must be a direct child of the node matched by the previous selector.
+	child bool
+}
+
+type filter struct {
+	field string
+	// Empty when the filter only checks if the field is set.
+	op     string
+	value  string
+	regexp *regexp.Regexp
+}
+
+// Returns true if the node `cursor` points to matches the last selector
+// and its ancestors match the other selectors.
+func matchesSelectors(selectors []selector, cursor *Cursor) bool {
+	last := selectors[len(selectors)-1]
+
+	if !last.matches(cursor.Node()) {
+		return false
+	}
+
+	if len(selectors) == 1 {
+		return true
+	}
+
+	rest := selectors[:len(selectors)-1]
+
+	if last.child {
+		return cursor.parent != nil && matchesSelectors(rest, cursor.parent)
+	}
+
+	for ancestor := cursor.parent; ancestor != nil; ancestor = ancestor.parent {
+		if matchesSelectors(rest, ancestor) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (selector *selector) matches(node ast.Node) bool {
+	value := reflect.ValueOf(node)
+
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return false
+	}
+
+	if selector.typ != nil && value.Elem().Type() != selector.typ {
+		return false
+	}
+
+	for _, filter := range selector.filters {
+		if !filter.matches(value.Elem()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (filter *filter) matches(structValue reflect.Value) bool {
+	field, ok := fieldByName(structValue.Type(), filter.field)
+	if !ok {
+		return false
+	}
+
+	fieldValue := structValue.FieldByIndex(field.Index)
+
+	if filter.op == "" {
+		return !fieldValue.IsZero()
+	}
+
+	source := attributeSource(fieldValue)
+
+	var equal bool
+	if filter.regexp != nil {
+		equal = filter.regexp.MatchString(source)
+	} else {
+		equal = source == filter.value
+	}
+
+	if filter.op == "!=" {
+		return !equal
+	}
+
+	return equal
+}
+
+// Returns the field of `structType` called `name`, ignoring case.
+func fieldByName(structType reflect.Type, name string) (reflect.StructField, bool) {
+	return structType.FieldByNameFunc(func(fieldName string) bool {
+		return strings.EqualFold(fieldName, name)
+	})
+}
+
+// Returns the source code of a field of a node as used in filters.
+func attributeSource(value reflect.Value) string {
+	switch {
+	case value.Kind() == reflect.Slice:
+		elements := make([]string, 0, value.Len())
+
+		for i := 0; i < value.Len(); i++ {
+			elements = append(elements, attributeSource(value.Index(i)))
+		}
+
+		return strings.Join(elements, ", ")
+
+	case value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr:
+		if value.IsNil() {
+			return ""
+		}
+
+		node, ok := value.Interface().(ast.Node)
+		if !ok {
+			return fmt.Sprint(value.Interface())
+		}
+
+		return nodeSource(node)
+
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// Returns the source code of `node`, including nodes that
+// can't be printed on their own like fields and field lists.
+func nodeSource(node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.FieldList:
+		return attributeSource(reflect.ValueOf(node.List))
+
+	case *ast.Field:
+		names := attributeSource(reflect.ValueOf(node.Names))
+		if names == "" {
+			return nodeSource(node.Type)
+		}
+
+		return names + " " + nodeSource(node.Type)
+
+	case *ast.CommentGroup:
+		return node.Text()
+
+	default:
+		return SourceCode(node)
+	}
+}
+
+// Parses a query like FuncDecl[name=/^Handle/] > CallExpr[fun='log.Printf'].
+func parseQuery(query string) ([]selector, error) {
+	parser := queryParser{input: query}
+
+	selectors := make([]selector, 0)
+
+	for {
+		parser.skipSpaces()
+
+		if parser.done() {
+			break
+		}
+
+		child := false
+
+		if parser.peek() == '>' {
+			if len(selectors) == 0 {
+				return nil, errors.New("> must be between two selectors")
+			}
+
+			child = true
+			parser.next()
+			parser.skipSpaces()
+		}
+
+		selector, err := parser.selector()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		selector.child = child
+
+		selectors = append(selectors, selector)
+	}
+
+	if len(selectors) == 0 {
+		return nil, errors.New("query is empty")
+	}
+
+	return selectors, nil
+}
+
+type queryParser struct {
+	input  string
+	offset int
+}
+
+func (parser *queryParser) done() bool {
+	return parser.offset >= len(parser.input)
+}
+
+func (parser *queryParser) peek() byte {
+	if parser.done() {
+		return 0
+	}
+
+	return parser.input[parser.offset]
+}
+
+func (parser *queryParser) next() byte {
+	b := parser.peek()
+	parser.offset++
+	return b
+}
+
+func (parser *queryParser) skipSpaces() {
+	for !parser.done() && strings.ContainsRune(" \t\n", rune(parser.peek())) {
+		parser.offset++
+	}
+}
+
+// Parses a node type, or *, followed by filters.
+func (parser *queryParser) selector() (selector, error) {
+	out := selector{}
+
+	if parser.peek() == '*' {
+		parser.next()
+	} else {
+		name := parser.word()
+		if name == "" {
+			return out, errors.Errorf("expected a node type at offset %d", parser.offset)
+		}
+
+		typ, ok := queryNodeTypes[name]
+		if !ok {
+			return out, errors.Errorf("unknown node type %s", name)
+		}
+
+		out.typ = typ
+	}
+
+	for parser.peek() == '[' {
+		parser.next()
+
+		filter, err := parser.filter()
+		if err != nil {
+			return out, errors.WithStack(err)
+		}
+
+		if out.typ != nil {
+			if _, ok := fieldByName(out.typ, filter.field); !ok {
+				return out, errors.Errorf("%s does not have a field called %s", out.typ.Name(), filter.field)
+			}
+		}
+
+		out.filters = append(out.filters, filter)
+	}
+
+	if !parser.done() && !strings.ContainsRune(" \t\n>", rune(parser.peek())) {
+		return out, errors.Errorf("unexpected %q at offset %d", parser.peek(), parser.offset)
+	}
+
+	return out, nil
+}
+
+// Parses a filter after its [, like name='main'].
+func (parser *queryParser) filter() (filter, error) {
+	out := filter{field: parser.word()}
+
+	if out.field == "" {
+		return out, errors.Errorf("expected a field name at offset %d", parser.offset)
+	}
+
+	switch {
+	case parser.peek() == ']':
+		parser.next()
+		return out, nil
+
+	case parser.peek() == '=':
+		out.op = "="
+
+	case strings.HasPrefix(parser.input[parser.offset:], "!="):
+		out.op = "!="
+		parser.next()
+
+	default:
+		return out, errors.Errorf("expected =, != or ] at offset %d", parser.offset)
+	}
+
+	parser.next()
+
+	value, isRegexp, err := parser.value()
+	if err != nil {
+		return out, errors.WithStack(err)
+	}
+
+	if isRegexp {
+		out.regexp, err = regexp.Compile(value)
+		if err != nil {
+			return out, errors.Wrapf(err, "invalid regular expression /%s/", value)
+		}
+	} else {
+		out.value = value
+	}
+
+	if parser.next() != ']' {
+		return out, errors.Errorf("expected ] at offset %d", parser.offset-1)
+	}
+
+	return out, nil
+}
+
+// Parses a quoted value, a regular expression between slashes or a value without quotes.
+func (parser *queryParser) value() (string, bool, error) {
+	quote := parser.peek()
+
+	if quote != '\'' && quote != '"' && quote != '/' {
+		start := parser.offset
+
+		for !parser.done() && parser.peek() != ']' {
+			parser.offset++
+		}
+
+		return strings.TrimSpace(parser.input[start:parser.offset]), false, nil
+	}
+
+	parser.next()
+
+	var value strings.Builder
+
+	for {
+		if parser.done() {
+			return "", false, errors.Errorf("missing closing %c", quote)
+		}
+
+		b := parser.next()
+
+		if b == quote {
+			break
+		}
+
+		// Escaped quotes are part of the value.
+		if b == '\\' && parser.peek() == quote {
+			b = parser.next()
+
+			if quote == '/' {
+				value.WriteByte('\\')
+			}
+		}
+
+		value.WriteByte(b)
+	}
+
+	return value.String(), quote == '/', nil
+}
+
+// Parses a node type or field name.
+func (parser *queryParser) word() string {
+	start := parser.offset
+
+	for !parser.done() {
+		b := parser.peek()
+
+		if !(b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9') {
+			break
+		}
+
+		parser.offset++
+	}
+
+	return parser.input[start:parser.offset]
+}
@@ -0,0 +1,126 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SourceFile_Query(t *testing.T) {
+	t.Parallel()
+
+	sourceCode := []byte(`package main
+
+import "log"
+
+var debug = true
+
+func HandleUsers() {
+	log.Printf("users")
+	log.Println("users")
+
+	for i := 0; i < 3; i++ {
+		defer log.Printf("%d", i)
+	}
+}
+
+func HandleOrders() {
+	go func() {
+		log.Printf("orders")
+	}()
+}
+
+func main() {
+	log.Printf("main")
+}
+`)
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: sourceCode, FilePath: "main.go"})
+	assert.NoError(t, err)
+
+	sources := func(matches []codemod.Match) []string {
+		out := make([]string, 0, len(matches))
+
+		for _, match := range matches {
+			out = append(out, codemod.SourceCode(match.Node))
+		}
+
+		return out
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{
+			query:    "FuncDecl[name=/^Handle/] CallExpr[fun='log.Printf']",
+			expected: []string{`log.Printf("users")`, `log.Printf("%d", i)`, `log.Printf("orders")`},
+		},
+		{
+			query:    "FuncDecl[name='main'] > BlockStmt > ExprStmt",
+			expected: []string{`log.Printf("main")`},
+		},
+		{
+			query:    "ForStmt DeferStmt",
+			expected: []string{`defer log.Printf("%d", i)`},
+		},
+		{
+			query:    "GoStmt > CallExpr[fun!=\"log.Printf\"]",
+			expected: []string{"func() {\n\tlog.Printf(\"orders\")\n}()"},
+		},
+		{
+			query:    "GenDecl[tok=var]",
+			expected: []string{"var debug = true"},
+		},
+		{
+			query:    "* > SelectorExpr[sel=Println]",
+			expected: []string{"log.Println"},
+		},
+		{
+			query:    "IfStmt",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		matches, err := file.Query(tt.query)
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.expected, sources(matches), tt.query)
+	}
+
+	t.Run("returns the scope and position of each match", func(t *testing.T) {
+		matches, err := file.Query("CallExpr[fun='log.Println']")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(matches))
+
+		assert.Equal(t, "main.go", matches[0].Position.Filename)
+		assert.Equal(t, 9, matches[0].Position.Line)
+		assert.Equal(t, 2, matches[0].Position.Column)
+
+		// Scopes are the same ones the other finders use.
+		assert.Contains(t, file.FunctionCalls(), matches[0].Scope)
+
+		_, isCall := matches[0].Node.(*ast.CallExpr)
+		assert.True(t, isCall)
+	})
+
+	t.Run("returns error when the query is invalid", func(t *testing.T) {
+		queries := []string{
+			"",
+			"> CallExpr",
+			"Call",
+			"CallExpr[name='x']",
+			"CallExpr[fun='x'",
+			"CallExpr[fun=/(/]",
+			"CallExpr[fun~x]",
+			"CallExpr.fun",
+		}
+
+		for _, query := range queries {
+			_, err := file.Query(query)
+			assert.Error(t, err, query)
+		}
+	})
+}
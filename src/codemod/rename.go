@@ -0,0 +1,319 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/pkg/errors"
+)
+
+// Renames the declaration of `obj` and every reference to it
+// in the package the source file belongs to.
+//
+// Type information is used to find the references, so shadowed variables
+// and unrelated fields or methods with the same name are not renamed.
+// Fails without changing anything if the source file has not been type checked,
+// if `newName` is already declared where `obj` is declared or if a reference
+// to `obj` or to another object would end up referring to something else.
+//
+// References in other packages are not renamed, use Project.Rename
+// to rename exported declarations. Interfaces implemented by a type
+// whose method is renamed are not changed.
+func (code *SourceFile) Rename(obj types.Object, newName string) error {
+	if code.pkg == nil {
+		return errors.New("can't rename without type information: source file has not been type checked")
+	}
+
+	if err := rename([]*packageInfo{code.pkg}, obj, newName); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Renames the declaration of `obj` and every reference to it in every package
+// of the project, which must have been created with type checking enabled.
+//
+// See SourceFile.Rename.
+func (project *Project) Rename(obj types.Object, newName string) error {
+	packages := make([]*packageInfo, 0)
+	seen := make(map[*packageInfo]bool)
+
+	for _, sourceFile := range project.SourceFiles() {
+		if sourceFile.pkg == nil {
+			return errors.Errorf("can't rename without type information: %s has not been type checked", sourceFile.FilePath)
+		}
+
+		if !seen[sourceFile.pkg] {
+			seen[sourceFile.pkg] = true
+			packages = append(packages, sourceFile.pkg)
+		}
+	}
+
+	if err := rename(packages, obj, newName); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// An identifier that declares or refers to an object, and the package it is in.
+type objectIdent struct {
+	ident *ast.Ident
+	pkg   *packageInfo
+}
+
+// Renames `obj` in `packages` after checking the new name does not change
+// what any identifier refers to.
+func rename(packages []*packageInfo, obj types.Object, newName string) error {
+	if obj == nil {
+		return errors.New("can't rename a nil object")
+	}
+
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return errors.Errorf("%q is not a valid name", newName)
+	}
+
+	if obj.Name() == newName {
+		return nil
+	}
+
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return errors.Errorf("can't rename import %s, use Imports().SetName", obj.Name())
+	case *types.Var:
+		if obj.Embedded() {
+			return errors.Errorf("can't rename embedded field %s, rename its type instead", obj.Name())
+		}
+	}
+
+	idents := identsOf(packages, obj)
+	if len(idents) == 0 {
+		return errors.Errorf("%s is not declared or used in the packages being renamed", obj.Name())
+	}
+
+	if err := checkRename(packages, obj, newName, idents); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, ident := range idents {
+		ident.ident.Name = newName
+	}
+
+	return nil
+}
+
+// Returns the identifiers in `packages` that declare or refer to `obj`.
+func identsOf(packages []*packageInfo, obj types.Object) []objectIdent {
+	out := make([]objectIdent, 0)
+
+	for _, pkg := range packages {
+		for _, sourceFile := range pkg.files {
+			ast.Inspect(sourceFile.file, func(node ast.Node) bool {
+				ident, ok := node.(*ast.Ident)
+				if !ok {
+					return true
+				}
+
+				// The identifier that declares the variable in a type switch,
+				// like x in switch x := y.(type), does not have an object.
+				declares := ident.Pos() == obj.Pos() && ident.Name == obj.Name()
+
+				if declares || sameObject(pkg.info.ObjectOf(ident), obj) {
+					out = append(out, objectIdent{ident: ident, pkg: pkg})
+				}
+
+				return true
+			})
+		}
+	}
+
+	return out
+}
+
+// Returns true if `a` and `b` are the same object.
+//
+// The variables a type switch declares in each case clause
+// are the same object since they have the same declaration.
+func sameObject(a, b types.Object) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	if a == b {
+		return true
+	}
+
+	return a.Pos().IsValid() && a.Pos() == b.Pos() && a.Name() == b.Name()
+}
+
+// Returns an error if renaming `obj` to `newName` would cause a conflict.
+func checkRename(packages []*packageInfo, obj types.Object, newName string, idents []objectIdent) error {
+	if obj.Exported() && !ast.IsExported(newName) {
+		for _, ident := range idents {
+			if ident.pkg.types != obj.Pkg() {
+				return errors.Errorf("can't rename %s to %s: %s is used by package %s", obj.Name(), newName, obj.Name(), ident.pkg.path)
+			}
+		}
+	}
+
+	scope := obj.Parent()
+
+	// Fields and methods don't belong to a scope,
+	// their names must be unique among the fields and methods of the type.
+	if scope == nil {
+		return checkFieldOrMethodRename(packages, obj, newName)
+	}
+
+	if existing := scope.Lookup(newName); existing != nil {
+		return errors.Errorf("can't rename %s to %s: %s is already declared at %s", obj.Name(), newName, newName, position(packages, existing))
+	}
+
+	// Package level declarations conflict with imports of the files in the package.
+	if scope.Parent() == types.Universe {
+		for _, pkg := range packages {
+			if pkg.types != obj.Pkg() {
+				continue
+			}
+
+			for _, sourceFile := range pkg.files {
+				if existing := pkg.info.Scopes[sourceFile.file].Lookup(newName); existing != nil {
+					return errors.Errorf("can't rename %s to %s: %s is imported at %s", obj.Name(), newName, newName, position(packages, existing))
+				}
+			}
+		}
+	}
+
+	// References to `obj` must not end up referring to a declaration
+	// of `newName` in a scope inside the scope `obj` is declared in.
+	for _, ident := range idents {
+		if ident.pkg.types != obj.Pkg() || ident.ident.Pos() == obj.Pos() {
+			continue
+		}
+
+		innermost := ident.pkg.types.Scope().Innermost(ident.ident.Pos())
+		if innermost == nil {
+			continue
+		}
+
+		found, existing := innermost.LookupParent(newName, ident.ident.Pos())
+		if existing != nil && isInside(found, scope) {
+			return errors.Errorf("can't rename %s to %s: the reference at %s would refer to %s declared at %s",
+				obj.Name(), newName, position(packages, ident.ident), newName, position(packages, existing))
+		}
+	}
+
+	// References to other objects called `newName` must not end up referring to `obj`.
+	var shadowed *ast.Ident
+
+	for _, pkg := range packages {
+		if pkg.types != obj.Pkg() {
+			continue
+		}
+
+		for ident, used := range pkg.info.Uses {
+			if used.Name() != newName || !isInside(scope, used.Parent()) || scope == used.Parent() {
+				continue
+			}
+
+			if isReferenceInScope(ident, obj) && (shadowed == nil || ident.Pos() < shadowed.Pos()) {
+				shadowed = ident
+			}
+		}
+	}
+
+	if shadowed != nil {
+		return errors.Errorf("can't rename %s to %s: it would shadow the reference to %s at %s",
+			obj.Name(), newName, newName, position(packages, shadowed))
+	}
+
+	return nil
+}
+
+// Returns true if `ident` is in the part of the scope `obj` is declared in where `obj` is visible.
+func isReferenceInScope(ident *ast.Ident, obj types.Object) bool {
+	scope := obj.Parent()
+
+	// Package level declarations are visible in every file of the package.
+	if scope.Parent() == types.Universe {
+		return true
+	}
+
+	// Local declarations are only visible after they are declared.
+	return scope.Contains(ident.Pos()) && ident.Pos() > obj.Pos()
+}
+
+// Returns true if `scope` is `ancestor` or is nested inside of it.
+func isInside(scope, ancestor *types.Scope) bool {
+	for ; scope != nil; scope = scope.Parent() {
+		if scope == ancestor {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns an error if a field or method of the type `obj` belongs to is called `newName`.
+func checkFieldOrMethodRename(packages []*packageInfo, obj types.Object, newName string) error {
+	for _, typ := range typesWith(obj) {
+		existing, _, _ := types.LookupFieldOrMethod(typ, true, obj.Pkg(), newName)
+		if existing != nil {
+			return errors.Errorf("can't rename %s to %s: %s already has a field or method called %s declared at %s",
+				obj.Name(), newName, typ, newName, position(packages, existing))
+		}
+	}
+
+	return nil
+}
+
+// Returns the types that have `obj` as a field or method.
+func typesWith(obj types.Object) []types.Type {
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			return []types.Type{recv.Type()}
+		}
+
+		return nil
+	}
+
+	out := make([]types.Type, 0)
+
+	if obj.Pkg() == nil {
+		return out
+	}
+
+	scope := obj.Pkg().Scope()
+
+	for _, name := range scope.Names() {
+		typeName, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+
+		structType, ok := typeName.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+
+		for i := 0; i < structType.NumFields(); i++ {
+			if structType.Field(i) == obj {
+				out = append(out, typeName.Type())
+			}
+		}
+	}
+
+	return out
+}
+
+// Returns the position of `node` in the source code, like main.go:10:2.
+//
+// Packages that are type checked together share the same file set.
+func position(packages []*packageInfo, node interface{ Pos() token.Pos }) string {
+	if !node.Pos().IsValid() || len(packages[0].files) == 0 {
+		return "unknown position"
+	}
+
+	return packages[0].files[0].fileSet.Position(node.Pos()).String()
+}
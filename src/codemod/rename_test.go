@@ -0,0 +1,230 @@
+package codemod_test
+
+import (
+	"go/types"
+	"path/filepath"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the object called `name` declared at the top level of the package `file` belongs to.
+func lookup(t *testing.T, file *codemod.SourceFile, name string) types.Object {
+	t.Helper()
+
+	obj := file.TypesPackage().Scope().Lookup(name)
+	assert.NotNil(t, obj)
+
+	return obj
+}
+
+func Test_SourceFile_Rename(t *testing.T) {
+	t.Parallel()
+
+	t.Run("renames the declaration and its references, but not shadowed variables", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"a.go": `package a
+
+var count = 0
+
+func increment() {
+	count++
+}
+
+func shadow(count int) int {
+	return count
+}
+`,
+			"b.go": `package a
+
+func get() int {
+	return count
+}
+`,
+		}))
+		assert.NoError(t, err)
+
+		file := findFile(t, files, "a.go")
+
+		assert.NoError(t, file.Rename(lookup(t, file, "count"), "total"))
+
+		expected := `package a
+
+var total = 0
+
+func increment() {
+	total++
+}
+
+func shadow(count int) int {
+	return count
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+		assert.Contains(t, string(findFile(t, files, "b.go").SourceCode()), "return total")
+	})
+
+	t.Run("renames fields but not fields of other types with the same name", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"a.go": `package a
+
+type User struct{ Name string }
+
+type Pet struct{ Name string }
+
+func names(u User, p Pet) []string {
+	u = User{Name: "user"}
+	return []string{u.Name, p.Name}
+}
+`,
+		}))
+		assert.NoError(t, err)
+
+		file := findFile(t, files, "a.go")
+
+		user := lookup(t, file, "User").Type().Underlying().(*types.Struct)
+
+		assert.NoError(t, file.Rename(user.Field(0), "FullName"))
+
+		expected := `package a
+
+type User struct{ FullName string }
+
+type Pet struct{ Name string }
+
+func names(u User, p Pet) []string {
+	u = User{FullName: "user"}
+	return []string{u.FullName, p.Name}
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+
+		assert.Error(t, file.Rename(user.Field(0), ""))
+	})
+
+	t.Run("refuses names that are already declared", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"a.go": `package a
+
+import "fmt"
+
+type User struct{ Name string }
+
+func (User) String() string { return "" }
+
+var a, b = 1, 2
+
+func print() { fmt.Println(a, b) }
+`,
+		}))
+		assert.NoError(t, err)
+
+		file := findFile(t, files, "a.go")
+
+		user := lookup(t, file, "User").Type().Underlying().(*types.Struct)
+
+		assert.Error(t, file.Rename(lookup(t, file, "a"), "b"))
+		assert.Error(t, file.Rename(lookup(t, file, "a"), "fmt"))
+		assert.Error(t, file.Rename(user.Field(0), "String"))
+		assert.Error(t, file.Rename(lookup(t, file, "a"), "not valid"))
+
+		assert.Equal(t, "a", lookup(t, file, "a").Name())
+		assert.Contains(t, string(file.SourceCode()), "var a, b = 1, 2")
+	})
+
+	t.Run("refuses names that would be shadowed or would shadow other declarations", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"a.go": `package a
+
+var limit = 10
+
+func f(max int) int {
+	return limit + max
+}
+
+func g(items []int) int {
+	size := 1
+	return size + len(items)
+}
+`,
+		}))
+		assert.NoError(t, err)
+
+		file := findFile(t, files, "a.go")
+
+		// return max + max
+		assert.Error(t, file.Rename(lookup(t, file, "limit"), "max"))
+		// len would refer to size.
+		assert.Error(t, file.Rename(lookup(t, file, "limit"), "len"))
+
+		expected := `package a
+
+var limit = 10
+
+func f(max int) int {
+	return limit + max
+}
+
+func g(items []int) int {
+	size := 1
+	return size + len(items)
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("returns error when the source file has not been type checked", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte("package a\n\nvar a = 1\n")})
+		assert.NoError(t, err)
+
+		assert.Error(t, file.Rename(types.NewVar(0, nil, "a", types.Typ[types.Int]), "b"))
+	})
+}
+
+func Test_Project_Rename(t *testing.T) {
+	t.Parallel()
+
+	inputs := writeModule(t, map[string]string{
+		"users/users.go": "package users\n\nfunc Find() {}\n",
+		"main.go":        "package main\n\nimport \"example.com/project/users\"\n\nfunc main() {\n\tusers.Find()\n}\n",
+	})
+
+	var root string
+
+	for _, input := range inputs {
+		if filepath.Base(input.FilePath) == "main.go" {
+			root = filepath.Dir(input.FilePath)
+		}
+	}
+
+	files := make([]codemod.ProjectFile, 0, len(inputs))
+
+	for _, input := range inputs {
+		path, err := filepath.Rel(root, input.FilePath)
+		assert.NoError(t, err)
+
+		files = append(files, codemod.ProjectFile{Path: path, Contents: input.SourceCode, Mode: 0644})
+	}
+
+	project, err := codemod.NewProject(codemod.NewProjectInput{Root: root, Files: files, TypeCheck: true})
+	assert.NoError(t, err)
+
+	users := project.Packages()[1].SourceFiles[0]
+
+	find := lookup(t, users, "Find")
+
+	assert.Error(t, project.Rename(find, "find"))
+	assert.NoError(t, project.Rename(find, "FindAll"))
+
+	contents, err := project.ReadFile("main.go")
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "users.FindAll()")
+
+	contents, err = project.ReadFile("users/users.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package users\n\nfunc FindAll() {}\n", string(contents))
+}
@@ -0,0 +1,581 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	metavariablePrefix         = "__codemod_metavariable_"
+	variadicMetavariablePrefix = "__codemod_variadic_metavariable_"
+)
+
+// A metavariable, like $name or $name..., in the source code of a pattern or template.
+type metavariableToken struct {
+	// Offsets of the first byte of the metavariable and of the byte after it.
+	start, end int
+	name       string
+	variadic   bool
+}
+
+// Returns the metavariables in `source` in the order they appear in.
+//
+// The source code is scanned as Go code, so $ inside of
+// string literals, rune literals and comments is left alone.
+func scanMetavariables(source string) []metavariableToken {
+	type scannedToken struct {
+		offset int
+		tok    token.Token
+		lit    string
+	}
+
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", fileSet.Base(), len(source))
+
+	var s scanner.Scanner
+	// $ is not valid Go, errors are expected.
+	s.Init(file, []byte(source), nil, 0)
+
+	tokens := make([]scannedToken, 0)
+
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		if lit == "" {
+			lit = tok.String()
+		}
+
+		tokens = append(tokens, scannedToken{offset: file.Offset(pos), tok: tok, lit: lit})
+	}
+
+	out := make([]metavariableToken, 0)
+
+	for i := 0; i+1 < len(tokens); i++ {
+		dollar, name := tokens[i], tokens[i+1]
+
+		if dollar.tok != token.ILLEGAL || dollar.lit != "$" || name.offset != dollar.offset+1 {
+			continue
+		}
+
+		if name.tok != token.IDENT && !(name.tok == token.INT && isDigits(name.lit)) {
+			continue
+		}
+
+		metavariable := metavariableToken{start: dollar.offset, end: name.offset + len(name.lit), name: name.lit}
+
+		if i+2 < len(tokens) && tokens[i+2].tok == token.ELLIPSIS && tokens[i+2].offset == metavariable.end {
+			metavariable.variadic = true
+			metavariable.end += len("...")
+		}
+
+		out = append(out, metavariable)
+	}
+
+	return out
+}
+
+// Returns true if `s` only has decimal digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// Replaces every node in `file` that matches `pattern` with `replacement`
+// and returns how many nodes were replaced.
+//
+// Patterns are Go expressions or statements that may contain metavariables:
+//
+// $name matches any expression, statement or identifier.
+//
+// $name... matches zero or more elements of a list,
+// function call arguments for example.
+//
+// $_ matches anything and is not bound to what it matched.
+//
+// When a metavariable appears more than once in a pattern, every occurrence
+// must match the same code.
+//
+// Metavariables in `replacement` are replaced by the code they matched:
+//
+//	codemod.Rewrite(
+//	  file,
+//	  "errors.Wrapf($err, $format, $args...)",
+//	  `fmt.Errorf($format + ": %w", $args..., $err)`,
+//	)
+//
+// goes from
+//
+//	errors.Wrapf(err, "fetching user %d", userID)
+//
+// to
+//
+//	fmt.Errorf("fetching user %d: %w", userID, err)
+//
+// String literals that end up being concatenated in the replacement are merged.
+//
+// Panics if `pattern` or `replacement` are not valid Go code.
+func Rewrite(file *SourceFile, pattern, replacement string) int {
+	patternNode, err := parsePattern(pattern)
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid pattern: %s", pattern))
+	}
+
+	replacementNode, err := parsePattern(replacement)
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid replacement: %s", replacement))
+	}
+
+	// Maps new nodes to the nodes they replaced.
+	newNodes := make(map[ast.Node]ast.Node)
+
+	rewriteTree(file.file, func(node ast.Node) ast.Node {
+		matcher := newPatternMatcher()
+
+		if !matcher.match(patternNode, node) {
+			return node
+		}
+
+		newNode, ok := matcher.substitute(replacementNode, node.Pos())
+
+		if !ok || !sameKind(newNode, node) {
+			return node
+		}
+
+		newNodes[newNode] = node
+
+		return newNode
+	})
+
+	// A new node may not be in the tree if it can't be stored where
+	// the node it would replace is, so we count the ones that are.
+	replaced := 0
+
+	ast.Inspect(file.file, func(node ast.Node) bool {
+		if oldNode, ok := newNodes[node]; ok {
+			file.MoveComments(oldNode, node)
+			replaced++
+		}
+
+		return true
+	})
+
+	return replaced
+}
+
+// Returns true if both nodes are expressions or both nodes are statements.
+func sameKind(a, b ast.Node) bool {
+	_, aIsExpr := a.(ast.Expr)
+	_, bIsExpr := b.(ast.Expr)
+	_, aIsStmt := a.(ast.Stmt)
+	_, bIsStmt := b.(ast.Stmt)
+
+	return (aIsExpr && bIsExpr) || (aIsStmt && bIsStmt)
+}
+
+// Parses a pattern as an expression or as a single statement.
+func parsePattern(pattern string) (ast.Node, error) {
+	var builder strings.Builder
+
+	last := 0
+
+	for _, metavariable := range scanMetavariables(pattern) {
+		builder.WriteString(pattern[last:metavariable.start])
+
+		if metavariable.variadic {
+			builder.WriteString(variadicMetavariablePrefix + metavariable.name)
+		} else {
+			builder.WriteString(metavariablePrefix + metavariable.name)
+		}
+
+		last = metavariable.end
+	}
+
+	builder.WriteString(pattern[last:])
+
+	source := builder.String()
+
+	if expr, err := parser.ParseExpr(source); err == nil {
+		return expr, nil
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "", fmt.Sprintf("package p; func f() { %s\n}", source), 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	statements := file.Decls[0].(*ast.FuncDecl).Body.List
+
+	if len(statements) != 1 {
+		return nil, errors.Errorf("pattern must be an expression or a single statement, got %d statements", len(statements))
+	}
+
+	return statements[0], nil
+}
+
+// Returns the name of the metavariable `node` represents.
+func metavariable(node ast.Node) (name string, variadic bool, ok bool) {
+	if stmt, isExprStmt := node.(*ast.ExprStmt); isExprStmt {
+		node = stmt.X
+	}
+
+	ident, isIdent := node.(*ast.Ident)
+	if !isIdent {
+		return "", false, false
+	}
+
+	if strings.HasPrefix(ident.Name, variadicMetavariablePrefix) {
+		return strings.TrimPrefix(ident.Name, variadicMetavariablePrefix), true, true
+	}
+
+	if strings.HasPrefix(ident.Name, metavariablePrefix) {
+		return strings.TrimPrefix(ident.Name, metavariablePrefix), false, true
+	}
+
+	return "", false, false
+}
+
+// Matches patterns against nodes and keeps track of
+// the nodes each metavariable matched.
+type patternMatcher struct {
+	bindings map[string][]ast.Node
+	// Variadic metavariables that matched the arguments of a call
+	// that spreads its last argument: f(a, b...).
+	spread map[string]bool
+	// Metavariables that have been used at least once in a replacement.
+	used map[string]bool
+}
+
+func newPatternMatcher() *patternMatcher {
+	return &patternMatcher{
+		bindings: make(map[string][]ast.Node),
+		spread:   make(map[string]bool),
+		used:     make(map[string]bool),
+	}
+}
+
+func (matcher *patternMatcher) bind(name string, nodes []ast.Node) bool {
+	if name == "_" {
+		return true
+	}
+
+	bound, ok := matcher.bindings[name]
+	if !ok {
+		matcher.bindings[name] = nodes
+		return true
+	}
+
+	if len(bound) != len(nodes) {
+		return false
+	}
+
+	for i := range bound {
+		if !newPatternMatcher().match(bound[i], nodes[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (matcher *patternMatcher) snapshot() map[string][]ast.Node {
+	bindings := make(map[string][]ast.Node, len(matcher.bindings))
+
+	for name, nodes := range matcher.bindings {
+		bindings[name] = nodes
+	}
+
+	return bindings
+}
+
+func (matcher *patternMatcher) match(pattern, node ast.Node) bool {
+	if isNilNode(pattern) || isNilNode(node) {
+		return isNilNode(pattern) && isNilNode(node)
+	}
+
+	if name, variadic, ok := metavariable(pattern); ok && !variadic {
+		_, patternIsStmt := pattern.(ast.Stmt)
+		_, nodeIsStmt := node.(ast.Stmt)
+		_, nodeIsExpr := node.(ast.Expr)
+
+		if (patternIsStmt && nodeIsStmt) || (!patternIsStmt && nodeIsExpr) {
+			return matcher.bind(name, []ast.Node{node})
+		}
+	}
+
+	if reflect.TypeOf(pattern) != reflect.TypeOf(node) {
+		return false
+	}
+
+	if call, ok := pattern.(*ast.CallExpr); ok {
+		if name, ok := spreadMetavariable(call); ok {
+			matcher.spread[name] = node.(*ast.CallExpr).Ellipsis.IsValid()
+		}
+	}
+
+	patternValue := reflect.ValueOf(pattern).Elem()
+	nodeValue := reflect.ValueOf(node).Elem()
+	structType := patternValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		if isSkippedField(structType, field) || !patternValue.Field(i).CanInterface() {
+			continue
+		}
+
+		patternField := patternValue.Field(i)
+		nodeField := nodeValue.Field(i)
+
+		switch {
+		case field.Type == posType:
+			if _, isCall := pattern.(*ast.CallExpr); isCall && field.Name == "Ellipsis" {
+				if _, ok := spreadMetavariable(pattern.(*ast.CallExpr)); ok {
+					continue
+				}
+			}
+
+			if presencePositions[structType][field.Name] &&
+				token.Pos(patternField.Int()).IsValid() != token.Pos(nodeField.Int()).IsValid() {
+				return false
+			}
+
+		case isNodeType(field.Type):
+			if !matcher.match(asNode(patternField), asNode(nodeField)) {
+				return false
+			}
+
+		case field.Type.Kind() == reflect.Slice && isNodeType(field.Type.Elem()):
+			if !matcher.matchList(asNodes(patternField), asNodes(nodeField)) {
+				return false
+			}
+
+		default:
+			if !reflect.DeepEqual(patternField.Interface(), nodeField.Interface()) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (matcher *patternMatcher) matchList(patterns, nodes []ast.Node) bool {
+	if len(patterns) == 0 {
+		return len(nodes) == 0
+	}
+
+	if name, variadic, ok := metavariable(patterns[0]); ok && variadic {
+		for i := 0; i <= len(nodes); i++ {
+			bindings := matcher.snapshot()
+
+			if matcher.bind(name, nodes[:i]) && matcher.matchList(patterns[1:], nodes[i:]) {
+				return true
+			}
+
+			matcher.bindings = bindings
+		}
+
+		return false
+	}
+
+	if len(nodes) == 0 {
+		return false
+	}
+
+	bindings := matcher.snapshot()
+
+	if matcher.match(patterns[0], nodes[0]) && matcher.matchList(patterns[1:], nodes[1:]) {
+		return true
+	}
+
+	matcher.bindings = bindings
+
+	return false
+}
+
+// Returns the name of the variadic metavariable that is the last
+// argument of `call` if there's one.
+//
+// f($args...) matches both f(a, b) and f(a, b...).
+func spreadMetavariable(call *ast.CallExpr) (string, bool) {
+	if len(call.Args) == 0 || call.Ellipsis.IsValid() {
+		return "", false
+	}
+
+	name, variadic, ok := metavariable(call.Args[len(call.Args)-1])
+	if !ok || !variadic {
+		return "", false
+	}
+
+	return name, true
+}
+
+func asNode(value reflect.Value) ast.Node {
+	if value.IsNil() {
+		return nil
+	}
+
+	return value.Interface().(ast.Node)
+}
+
+func asNodes(value reflect.Value) []ast.Node {
+	out := make([]ast.Node, 0, value.Len())
+
+	for i := 0; i < value.Len(); i++ {
+		out = append(out, asNode(value.Index(i)))
+	}
+
+	return out
+}
+
+// Builds a new node from `template` replacing metavariables
+// by the nodes they matched.
+//
+// Returns false if the nodes can't be used in the template.
+func (matcher *patternMatcher) substitute(template ast.Node, pos token.Pos) (ast.Node, bool) {
+	ok := true
+
+	var c *cloner
+
+	c = &cloner{
+		pos: pos,
+		replace: func(node ast.Node) ([]ast.Node, bool) {
+			if call, isCall := node.(*ast.CallExpr); isCall {
+				return matcher.substituteCall(c, call, &ok)
+			}
+
+			name, _, isMetavariable := metavariable(node)
+			if !isMetavariable {
+				return nil, false
+			}
+
+			bound, ok := matcher.bindings[name]
+			if !ok {
+				panic(fmt.Sprintf("metavariable $%s is used in the replacement but is not in the pattern", name))
+			}
+
+			nodes := make([]ast.Node, 0, len(bound))
+
+			for _, boundNode := range bound {
+				// The same node can't be in the tree twice, otherwise
+				// modifying one of the occurrences would modify both.
+				if matcher.used[name] {
+					boundNode = cloneNode(boundNode)
+				}
+
+				nodes = append(nodes, adaptToTemplate(node, boundNode))
+			}
+
+			matcher.used[name] = true
+
+			return nodes, true
+		},
+	}
+
+	// Only the code that comes from the template is folded,
+	// the code that was matched is kept as it is.
+	matched := make(map[ast.Node]bool)
+
+	for _, nodes := range matcher.bindings {
+		for _, node := range nodes {
+			ast.Inspect(node, func(node ast.Node) bool {
+				matched[node] = true
+				return true
+			})
+		}
+	}
+
+	newNode := foldStringConcatenation(c.clone(template), matched)
+
+	return newNode, ok
+}
+
+// Copies a call from the template, spreading the last argument
+// if the arguments that matched were spread.
+//
+// Sets `ok` to false if arguments that were spread are not the last
+// arguments of the call in the template.
+func (matcher *patternMatcher) substituteCall(c *cloner, call *ast.CallExpr, ok *bool) ([]ast.Node, bool) {
+	newCall := c.copy(call).(*ast.CallExpr)
+
+	for i, arg := range call.Args {
+		name, variadic, isMetavariable := metavariable(arg)
+		if !isMetavariable || !variadic || !matcher.spread[name] {
+			continue
+		}
+
+		if i != len(call.Args)-1 || call.Ellipsis.IsValid() {
+			*ok = false
+			continue
+		}
+
+		newCall.Ellipsis = c.presencePosition()
+	}
+
+	return []ast.Node{newCall}, true
+}
+
+// Metavariables in statement position are parsed as expression statements,
+// if the metavariable matched an expression, the expression must be
+// wrapped in a statement again.
+func adaptToTemplate(template, node ast.Node) ast.Node {
+	_, templateIsStmt := template.(ast.Stmt)
+	expr, nodeIsExpr := node.(ast.Expr)
+
+	if templateIsStmt && nodeIsExpr {
+		return &ast.ExprStmt{X: expr}
+	}
+
+	return node
+}
+
+// Merges string literals that are concatenated:
+//
+// "a" + "b" becomes "ab".
+func foldStringConcatenation(node ast.Node, skip map[ast.Node]bool) ast.Node {
+	return rewriteTree(node, func(node ast.Node) ast.Node {
+		binary, ok := node.(*ast.BinaryExpr)
+		if !ok || binary.Op != token.ADD || skip[binary] {
+			return node
+		}
+
+		left, leftOk := unparen(binary.X).(*ast.BasicLit)
+		right, rightOk := unparen(binary.Y).(*ast.BasicLit)
+
+		if !leftOk || !rightOk || left.Kind != token.STRING || right.Kind != token.STRING {
+			return node
+		}
+
+		leftValue, err := strconv.Unquote(left.Value)
+		if err != nil {
+			return node
+		}
+
+		rightValue, err := strconv.Unquote(right.Value)
+		if err != nil {
+			return node
+		}
+
+		return &ast.BasicLit{
+			ValuePos: left.ValuePos,
+			Kind:     token.STRING,
+			Value:    strconv.Quote(leftValue + rightValue),
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Rewrite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		description string
+		code        string
+		pattern     string
+		replacement string
+		expected    string
+		replaced    int
+	}{
+		{
+			description: "rewrites errors.Wrapf to fmt.Errorf",
+			code: `
+			package main
+
+			func foo() error {
+				return errors.Wrapf(errSomething, "fetching user %d", userID)
+			}
+			`,
+			pattern:     "errors.Wrapf($err, $format, $args...)",
+			replacement: `fmt.Errorf($format + ": %w", $args..., $err)`,
+			expected: `package main
+
+func foo() error {
+	return fmt.Errorf("fetching user %d: %w", userID, errSomething)
+}
+`,
+			replaced: 1,
+		},
+		{
+			description: "variadic metavariables match zero elements",
+			code: `
+			package main
+
+			func foo() error {
+				return errors.Wrapf(err, "context")
+			}
+			`,
+			pattern:     "errors.Wrapf($err, $format, $args...)",
+			replacement: `fmt.Errorf($format + ": %w", $args..., $err)`,
+			expected: `package main
+
+func foo() error {
+	return fmt.Errorf("context: %w", err)
+}
+`,
+			replaced: 1,
+		},
+		{
+			description: "rewrites nested matches",
+			code: `
+			package main
+
+			func foo() {
+				_ = a(a(1))
+			}
+			`,
+			pattern:     "a($x)",
+			replacement: "b($x)",
+			expected: `package main
+
+func foo() {
+	_ = b(b(1))
+}
+`,
+			replaced: 2,
+		},
+		{
+			description: "metavariables that appear more than once must match the same code",
+			code: `
+			package main
+
+			func foo() {
+				_ = x == x
+				_ = x == y
+			}
+			`,
+			pattern:     "$a == $a",
+			replacement: "true",
+			expected: `package main
+
+func foo() {
+	_ = true
+	_ = x == y
+}
+`,
+			replaced: 1,
+		},
+		{
+			description: "rewrites statements",
+			code: `
+			package main
+
+			func foo() error {
+				err := bar()
+				if err != nil {
+					return err
+				}
+				return nil
+			}
+			`,
+			pattern:     "if $err != nil { return $err }",
+			replacement: "if $err != nil { return errors.WithStack($err) }",
+			expected: `package main
+
+func foo() error {
+	err := bar()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+`,
+			replaced: 1,
+		},
+		{
+			description: "keeps variadic arguments",
+			code: `
+			package main
+
+			func foo() {
+				log.Printf(format, args...)
+			}
+			`,
+			pattern:     "log.Printf($args...)",
+			replacement: "logger.Infof($args...)",
+			expected: `package main
+
+func foo() {
+	logger.Infof(format, args...)
+}
+`,
+			replaced: 1,
+		},
+		{
+			description: "does not rewrite calls when spread arguments would not be the last arguments",
+			code: `
+			package main
+
+			func foo() error {
+				return errors.Wrapf(err, format, args...)
+			}
+			`,
+			pattern:     "errors.Wrapf($err, $format, $args...)",
+			replacement: `fmt.Errorf($format + ": %w", $args..., $err)`,
+			expected: `package main
+
+func foo() error {
+	return errors.Wrapf(err, format, args...)
+}
+`,
+			replaced: 0,
+		},
+		{
+			description: "does nothing when there are no matches",
+			code: `
+			package main
+
+			func foo() {
+				log.Println("hello")
+			}
+			`,
+			pattern:     "log.Printf($args...)",
+			replacement: "logger.Infof($args...)",
+			expected: `package main
+
+func foo() {
+	log.Println("hello")
+}
+`,
+			replaced: 0,
+		},
+		{
+			description: "leaves $ inside of string literals alone",
+			code: `
+			package main
+
+			func foo() {
+				f(a)
+				log.Println("$x")
+			}
+			`,
+			pattern:     `log.Println("$x")`,
+			replacement: `g("cost: $1", '$')`,
+			expected: `package main
+
+func foo() {
+	f(a)
+	g("cost: $1", '$')
+}
+`,
+			replaced: 1,
+		},
+		{
+			description: "replaces metavariables next to string literals with $ inside of them",
+			code: `
+			package main
+
+			func foo() {
+				f(a)
+			}
+			`,
+			pattern:     "f($x)",
+			replacement: "g(\"cost: $1\", $x, `$x`)",
+			expected: `package main
+
+func foo() {
+	g("cost: $1", a, ` + "`$x`" + `)
+}
+`,
+			replaced: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			file, err := codemod.New(codemod.NewInput{SourceCode: []byte(tt.code)})
+			assert.NoError(t, err)
+
+			replaced := codemod.Rewrite(file, tt.pattern, tt.replacement)
+
+			assert.Equal(t, tt.replaced, replaced)
+			check(t, tt.expected, string(file.SourceCode()))
+		})
+	}
+
+	t.Run("panics when pattern is not valid Go code", func(t *testing.T) {
+		file, _ := codemod.New(codemod.NewInput{SourceCode: []byte("package main")})
+
+		assert.Panics(t, func() {
+			codemod.Rewrite(file, "func (", "x")
+		})
+	})
+}
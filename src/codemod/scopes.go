@@ -0,0 +1,229 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// A scope of a source file, like in the Go spec: the package, a function declaration,
+// a function literal or a block, like an if statement and its body.
+//
+// Scopes form a tree, the package scope is the root and the parent of
+// every other scope is the scope it is in, see Parent.
+//
+// Scopes are comparable, finders group the nodes they return by the innermost scope the nodes are in.
+type Scope struct {
+	// One of *ast.File for the package scope, *ast.FuncDecl, *ast.FuncLit,
+	// *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt,
+	// *ast.CaseClause, *ast.CommClause or *ast.BlockStmt for blocks that
+	// are not the body of one of the other nodes, like else blocks.
+	node ast.Node
+	file *SourceFile
+}
+
+// Returns the node that starts the scope. See Scope.
+func (scope Scope) Node() ast.Node {
+	return scope.node
+}
+
+// Returns the name of the function for function declarations,
+// the name of the package for the package scope and an empty string otherwise.
+func (scope Scope) Name() string {
+	switch node := scope.node.(type) {
+	case *ast.FuncDecl:
+		return node.Name.Name
+	case *ast.File:
+		return node.Name.Name
+	default:
+		return ""
+	}
+}
+
+// Returns the receiver type of a method, like *Server, or an empty string
+// if the scope is not a method declaration.
+func (scope Scope) Receiver() string {
+	funcDecl, ok := scope.node.(*ast.FuncDecl)
+	if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return ""
+	}
+
+	return SourceCode(funcDecl.Recv.List[0].Type)
+}
+
+// Returns the file, line and column where the node that starts the scope starts,
+// the package clause for the package scope.
+func (scope Scope) Position() token.Position {
+	if scope.file == nil {
+		return token.Position{}
+	}
+
+	return scope.file.nodePosition(scope.node)
+}
+
+// Returns the scope the scope is in.
+//
+// Returns false for the package scope or if the node
+// that starts the scope has been removed from the file.
+func (scope Scope) Parent() (Scope, bool) {
+	if scope.file == nil {
+		return Scope{}, false
+	}
+
+	cursor := scope.file.cursorOf(scope.node)
+	if cursor == nil {
+		return Scope{}, false
+	}
+
+	parent := scopeOf(cursor)
+
+	return parent, parent.node != nil
+}
+
+// Returns the nodes inside of the scope matched by every matcher in `matchers`. See SourceFile.Find.
+func (scope Scope) Find(matchers ...Matcher) []Match {
+	return scope.file.Find(scope.matchers(matchers)...)
+}
+
+// Returns the function calls inside of the scope. See SourceFile.FunctionCalls.
+func (scope Scope) FunctionCalls(matchers ...Matcher) map[Scope][]FunctionCall {
+	return scope.file.FunctionCalls(scope.matchers(matchers)...)
+}
+
+// Returns the function calls inside of the scope in the order they appear in. See SourceFile.FunctionCallsInOrder.
+func (scope Scope) FunctionCallsInOrder(matchers ...Matcher) []FunctionCall {
+	return scope.file.FunctionCallsInOrder(scope.matchers(matchers)...)
+}
+
+// Returns the first call to `selector`, like s.End, inside of the scope or nil if there is none.
+func (scope Scope) FindCall(selector string) *FunctionCall {
+	matches := scope.Find(IsCall(selector))
+	if len(matches) == 0 {
+		return nil
+	}
+
+	cursor := matches[0].Cursor
+
+	return &FunctionCall{Node: cursor.node.(*ast.CallExpr), Parent: cursor.parent.nodeWithParent(), file: scope.file, cursor: cursor}
+}
+
+// Returns the if statements inside of the scope. See SourceFile.IfStatements.
+func (scope Scope) IfStatements(matchers ...Matcher) map[Scope][]IfStmt {
+	return scope.file.IfStatements(scope.matchers(matchers)...)
+}
+
+// Returns the if statements inside of the scope in the order they appear in. See SourceFile.IfStatementsInOrder.
+func (scope Scope) IfStatementsInOrder(matchers ...Matcher) []IfStmt {
+	return scope.file.IfStatementsInOrder(scope.matchers(matchers)...)
+}
+
+// Returns the switch statements inside of the scope. See SourceFile.SwitchStatements.
+func (scope Scope) SwitchStatements(matchers ...Matcher) map[Scope][]SwitchStmt {
+	return scope.file.SwitchStatements(scope.matchers(matchers)...)
+}
+
+// Returns the switch statements inside of the scope in the order they appear in. See SourceFile.SwitchStatementsInOrder.
+func (scope Scope) SwitchStatementsInOrder(matchers ...Matcher) []SwitchStmt {
+	return scope.file.SwitchStatementsInOrder(scope.matchers(matchers)...)
+}
+
+// Returns the type switch statements inside of the scope. See SourceFile.TypeSwitchStatements.
+func (scope Scope) TypeSwitchStatements(matchers ...Matcher) map[Scope][]TypeSwitchStmt {
+	return scope.file.TypeSwitchStatements(scope.matchers(matchers)...)
+}
+
+// Returns the type switch statements inside of the scope in the order they appear in. See SourceFile.TypeSwitchStatementsInOrder.
+func (scope Scope) TypeSwitchStatementsInOrder(matchers ...Matcher) []TypeSwitchStmt {
+	return scope.file.TypeSwitchStatementsInOrder(scope.matchers(matchers)...)
+}
+
+// Returns the assignments inside of the scope. See SourceFile.Assignments.
+func (scope Scope) Assignments(matchers ...Matcher) map[Scope][]Assignment {
+	return scope.file.Assignments(scope.matchers(matchers)...)
+}
+
+// Returns the assignments inside of the scope in the order they appear in. See SourceFile.AssignmentsInOrder.
+func (scope Scope) AssignmentsInOrder(matchers ...Matcher) []Assignment {
+	return scope.file.AssignmentsInOrder(scope.matchers(matchers)...)
+}
+
+// Returns the assignments and declarations inside of the scope. See SourceFile.Bindings.
+func (scope Scope) Bindings(matchers ...Matcher) []Binding {
+	return scope.file.Bindings(scope.matchers(matchers)...)
+}
+
+// Returns the type declarations inside of the scope. See SourceFile.TypeDeclarations.
+func (scope Scope) TypeDeclarations(matchers ...Matcher) []TypeDeclaration {
+	return scope.file.TypeDeclarations(scope.matchers(matchers)...)
+}
+
+// Returns the functions declared in the scope, only the package scope has function declarations.
+// See SourceFile.Functions.
+func (scope Scope) Functions(matchers ...Matcher) []Function {
+	return scope.file.Functions(scope.matchers(matchers)...)
+}
+
+// Returns the struct literals of type `typeName` inside of the scope. See SourceFile.StructLiterals.
+func (scope Scope) StructLiterals(typeName string, matchers ...Matcher) []Struct {
+	return scope.file.StructLiterals(typeName, scope.matchers(matchers)...)
+}
+
+// Returns the map literals of type `mapType` inside of the scope. See SourceFile.MapLiterals.
+func (scope Scope) MapLiterals(mapType string, matchers ...Matcher) []Map {
+	return scope.file.MapLiterals(mapType, scope.matchers(matchers)...)
+}
+
+// Returns the slice and array literals of type `sliceType` inside of the scope. See SourceFile.SliceLiterals.
+func (scope Scope) SliceLiterals(sliceType string, matchers ...Matcher) []Slice {
+	return scope.file.SliceLiterals(sliceType, scope.matchers(matchers)...)
+}
+
+// Returns `matchers` and a matcher that only matches nodes inside of the scope.
+func (scope Scope) matchers(matchers []Matcher) []Matcher {
+	node := scope.node
+
+	inside := func(cursor *Cursor) bool {
+		return node != nil && cursor.parent.findUpstream(func(ancestor *Cursor) bool {
+			return ancestor.node == node
+		}) != nil
+	}
+
+	return append([]Matcher{inside}, matchers...)
+}
+
+// Returns the innermost scope the node `cursor` points to is in.
+//
+// A statement that starts a scope, like an if statement, is in the scope around it,
+// the nodes inside of it, like its condition, are in the scope it starts.
+func scopeOf(cursor *Cursor) Scope {
+	if ancestor := cursor.parent.findUpstream(startsScope); ancestor != nil {
+		return Scope{node: ancestor.node, file: cursor.file}
+	}
+
+	return Scope{file: cursor.file}
+}
+
+// Returns true if the node `cursor` points to starts a scope. See Scope.
+func startsScope(cursor *Cursor) bool {
+	switch cursor.node.(type) {
+	case *ast.File, *ast.FuncDecl, *ast.FuncLit, *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt,
+		*ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.CaseClause, *ast.CommClause:
+		return true
+	case *ast.BlockStmt:
+		if cursor.parent == nil {
+			return true
+		}
+
+		// The body belongs to the scope of the node, except for else blocks.
+		switch cursor.parent.node.(type) {
+		case *ast.FuncDecl, *ast.FuncLit, *ast.ForStmt, *ast.RangeStmt,
+			*ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			return false
+		case *ast.IfStmt:
+			return cursor.name == "Else"
+		}
+
+		return true
+	default:
+		return false
+	}
+}
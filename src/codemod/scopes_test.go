@@ -0,0 +1,239 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Scope(t *testing.T) {
+	t.Parallel()
+
+	sourceCode := []byte(`package main
+
+var client = newClient()
+
+func (s *Server) Handle() {
+	go func() {
+		log.Println("started")
+	}()
+
+	if ok := check(); ok {
+		log.Println("ok")
+	} else {
+		log.Println("not ok")
+	}
+
+	log.Println("done")
+}
+`)
+
+	// Returns the scope of the only call to `name`.
+	scopeOf := func(t *testing.T, file *codemod.SourceFile, name string) codemod.Scope {
+		t.Helper()
+
+		matches := file.Find(codemod.IsCall(name))
+		assert.Equal(t, 1, len(matches), name)
+
+		return matches[0].Scope
+	}
+
+	// Returns the kinds of nodes from the scope to the package scope.
+	chain := func(scope codemod.Scope) []string {
+		out := make([]string, 0)
+
+		for {
+			switch scope.Node().(type) {
+			case *ast.File:
+				out = append(out, "package")
+			case *ast.FuncDecl:
+				out = append(out, "func "+scope.Name())
+			case *ast.FuncLit:
+				out = append(out, "func literal")
+			case *ast.IfStmt:
+				out = append(out, "if")
+			case *ast.BlockStmt:
+				out = append(out, "block")
+			}
+
+			parent, ok := scope.Parent()
+			if !ok {
+				return out
+			}
+
+			scope = parent
+		}
+	}
+
+	t.Run("package level calls are in the package scope", func(t *testing.T) {
+		t.Parallel()
+
+		file, err := codemod.New(codemod.NewInput{SourceCode: sourceCode})
+		assert.NoError(t, err)
+
+		scope := scopeOf(t, file, "newClient")
+
+		assert.Equal(t, "main", scope.Name())
+		assert.Equal(t, []string{"package"}, chain(scope))
+	})
+
+	t.Run("function literals and blocks have their own scopes", func(t *testing.T) {
+		t.Parallel()
+
+		file, err := codemod.New(codemod.NewInput{SourceCode: sourceCode})
+		assert.NoError(t, err)
+
+		calls := file.FunctionCalls(codemod.IsCall("log.Println"))
+		assert.Equal(t, 4, len(calls))
+
+		chains := make(map[string][]string)
+		for scope, calls := range calls {
+			for _, call := range calls {
+				chains[codemod.SourceCode(call.Node.Args[0])] = chain(scope)
+			}
+		}
+
+		assert.Equal(t, map[string][]string{
+			`"started"`: {"func literal", "func Handle", "package"},
+			`"ok"`:      {"if", "func Handle", "package"},
+			`"not ok"`:  {"block", "if", "func Handle", "package"},
+			`"done"`:    {"func Handle", "package"},
+		}, chains)
+
+		assert.Equal(t, []string{"if", "func Handle", "package"}, chain(scopeOf(t, file, "check")))
+	})
+
+	t.Run("returns the name and receiver of methods", func(t *testing.T) {
+		t.Parallel()
+
+		file, err := codemod.New(codemod.NewInput{SourceCode: sourceCode})
+		assert.NoError(t, err)
+
+		method, ok := scopeOf(t, file, "check").Parent()
+		assert.True(t, ok)
+		assert.Equal(t, "Handle", method.Name())
+		assert.Equal(t, "*Server", method.Receiver())
+
+		pkg, _ := method.Parent()
+		assert.Equal(t, "", pkg.Receiver())
+	})
+
+	t.Run("finders only return nodes inside of the scope", func(t *testing.T) {
+		t.Parallel()
+
+		file, err := codemod.New(codemod.NewInput{SourceCode: sourceCode})
+		assert.NoError(t, err)
+
+		ifScope := scopeOf(t, file, "check")
+
+		calls := 0
+		for _, scopeCalls := range ifScope.FunctionCalls(codemod.IsCall("log.Println")) {
+			calls += len(scopeCalls)
+		}
+		assert.Equal(t, 2, calls)
+
+		method, _ := ifScope.Parent()
+		assert.Equal(t, 1, len(method.IfStatements()))
+		assert.Equal(t, 1, len(method.Bindings()))
+		assert.Equal(t, 0, len(ifScope.Find(codemod.IsCall("newClient"))))
+
+		method.FindCall("log.Println").Remove()
+
+		expected := `package main
+
+var client = newClient()
+
+func (s *Server) Handle() {
+	go func() {
+	}()
+
+	if ok := check(); ok {
+		log.Println("ok")
+	} else {
+		log.Println("not ok")
+	}
+
+	log.Println("done")
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+}
+
+func Test_SourceFile_FindersInOrder(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{FilePath: "main.go", SourceCode: []byte(`package main
+
+var handlers = map[string]int{"a": 1}
+
+func main() {
+	a()
+	if ok := b(); ok {
+		c()
+	}
+	go func() {
+		d()
+	}()
+	e()
+}
+
+func f() {
+	x := map[string]int{}
+	g()
+	x = nil
+}
+`)})
+	assert.NoError(t, err)
+
+	t.Run("returns the results in the order they appear in the file", func(t *testing.T) {
+		t.Parallel()
+
+		names := make([]string, 0)
+		lines := make([]int, 0)
+		for _, call := range file.FunctionCallsInOrder() {
+			names = append(names, call.FunctionName())
+			lines = append(lines, call.Position().Line)
+		}
+
+		assert.Equal(t, []string{"a", "b", "c", "func() {\n\td()\n}", "d", "e", "g"}, names)
+		assert.Equal(t, []int{6, 7, 8, 10, 11, 13, 18}, lines)
+
+		assignments := file.AssignmentsInOrder()
+		assert.Equal(t, 3, len(assignments))
+		assert.Equal(t, "ok := b()", codemod.SourceCode(assignments[0].Node))
+		assert.Equal(t, "f", assignments[2].Scope().Name())
+		assert.Equal(t, 2, len(file.FindAssignmentsInOrder("x")))
+
+		ifs := file.IfStatementsInOrder()
+		assert.Equal(t, 1, len(ifs))
+		assert.Equal(t, "main", ifs[0].Scope().Name())
+	})
+
+	t.Run("returns the file, line and column of each result", func(t *testing.T) {
+		t.Parallel()
+
+		position := file.FunctionCallsInOrder(codemod.IsCall("g"))[0].Position()
+		assert.Equal(t, "main.go", position.Filename)
+		assert.Equal(t, 18, position.Line)
+		assert.Equal(t, 2, position.Column)
+
+		scope, literal := file.FindMapLiteral("map[string]int")
+		assert.Equal(t, "main", scope.Name())
+		assert.Equal(t, 3, literal.Position().Line)
+		assert.Equal(t, 1, scope.Position().Line)
+	})
+
+	t.Run("groups keep the order of the results in each scope", func(t *testing.T) {
+		t.Parallel()
+
+		for scope, calls := range file.FunctionCalls() {
+			for i := 1; i < len(calls); i++ {
+				assert.Less(t, calls[i-1].Position().Offset, calls[i].Position().Offset, scope.Name())
+			}
+		}
+	})
+}
@@ -0,0 +1,873 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A parameter of a function after its signature is changed.
+type Parameter struct {
+	// Index of the parameter in the current signature, -1 for a new parameter.
+	From int
+	// Name of the parameter. An empty name keeps the name of an existing parameter.
+	Name string
+	// Type of a new parameter as Go code, like context.Context.
+	Type string
+	// Argument callers pass to a new parameter as Go code, like context.TODO().
+	// May be empty only for new variadic parameters.
+	Default string
+}
+
+// Keeps the parameter at index `from` of the current signature.
+func KeepParameter(from int) Parameter {
+	return Parameter{From: from}
+}
+
+// Keeps the parameter at index `from` of the current signature and renames it to `name`.
+func RenameParameter(from int, name string) Parameter {
+	return Parameter{From: from, Name: name}
+}
+
+// Adds a parameter called `name` of type `typ`, callers pass `defaultArgument` to it.
+func AddParameter(name, typ, defaultArgument string) Parameter {
+	return Parameter{From: -1, Name: name, Type: typ, Default: defaultArgument}
+}
+
+// A reference to a function that ChangeSignature could not change safely.
+type SkippedCall struct {
+	// Where the reference is, like main.go:10:2.
+	Position string
+	Reason   string
+}
+
+// Changes the parameters of the function to `params`. Parameters that
+// are not in `params` are removed and the others are placed in the order of `params`.
+//
+// The declaration, the methods of interfaces the function implements if it is a method,
+// and every call to them are changed. When the source file has been type checked,
+// calls in every package type checked with it are changed, otherwise only
+// calls in the source file are.
+//
+// Returns the references that were left as they were because changing them
+// could break the code, like function values, calls that spread a multi-value
+// expression or calls where removing or reordering arguments changes
+// the order of side effects. Nothing is changed if an error is returned.
+func (function *Function) ChangeSignature(params []Parameter) ([]SkippedCall, error) {
+	change, err := newSignatureChange(function.file, function.Node.Name.Name, function.Node.Type.Params, []*Function{function}, params)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := change.findReferences(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := change.apply(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return change.skipped, nil
+}
+
+// A parameter in a parameter list, fields like a, b int have more than one.
+type flatParam struct {
+	field *ast.Field
+	// Nil if the parameter does not have a name.
+	name *ast.Ident
+}
+
+func flattenParams(list *ast.FieldList) []flatParam {
+	out := make([]flatParam, 0)
+
+	if list == nil {
+		return out
+	}
+
+	for _, field := range list.List {
+		if len(field.Names) == 0 {
+			out = append(out, flatParam{field: field})
+			continue
+		}
+
+		for _, name := range field.Names {
+			out = append(out, flatParam{field: field, name: name})
+		}
+	}
+
+	return out
+}
+
+func isVariadic(param flatParam) bool {
+	_, ok := param.field.Type.(*ast.Ellipsis)
+	return ok
+}
+
+// A call whose arguments will be replaced.
+type callChange struct {
+	call *ast.CallExpr
+	args []ast.Expr
+}
+
+type signatureChange struct {
+	// The source file the function or interface whose signature changes is declared in.
+	code *SourceFile
+	name string
+	// The declarations that change, the function or the methods that implement an interface method.
+	functions []*Function
+	params    []Parameter
+	current   []flatParam
+	// Types and default arguments of new parameters, indexed like `params`.
+	types    map[int]ast.Expr
+	defaults map[int]string
+	// Interface methods that will change with the function.
+	interfaceMethods []*ast.Field
+	// Functions and methods whose calls are changed.
+	callees map[types.Object]bool
+	calls   []callChange
+	skipped []SkippedCall
+}
+
+// Returns the change of the signature `name`, whose parameters are `list`, to `params`.
+func newSignatureChange(code *SourceFile, name string, list *ast.FieldList, functions []*Function, params []Parameter) (*signatureChange, error) {
+	change := &signatureChange{
+		code:      code,
+		name:      name,
+		functions: functions,
+		params:    params,
+		current:   flattenParams(list),
+		types:     make(map[int]ast.Expr),
+		defaults:  make(map[int]string),
+		callees:   make(map[types.Object]bool),
+	}
+
+	named := len(change.current) > 0 && change.current[0].name != nil
+	if len(change.current) == 0 {
+		named = len(params) > 0 && params[0].Name != ""
+	}
+
+	kept := make(map[int]bool)
+
+	for i, param := range params {
+		if param.From < -1 || param.From >= len(change.current) {
+			return nil, errors.Errorf("%s has no parameter at index %d", name, param.From)
+		}
+
+		if param.Name != "" && !named {
+			return nil, errors.Errorf("can't name parameter %s, the other parameters of %s don't have names", param.Name, name)
+		}
+
+		if param.Name != "" && !token.IsIdentifier(param.Name) {
+			return nil, errors.Errorf("%q is not a valid name", param.Name)
+		}
+
+		if param.From >= 0 {
+			if kept[param.From] {
+				return nil, errors.Errorf("parameter at index %d is used more than once", param.From)
+			}
+
+			kept[param.From] = true
+
+			if isVariadic(change.current[param.From]) && i != len(params)-1 {
+				return nil, errors.Errorf("variadic parameter at index %d must be the last parameter", param.From)
+			}
+
+			continue
+		}
+
+		if named && param.Name == "" {
+			return nil, errors.Errorf("new parameter of type %s needs a name", param.Type)
+		}
+
+		typ, err := parseType(param.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing type of parameter %s", param.Name)
+		}
+
+		if _, variadic := typ.(*ast.Ellipsis); variadic && i != len(params)-1 {
+			return nil, errors.Errorf("variadic parameter %s must be the last parameter", param.Name)
+		}
+
+		if _, variadic := typ.(*ast.Ellipsis); !variadic || param.Default != "" {
+			if _, err := parser.ParseExpr(param.Default); err != nil {
+				return nil, errors.Wrapf(err, "parsing default argument of parameter %s", param.Name)
+			}
+		}
+
+		change.types[i] = typ
+		change.defaults[i] = param.Default
+	}
+
+	// Parameters that are removed must not be used by the functions.
+	for _, function := range functions {
+		for i, param := range flattenParams(function.Node.Type.Params) {
+			if kept[i] || param.name == nil || param.name.Name == "_" {
+				continue
+			}
+
+			if uses := function.file.referencesTo(param.name, function.Node.Body); len(uses) > 0 {
+				return nil, errors.Errorf("can't remove parameter %s, it is used at %s", param.name.Name, function.file.position(uses[0].Pos()))
+			}
+		}
+	}
+
+	return change, nil
+}
+
+// Parses a type, including variadic types like ...string.
+func parseType(source string) (ast.Expr, error) {
+	if strings.HasPrefix(source, "...") {
+		elt, err := parseType(strings.TrimPrefix(source, "..."))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return &ast.Ellipsis{Elt: elt}, nil
+	}
+
+	expr, err := parser.ParseExpr(source)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return cloneNode(expr).(ast.Expr), nil
+}
+
+// Returns the identifiers in `node` that refer to what `declaration` declares.
+//
+// Type information is used when available, otherwise the names
+// resolved by the parser are used.
+func (code *SourceFile) referencesTo(declaration *ast.Ident, node ast.Node) []*ast.Ident {
+	out := make([]*ast.Ident, 0)
+
+	if isNilNode(node) {
+		return out
+	}
+
+	var obj types.Object
+	if code.pkg != nil {
+		obj = code.pkg.info.Defs[declaration]
+	}
+
+	ast.Inspect(node, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if !ok || ident == declaration {
+			return true
+		}
+
+		if obj != nil && sameObject(code.pkg.info.Uses[ident], obj) {
+			out = append(out, ident)
+		} else if obj == nil && declaration.Obj != nil && ident.Obj == declaration.Obj {
+			out = append(out, ident)
+		}
+
+		return true
+	})
+
+	return out
+}
+
+// Returns the position of `pos` in the source file, like main.go:10:2.
+func (code *SourceFile) position(pos token.Pos) string {
+	position := code.fileSet.Position(pos)
+
+	return fmt.Sprintf("%s:%d:%d", code.FilePath, position.Line, position.Column)
+}
+
+// Returns the source files calls may be in.
+func (change *signatureChange) files() []*SourceFile {
+	code := change.code
+
+	if code.pkg == nil {
+		return []*SourceFile{code}
+	}
+
+	out := make([]*SourceFile, 0)
+
+	for _, pkg := range code.pkg.packages {
+		out = append(out, pkg.files...)
+	}
+
+	return out
+}
+
+// Finds the interface methods that change with the functions and the calls to them.
+func (change *signatureChange) findReferences() error {
+	if change.code.pkg != nil {
+		fns := make([]*types.Func, 0, len(change.functions))
+
+		for _, function := range change.functions {
+			fn, ok := function.file.pkg.info.Defs[function.Node.Name].(*types.Func)
+			if !ok {
+				return errors.Errorf("no type information for %s", function.Node.Name.Name)
+			}
+
+			change.callees[fn] = true
+			fns = append(fns, fn)
+		}
+
+		// Every function is a callee before looking for implementations that don't change.
+		for _, fn := range fns {
+			change.findInterfaceMethods(fn)
+		}
+	} else {
+		change.findInterfaceMethodsWithoutTypes()
+	}
+
+	for _, sourceFile := range change.files() {
+		change.findCalls(sourceFile)
+	}
+
+	return nil
+}
+
+// Finds the methods of interfaces that `fn` implements, which must have the same signature.
+func (change *signatureChange) findInterfaceMethods(fn *types.Func) {
+	signature := fn.Type().(*types.Signature)
+	if signature.Recv() == nil {
+		return
+	}
+
+	recv := signature.Recv().Type()
+	if pointer, ok := recv.(*types.Pointer); ok {
+		recv = pointer.Elem()
+	}
+
+	interfaces := make([]*types.Interface, 0)
+
+	for _, sourceFile := range change.files() {
+		info := sourceFile.pkg.info
+
+		ast.Inspect(sourceFile.file, func(node ast.Node) bool {
+			interfaceType, ok := node.(*ast.InterfaceType)
+			if !ok {
+				return true
+			}
+
+			for _, field := range interfaceType.Methods.List {
+				if len(field.Names) == 0 {
+					continue
+				}
+
+				method, ok := info.Defs[field.Names[0]].(*types.Func)
+				if !ok || change.callees[method] || method.Name() != fn.Name() || !sameParams(method.Type().(*types.Signature), signature) {
+					continue
+				}
+
+				iface, ok := info.TypeOf(interfaceType).(*types.Interface)
+				if !ok || (!types.Implements(recv, iface) && !types.Implements(types.NewPointer(recv), iface)) {
+					continue
+				}
+
+				change.interfaceMethods = append(change.interfaceMethods, field)
+				change.callees[method] = true
+				interfaces = append(interfaces, iface)
+			}
+
+			return true
+		})
+	}
+
+	change.findOtherImplementations(fn, recv, interfaces)
+}
+
+// Records the methods of other types that implement `interfaces`,
+// they are not changed and stop implementing the interfaces.
+func (change *signatureChange) findOtherImplementations(fn *types.Func, recv types.Type, interfaces []*types.Interface) {
+	for _, pkg := range change.code.pkg.packages {
+		scope := pkg.types.Scope()
+
+		for _, name := range scope.Names() {
+			typeName, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || types.Identical(typeName.Type(), recv) || types.IsInterface(typeName.Type()) {
+				continue
+			}
+
+			for _, iface := range interfaces {
+				if !types.Implements(typeName.Type(), iface) && !types.Implements(types.NewPointer(typeName.Type()), iface) {
+					continue
+				}
+
+				method, _, _ := types.LookupFieldOrMethod(typeName.Type(), true, typeName.Pkg(), fn.Name())
+				if method == nil || change.callees[method] {
+					break
+				}
+
+				change.skipped = append(change.skipped, SkippedCall{
+					Position: change.code.fileSet.Position(method.Pos()).String(),
+					Reason:   fmt.Sprintf("%s implements an interface whose method %s changed, but %s.%s was not changed", name, fn.Name(), name, fn.Name()),
+				})
+
+				break
+			}
+		}
+	}
+}
+
+// Returns true if the parameters and results of `a` and `b` have the same types.
+func sameParams(a, b *types.Signature) bool {
+	return a.Variadic() == b.Variadic() && types.Identical(a.Params(), b.Params()) && types.Identical(a.Results(), b.Results())
+}
+
+// Finds the interface methods in the source file that have the same name
+// and the same parameter and result types, as written in the source code, as the method.
+func (change *signatureChange) findInterfaceMethodsWithoutTypes() {
+	decl := change.functions[0].Node
+	if decl.Recv == nil {
+		return
+	}
+
+	ast.Inspect(change.code.file, func(node ast.Node) bool {
+		interfaceType, ok := node.(*ast.InterfaceType)
+		if !ok {
+			return true
+		}
+
+		for _, field := range interfaceType.Methods.List {
+			funcType, ok := field.Type.(*ast.FuncType)
+			if !ok || len(field.Names) == 0 || field.Names[0].Name != decl.Name.Name {
+				continue
+			}
+
+			if typesSource(funcType.Params) == typesSource(decl.Type.Params) && typesSource(funcType.Results) == typesSource(decl.Type.Results) {
+				change.interfaceMethods = append(change.interfaceMethods, field)
+			}
+		}
+
+		return true
+	})
+}
+
+// Returns the types in `list` as Go code, one for each name.
+func typesSource(list *ast.FieldList) string {
+	out := make([]string, 0)
+
+	for _, param := range flattenParams(list) {
+		out = append(out, SourceCode(param.field.Type))
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// Finds the references to the function in `sourceFile` and the new arguments of each call.
+func (change *signatureChange) findCalls(sourceFile *SourceFile) {
+	stack := make([]ast.Node, 0)
+
+	ast.Inspect(sourceFile.file, func(node ast.Node) bool {
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+
+		if ident, ok := node.(*ast.Ident); ok {
+			change.checkReference(sourceFile, ident, stack)
+		}
+
+		stack = append(stack, node)
+
+		return true
+	})
+}
+
+// Returns true if `ident` refers to the function or to one of the interface methods.
+// `ok` is false if that can't be known.
+func (change *signatureChange) refersToFunction(sourceFile *SourceFile, ident *ast.Ident, parent ast.Node) (refers bool, ok bool) {
+	decl := change.functions[0].Node
+
+	if sourceFile.pkg != nil {
+		if _, declares := sourceFile.pkg.info.Defs[ident]; declares {
+			return false, true
+		}
+
+		for callee := range change.callees {
+			if sameObject(sourceFile.pkg.info.Uses[ident], callee) {
+				return true, true
+			}
+		}
+
+		return false, true
+	}
+
+	if ident.Name != decl.Name.Name || ident == decl.Name {
+		return false, true
+	}
+
+	if decl.Recv == nil {
+		return decl.Name.Obj != nil && ident.Obj == decl.Name.Obj, true
+	}
+
+	// Without type information, x.Method could be any method with the same name.
+	selector, isSelector := parent.(*ast.SelectorExpr)
+
+	return false, !(isSelector && selector.Sel == ident)
+}
+
+// Checks if `ident` is a reference to the function and records how to change it.
+func (change *signatureChange) checkReference(sourceFile *SourceFile, ident *ast.Ident, stack []ast.Node) {
+	var parent ast.Node
+	if len(stack) > 0 {
+		parent = stack[len(stack)-1]
+	}
+
+	refers, ok := change.refersToFunction(sourceFile, ident, parent)
+
+	if !ok {
+		change.skip(sourceFile, ident.Pos(), fmt.Sprintf("can't tell if %s is a call to the method without type information", SourceCode(parent)))
+		return
+	}
+
+	if !refers {
+		return
+	}
+
+	// Offset of the first argument that is a parameter, method expressions
+	// like T.Method(t, x) take the receiver as first argument.
+	offset := 0
+
+	callee := ast.Node(ident)
+
+	if selector, ok := parent.(*ast.SelectorExpr); ok && selector.Sel == ident {
+		callee = selector
+
+		if sourceFile.pkg != nil {
+			if selection, ok := sourceFile.pkg.info.Selections[selector]; ok && selection.Kind() == types.MethodExpr {
+				offset = 1
+			}
+		}
+
+		if len(stack) > 1 {
+			parent = stack[len(stack)-2]
+		} else {
+			parent = nil
+		}
+	}
+
+	call, ok := parent.(*ast.CallExpr)
+	if !ok || call.Fun != callee {
+		change.skip(sourceFile, ident.Pos(), fmt.Sprintf("%s is used without being called", ident.Name))
+		return
+	}
+
+	args, reason := change.newArgs(sourceFile, call, offset)
+	if reason != "" {
+		change.skip(sourceFile, call.Pos(), reason)
+		return
+	}
+
+	change.calls = append(change.calls, callChange{call: call, args: args})
+}
+
+func (change *signatureChange) skip(sourceFile *SourceFile, pos token.Pos, reason string) {
+	change.skipped = append(change.skipped, SkippedCall{Position: sourceFile.position(pos), Reason: reason})
+}
+
+// Returns the arguments of `call` after the signature changes
+// or the reason the call can't be changed.
+func (change *signatureChange) newArgs(sourceFile *SourceFile, call *ast.CallExpr, offset int) ([]ast.Expr, string) {
+	args := call.Args[offset:]
+
+	variadic := len(change.current) > 0 && isVariadic(change.current[len(change.current)-1])
+
+	fixed := len(change.current)
+	if variadic {
+		fixed--
+	}
+
+	if len(args) == 1 && (fixed > 1 || isTuple(sourceFile, args[0])) {
+		return nil, "the call passes a multi-value expression as arguments"
+	}
+
+	if len(args) < fixed {
+		return nil, fmt.Sprintf("the call has %d arguments, expected at least %d", len(args), fixed)
+	}
+
+	// Arguments of each parameter, variadic parameters may have zero or more.
+	current := make([][]ast.Expr, len(change.current))
+
+	for i := range change.current {
+		if i < fixed {
+			current[i] = args[i : i+1]
+		} else {
+			current[i] = args[i:]
+		}
+	}
+
+	if call.Ellipsis.IsValid() {
+		last := len(change.params) - 1
+		if last < 0 || change.params[last].From != len(change.current)-1 {
+			return nil, "the call spreads a slice into the variadic parameter, which is no longer the last parameter"
+		}
+	}
+
+	out := make([]ast.Expr, 0, len(call.Args))
+	out = append(out, call.Args[:offset]...)
+
+	// Indexes of the current parameters in the order their arguments are evaluated after the change.
+	order := make([]int, 0)
+
+	for i, param := range change.params {
+		if param.From >= 0 {
+			out = append(out, current[param.From]...)
+			order = append(order, param.From)
+			continue
+		}
+
+		if change.defaults[i] == "" {
+			continue
+		}
+
+		expr, _ := parser.ParseExpr(change.defaults[i])
+		out = append(out, cloneNode(expr).(ast.Expr))
+	}
+
+	kept := make(map[int]bool)
+	for _, from := range order {
+		kept[from] = true
+	}
+
+	for i, exprs := range current {
+		for _, expr := range exprs {
+			if !kept[i] && hasSideEffects(expr) {
+				return nil, fmt.Sprintf("removing argument %s would remove its side effects", SourceCode(expr))
+			}
+		}
+	}
+
+	// Arguments with side effects must be evaluated in the same order.
+	last := -1
+
+	for _, from := range order {
+		for _, expr := range current[from] {
+			if !hasSideEffects(expr) {
+				continue
+			}
+
+			if from < last {
+				return nil, fmt.Sprintf("reordering argument %s would change the order its side effects happen in", SourceCode(expr))
+			}
+
+			last = from
+		}
+	}
+
+	return out, ""
+}
+
+// Returns true if `expr` is known to evaluate to more than one value.
+func isTuple(sourceFile *SourceFile, expr ast.Expr) bool {
+	_, ok := sourceFile.TypeOf(expr).(*types.Tuple)
+	return ok
+}
+
+// Returns true if evaluating `expr` may have side effects:
+// it calls a function or receives from a channel.
+func hasSideEffects(expr ast.Expr) bool {
+	found := false
+
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			found = true
+		case *ast.UnaryExpr:
+			found = found || node.Op == token.ARROW
+		case *ast.FuncLit:
+			return false
+		}
+
+		return !found
+	})
+
+	return found
+}
+
+// Changes the declaration, the interface methods and the calls.
+func (change *signatureChange) apply() error {
+	// Every rename is checked before anything is changed because renaming may fail.
+	type rename struct {
+		idents []*ast.Ident
+		name   string
+	}
+
+	renames := make([]rename, 0)
+
+	for _, function := range change.functions {
+		current := flattenParams(function.Node.Type.Params)
+
+		for _, param := range change.params {
+			if param.From < 0 || param.Name == "" {
+				continue
+			}
+
+			name := current[param.From].name
+			if name == nil || name.Name == param.Name {
+				continue
+			}
+
+			idents, err := function.file.paramRename(name, function.Node.Body, param.Name)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			renames = append(renames, rename{idents: idents, name: param.Name})
+		}
+	}
+
+	for _, rename := range renames {
+		for _, ident := range rename.idents {
+			ident.Name = rename.name
+		}
+	}
+
+	for _, function := range change.functions {
+		function.Node.Type.Params = change.paramList(function.Node.Type.Params, true)
+	}
+
+	for _, method := range change.interfaceMethods {
+		funcType := method.Type.(*ast.FuncType)
+		funcType.Params = change.paramList(funcType.Params, false)
+	}
+
+	for _, call := range change.calls {
+		call.call.Args = call.args
+	}
+
+	return nil
+}
+
+// Returns the identifiers that must be renamed to rename the parameter `name` declares
+// to `newName`: the declaration and its references in `body`.
+func (code *SourceFile) paramRename(name *ast.Ident, body *ast.BlockStmt, newName string) ([]*ast.Ident, error) {
+	if code.pkg != nil {
+		if obj := code.pkg.info.Defs[name]; obj != nil {
+			packages := []*packageInfo{code.pkg}
+
+			idents := identsOf(packages, obj)
+
+			if err := checkRename(packages, obj, newName, idents); err != nil {
+				return nil, errors.WithStack(err)
+			}
+
+			out := make([]*ast.Ident, 0, len(idents))
+			for _, ident := range idents {
+				out = append(out, ident.ident)
+			}
+
+			return out, nil
+		}
+	}
+
+	// Without type information, the name must not be used in the function at all.
+	used := false
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && ident.Name == newName {
+			used = true
+		}
+
+		return !used
+	})
+
+	if used {
+		return nil, errors.Errorf("can't rename parameter %s to %s: %s is used in the function", name.Name, newName, newName)
+	}
+
+	return append(code.referencesTo(name, body), name), nil
+}
+
+// Returns the parameter list `list` becomes. Renamed parameters of interface methods
+// are renamed here, the parameters of functions are renamed with their references before.
+//
+// Parameters that were declared together, like a, b int, stay together if they are kept in order.
+func (change *signatureChange) paramList(list *ast.FieldList, isFunction bool) *ast.FieldList {
+	current := flattenParams(list)
+
+	named := len(current) > 0 && current[0].name != nil
+	if len(current) == 0 {
+		named = len(change.params) > 0 && change.params[0].Name != ""
+	}
+
+	out := &ast.FieldList{Opening: list.Opening, Closing: list.Closing}
+
+	// Types that are already in the new list, a type used twice is copied.
+	used := make(map[ast.Expr]bool)
+
+	for i, param := range change.params {
+		if param.From < 0 {
+			field := &ast.Field{Type: change.types[i]}
+
+			if named {
+				name := param.Name
+				if name == "" {
+					name = "_"
+				}
+
+				field.Names = []*ast.Ident{ast.NewIdent(name)}
+			}
+
+			// Each signature gets its own copy of the type.
+			change.types[i] = cloneNode(change.types[i]).(ast.Expr)
+
+			out.List = append(out.List, field)
+
+			continue
+		}
+
+		param := current[param.From]
+
+		if !isFunction && param.name != nil && change.params[i].Name != "" {
+			param.name.Name = change.params[i].Name
+		}
+
+		previous := i - 1
+		if previous >= 0 && change.params[previous].From >= 0 && change.params[previous].From == change.params[i].From-1 &&
+			current[change.params[previous].From].field == param.field && param.name != nil {
+			last := out.List[len(out.List)-1]
+			last.Names = append(last.Names, param.name)
+			continue
+		}
+
+		typ := param.field.Type
+		if used[typ] {
+			typ = cloneNode(typ).(ast.Expr)
+		}
+
+		used[param.field.Type] = true
+
+		field := &ast.Field{Type: typ}
+		if param.name != nil {
+			field.Names = []*ast.Ident{param.name}
+		}
+
+		out.List = append(out.List, field)
+	}
+
+	// Fields that did not change are kept so their comments are kept.
+	for i, field := range out.List {
+		for _, original := range list.List {
+			if field.Type == original.Type && sameIdents(field.Names, original.Names) {
+				out.List[i] = original
+			}
+		}
+	}
+
+	return out
+}
+
+func sameIdents(a, b []*ast.Ident) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,264 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func findFunction(t *testing.T, file *codemod.SourceFile, name string) codemod.Function {
+	t.Helper()
+
+	for _, function := range file.Functions() {
+		if function.Node.Name.Name == name {
+			return function
+		}
+	}
+
+	assert.FailNow(t, "function not found: "+name)
+
+	return codemod.Function{}
+}
+
+func Test_Function_ChangeSignature(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reorders parameters of the function, interface methods and calls", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import "context"
+
+type UserService interface {
+	Find(int64, context.Context) error
+}
+
+type service struct{}
+
+func (service) Find(userID int64, ctx context.Context) error {
+	return find(userID, ctx)
+}
+
+func find(userID int64, ctx context.Context) error {
+	return nil
+}
+
+func main() {
+	_ = find(1, context.Background())
+}
+`)})
+		assert.NoError(t, err)
+
+		find := findFunction(t, file, "find")
+
+		skipped, err := find.ChangeSignature([]codemod.Parameter{codemod.KeepParameter(1), codemod.KeepParameter(0)})
+		assert.NoError(t, err)
+		assert.Empty(t, skipped)
+
+		method := file.Functions()[0]
+
+		skipped, err = method.ChangeSignature([]codemod.Parameter{codemod.KeepParameter(1), codemod.KeepParameter(0)})
+		assert.NoError(t, err)
+		assert.Empty(t, skipped)
+
+		expected := `package main
+
+import "context"
+
+type UserService interface {
+	Find(context.Context, int64) error
+}
+
+type service struct{}
+
+func (service) Find(ctx context.Context, userID int64) error {
+	return find(ctx, userID)
+}
+
+func find(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func main() {
+	_ = find(context.Background(), 1)
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("adds, removes and renames parameters", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func greet(name, unused string, times int) {
+	for i := 0; i < times; i++ {
+		println(name)
+	}
+}
+
+func main() {
+	greet("bob", "", 2)
+}
+`)})
+		assert.NoError(t, err)
+
+		greet := findFunction(t, file, "greet")
+
+		skipped, err := greet.ChangeSignature([]codemod.Parameter{
+			codemod.RenameParameter(0, "who"),
+			codemod.KeepParameter(2),
+			codemod.AddParameter("prefix", "string", `"hello"`),
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, skipped)
+
+		expected := `package main
+
+func greet(who string, times int, prefix string) {
+	for i := 0; i < times; i++ {
+		println(who)
+	}
+}
+
+func main() {
+	greet("bob", 2, "hello")
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("renames named parameters of interface methods", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+type Greeter interface {
+	Greet(name string, times int)
+}
+
+type Logger interface {
+	Greet(string, int)
+}
+
+type greeter struct{}
+
+func (greeter) Greet(name string, times int) {
+	println(name, times)
+}
+`)})
+		assert.NoError(t, err)
+
+		greet := findFunction(t, file, "Greet")
+
+		skipped, err := greet.ChangeSignature([]codemod.Parameter{
+			codemod.RenameParameter(0, "who"),
+			codemod.KeepParameter(1),
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, skipped)
+
+		expected := `package main
+
+type Greeter interface {
+	Greet(who string, times int)
+}
+
+type Logger interface {
+	Greet(string, int)
+}
+
+type greeter struct{}
+
+func (greeter) Greet(who string, times int) {
+	println(who, times)
+}
+`
+
+		assert.Equal(t, expected, string(file.SourceCode()))
+	})
+
+	t.Run("reports references it could not change", func(t *testing.T) {
+		file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func add(a, b int) int { return a + b }
+
+func pair() (int, int) { return 1, 2 }
+
+func main() {
+	f := add
+	_ = add(pair())
+	_ = add(1, pair2())
+	_ = add(3, 4)
+}
+`)})
+		assert.NoError(t, err)
+
+		add := findFunction(t, file, "add")
+
+		skipped, err := add.ChangeSignature([]codemod.Parameter{codemod.KeepParameter(0)})
+		assert.Error(t, err)
+		assert.Empty(t, skipped)
+
+		skipped, err = add.ChangeSignature([]codemod.Parameter{
+			codemod.KeepParameter(1),
+			codemod.KeepParameter(0),
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, len(skipped))
+		assert.Equal(t, ":8:7", skipped[0].Position)
+		assert.Contains(t, skipped[0].Reason, "without being called")
+		assert.Contains(t, skipped[1].Reason, "multi-value")
+
+		assert.Contains(t, string(file.SourceCode()), "_ = add(pair2(), 1)")
+		assert.Contains(t, string(file.SourceCode()), "_ = add(4, 3)")
+	})
+
+	t.Run("changes calls and interfaces in other packages", func(t *testing.T) {
+		files, err := codemod.NewTypeChecked(writeModule(t, map[string]string{
+			"users/users.go": `package users
+
+type Repository interface {
+	Find(id int) string
+}
+
+type Postgres struct{}
+
+func (Postgres) Find(id int) string { return "" }
+
+type Memory struct{}
+
+func (Memory) Find(id int) string { return "" }
+`,
+			"main.go": `package main
+
+import "example.com/project/users"
+
+func main() {
+	var repo users.Repository = users.Postgres{}
+	repo.Find(1)
+	users.Postgres{}.Find(2)
+}
+`,
+		}))
+		assert.NoError(t, err)
+
+		users := findFile(t, files, "users.go")
+
+		skipped, err := users.Functions()[0].ChangeSignature([]codemod.Parameter{
+			codemod.AddParameter("ctx", "context.Context", "context.TODO()"),
+			codemod.KeepParameter(0),
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1, len(skipped))
+		assert.Contains(t, skipped[0].Reason, "Memory.Find was not changed")
+
+		assert.Contains(t, string(users.SourceCode()), "Find(ctx context.Context, id int) string\n}")
+		assert.Contains(t, string(users.SourceCode()), "func (Postgres) Find(ctx context.Context, id int) string")
+
+		main := string(findFile(t, files, "main.go").SourceCode())
+
+		assert.Contains(t, main, "repo.Find(context.TODO(), 1)")
+		assert.Contains(t, main, "users.Postgres{}.Find(context.TODO(), 2)")
+	})
+}
@@ -0,0 +1,216 @@
+package codemod
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A struct tag, like `json:"name,omitempty" yaml:"name"`.
+//
+// Keys are kept in the order they appear in,
+// new keys are added after the existing ones.
+type Tag struct {
+	entries []tagEntry
+}
+
+type tagEntry struct {
+	key   string
+	value string
+}
+
+// Parses a struct tag that follows the key:"value" convention,
+// with or without the quotes around it: `json:"name"` and json:"name"
+// are the same tag.
+func ParseTag(tag string) (*Tag, error) {
+	tag = strings.TrimSpace(tag)
+
+	if len(tag) > 0 && (tag[0] == '`' || tag[0] == '"') {
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid struct tag %s", tag)
+		}
+
+		tag = unquoted
+	}
+
+	out := &Tag{}
+
+	for {
+		tag = strings.TrimLeft(tag, " ")
+
+		if tag == "" {
+			return out, nil
+		}
+
+		// Keys are any characters other than spaces, quotes, colons and control characters.
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return nil, errors.Errorf("invalid struct tag %q: expected key:\"value\"", tag)
+		}
+
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		// Find the closing quote of the value, skipping escaped quotes.
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+
+			i++
+		}
+
+		if i >= len(tag) {
+			return nil, errors.Errorf("invalid struct tag: missing closing quote for the value of %s", key)
+		}
+
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid struct tag: invalid value for %s", key)
+		}
+
+		tag = tag[i+1:]
+
+		if tag != "" && tag[0] != ' ' {
+			return nil, errors.Errorf("invalid struct tag: expected a space after the value of %s", key)
+		}
+
+		out.entries = append(out.entries, tagEntry{key: key, value: value})
+	}
+}
+
+// Returns the keys of the tag in the order they appear in.
+func (tag *Tag) Keys() []string {
+	out := make([]string, 0, len(tag.entries))
+
+	for _, entry := range tag.entries {
+		out = append(out, entry.key)
+	}
+
+	return out
+}
+
+// Returns the value of `key`, like name,omitempty for json:"name,omitempty".
+func (tag *Tag) Get(key string) (string, bool) {
+	for _, entry := range tag.entries {
+		if entry.key == key {
+			return entry.value, true
+		}
+	}
+
+	return "", false
+}
+
+// Sets the value of `key`, adding the key after the existing keys if the tag does not have it.
+func (tag *Tag) Set(key, value string) {
+	for i := range tag.entries {
+		if tag.entries[i].key == key {
+			tag.entries[i].value = value
+			return
+		}
+	}
+
+	tag.entries = append(tag.entries, tagEntry{key: key, value: value})
+}
+
+// Removes `key` from the tag.
+func (tag *Tag) Remove(key string) {
+	entries := make([]tagEntry, 0, len(tag.entries))
+
+	for _, entry := range tag.entries {
+		if entry.key != key {
+			entries = append(entries, entry)
+		}
+	}
+
+	tag.entries = entries
+}
+
+// Returns the part of the value of `key` before the options,
+// like name for json:"name,omitempty".
+func (tag *Tag) Name(key string) string {
+	value, _ := tag.Get(key)
+
+	return strings.Split(value, ",")[0]
+}
+
+// Returns the options in the value of `key`, like omitempty for json:"name,omitempty".
+func (tag *Tag) Options(key string) []string {
+	value, _ := tag.Get(key)
+
+	parts := strings.Split(value, ",")
+
+	return parts[1:]
+}
+
+// Returns true if `option` is one of the options in the value of `key`.
+func (tag *Tag) HasOption(key, option string) bool {
+	for _, existing := range tag.Options(key) {
+		if existing == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Adds `option` to the options in the value of `key`,
+// adding the key if the tag does not have it.
+func (tag *Tag) AddOption(key, option string) {
+	if tag.HasOption(key, option) {
+		return
+	}
+
+	value, _ := tag.Get(key)
+
+	tag.Set(key, value+","+option)
+}
+
+// Removes `option` from the options in the value of `key`.
+func (tag *Tag) RemoveOption(key, option string) {
+	value, ok := tag.Get(key)
+	if !ok {
+		return
+	}
+
+	parts := strings.Split(value, ",")
+
+	kept := []string{parts[0]}
+
+	for _, existing := range parts[1:] {
+		if existing != option {
+			kept = append(kept, existing)
+		}
+	}
+
+	tag.Set(key, strings.Join(kept, ","))
+}
+
+// Returns the tag without the quotes around it, like json:"name" yaml:"name".
+func (tag *Tag) String() string {
+	entries := make([]string, 0, len(tag.entries))
+
+	for _, entry := range tag.entries {
+		entries = append(entries, entry.key+":"+strconv.Quote(entry.value))
+	}
+
+	return strings.Join(entries, " ")
+}
+
+// Returns the tag as it's written in the source code, like `json:"name"`.
+func (tag *Tag) literal() string {
+	s := tag.String()
+
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+
+	return "`" + s + "`"
+}
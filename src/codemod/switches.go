@@ -0,0 +1,518 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+type TypeSwitchStmt struct {
+	Parent NodeWithParent
+	Node   *ast.TypeSwitchStmt
+	cursor *Cursor
+}
+
+func (stmt *TypeSwitchStmt) InsertAfter(node ast.Node) {
+	insertAfter(stmt.cursor, node)
+}
+
+func (stmt *TypeSwitchStmt) InsertBefore(node ast.Node) {
+	insertBefore(stmt.cursor, node)
+}
+
+func (stmt *TypeSwitchStmt) Remove() {
+	remove(stmt.cursor)
+}
+
+// Returns the cursor that points to the statement.
+func (stmt *TypeSwitchStmt) Cursor() *Cursor {
+	return stmt.cursor
+}
+
+// Returns the innermost scope the statement is in.
+func (stmt *TypeSwitchStmt) Scope() Scope {
+	return scopeOf(stmt.cursor)
+}
+
+// Returns the file, line and column where the statement starts.
+func (stmt *TypeSwitchStmt) Position() token.Position {
+	return stmt.cursor.file.nodePosition(stmt.Node)
+}
+
+// Returns the type switches, like switch v := x.(type) {}, grouped by the innermost scope they are in.
+//
+// See TypeSwitchStatementsInOrder to visit them in the order they appear in.
+func (code *SourceFile) TypeSwitchStatements(matchers ...Matcher) map[Scope][]TypeSwitchStmt {
+	out := make(map[Scope][]TypeSwitchStmt)
+
+	for _, stmt := range code.TypeSwitchStatementsInOrder(matchers...) {
+		scope := stmt.Scope()
+		out[scope] = append(out[scope], stmt)
+	}
+
+	return out
+}
+
+// Returns the type switches in the order they appear in the file.
+func (code *SourceFile) TypeSwitchStatementsInOrder(matchers ...Matcher) []TypeSwitchStmt {
+	out := make([]TypeSwitchStmt, 0)
+
+	for _, match := range code.find("TypeSwitchStmt", matchers) {
+		out = append(out, TypeSwitchStmt{
+			Parent: match.Cursor.parent.nodeWithParent(),
+			Node:   match.Node.(*ast.TypeSwitchStmt),
+			cursor: match.Cursor,
+		})
+	}
+
+	return out
+}
+
+// A case of a switch or type switch statement, like case "a", "b": or default:.
+type CaseClause struct {
+	Node *ast.CaseClause
+	file *SourceFile
+	// The body of the switch the case belongs to.
+	switchBody *ast.BlockStmt
+}
+
+// Returns the cases of the switch in the order they appear in.
+func (stmt *SwitchStmt) Cases() []CaseClause {
+	return casesOf(stmt.cursor.file, stmt.Node.Body)
+}
+
+// Returns the case that has the expression `expr`, like StatusActive in case StatusActive, StatusPending:.
+func (stmt *SwitchStmt) Case(expr string) (CaseClause, bool) {
+	return findCase(stmt.Cases(), expr)
+}
+
+// Returns the default case.
+func (stmt *SwitchStmt) Default() (CaseClause, bool) {
+	return findDefault(stmt.Cases())
+}
+
+// Adds a case with the expressions `exprs` and the statements `body` before the default case,
+// or after the other cases if there's no default case.
+//
+// Returns error if an expression is not valid or if the switch already has a case for it.
+func (stmt *SwitchStmt) AddCase(exprs []string, body []ast.Stmt) (CaseClause, error) {
+	return addCase(stmt.cursor.file, stmt.Node.Body, exprs, body)
+}
+
+// Adds a default case with the statements `body` after the other cases.
+//
+// Returns error if the switch already has a default case.
+func (stmt *SwitchStmt) AddDefault(body []ast.Stmt) (CaseClause, error) {
+	return addDefault(stmt.cursor.file, stmt.Node.Body, body)
+}
+
+// Removes the case that has the expression `expr`, with every other expression in it.
+// Use CaseClause.RemoveExpr to only remove the expression.
+//
+// Returns error if there's no case for `expr` or if the case before it falls through to it.
+func (stmt *SwitchStmt) RemoveCase(expr string) error {
+	return removeCase(stmt.Cases(), expr)
+}
+
+// Merges the cases that have the expressions `exprs` into the first of them,
+// case A: f() and case B: f() become case A, B: f().
+//
+// Returns error if there's no case for one of the expressions, if the bodies of the cases
+// are not the same or if merging them would change where the cases fall through to.
+func (stmt *SwitchStmt) MergeCases(exprs ...string) (CaseClause, error) {
+	return mergeCases(stmt.Cases(), exprs, "")
+}
+
+// Returns the cases of the type switch in the order they appear in.
+func (stmt *TypeSwitchStmt) Cases() []CaseClause {
+	return casesOf(stmt.cursor.file, stmt.Node.Body)
+}
+
+// Returns the case that has the type `typ`, like *os.PathError in case *os.PathError:.
+func (stmt *TypeSwitchStmt) Case(typ string) (CaseClause, bool) {
+	return findCase(stmt.Cases(), typ)
+}
+
+// Returns the default case.
+func (stmt *TypeSwitchStmt) Default() (CaseClause, bool) {
+	return findDefault(stmt.Cases())
+}
+
+// Adds a case with the types `types` and the statements `body` before the default case,
+// or after the other cases if there's no default case.
+//
+// Returns error if a type is not valid or if the switch already has a case for it.
+func (stmt *TypeSwitchStmt) AddCase(types []string, body []ast.Stmt) (CaseClause, error) {
+	return addCase(stmt.cursor.file, stmt.Node.Body, types, body)
+}
+
+// Adds a default case with the statements `body` after the other cases.
+//
+// Returns error if the switch already has a default case.
+func (stmt *TypeSwitchStmt) AddDefault(body []ast.Stmt) (CaseClause, error) {
+	return addDefault(stmt.cursor.file, stmt.Node.Body, body)
+}
+
+// Removes the case that has the type `typ`, with every other type in it.
+//
+// Returns error if there's no case for `typ`.
+func (stmt *TypeSwitchStmt) RemoveCase(typ string) error {
+	return removeCase(stmt.Cases(), typ)
+}
+
+// Merges the cases that have the types `types` into the first of them.
+//
+// Returns error if there's no case for one of the types, if the bodies of the cases are not the same
+// or if the bodies use the variable bound by the switch, like v in switch v := x.(type),
+// since in a case with more than one type the variable has the type of x.
+func (stmt *TypeSwitchStmt) MergeCases(types ...string) (CaseClause, error) {
+	return mergeCases(stmt.Cases(), types, stmt.boundVariable())
+}
+
+// Returns the name of the variable bound by the switch, like v in switch v := x.(type),
+// or an empty string if the switch does not bind a variable.
+func (stmt *TypeSwitchStmt) boundVariable() string {
+	assign, ok := stmt.Node.Assign.(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 {
+		return ""
+	}
+
+	ident, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok || ident.Name == "_" {
+		return ""
+	}
+
+	return ident.Name
+}
+
+// Returns true if the case is the default case.
+func (clause *CaseClause) IsDefault() bool {
+	return clause.Node.List == nil
+}
+
+// Returns the expressions of the case as they are written in the source code,
+// or the types for cases of type switches.
+func (clause *CaseClause) Exprs() []string {
+	out := make([]string, 0, len(clause.Node.List))
+
+	for _, expr := range clause.Node.List {
+		out = append(out, SourceCode(expr))
+	}
+
+	return out
+}
+
+// Returns true if `expr` is one of the expressions of the case.
+func (clause *CaseClause) Has(expr string) bool {
+	return clause.indexOf(expr) != -1
+}
+
+// Adds `expr` after the other expressions of the case.
+//
+// Returns error if `expr` is not valid, if the case is the default case
+// or if the switch already has a case for `expr`.
+func (clause *CaseClause) AddExpr(expr string) error {
+	if clause.IsDefault() {
+		return errors.Errorf("can't add %s to the default case", expr)
+	}
+
+	caseExpr, err := clause.newExpr(expr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	clause.Node.List = append(clause.Node.List, caseExpr)
+
+	return nil
+}
+
+// Removes `expr` from the expressions of the case.
+//
+// Returns error if the case does not have `expr` or if it's the only expression of the case,
+// since the case would become the default case. Use Remove to remove the case.
+func (clause *CaseClause) RemoveExpr(expr string) error {
+	index := clause.indexOf(expr)
+	if index == -1 {
+		return errors.Errorf("can't remove %s: case does not have it", expr)
+	}
+
+	if len(clause.Node.List) == 1 {
+		return errors.Errorf("can't remove %s: it's the only expression of the case", expr)
+	}
+
+	clause.Node.List = append(clause.Node.List[:index:index], clause.Node.List[index+1:]...)
+
+	return nil
+}
+
+// Replaces the expression `old` of the case with `new`, like a constant that has been renamed.
+//
+// Returns error if the case does not have `old`, if `new` is not valid
+// or if the switch already has a case for `new`.
+func (clause *CaseClause) ReplaceExpr(old, new string) error {
+	index := clause.indexOf(old)
+	if index == -1 {
+		return errors.Errorf("can't replace %s: case does not have it", old)
+	}
+
+	expr, err := clause.newExpr(new)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	clause.file.MoveComments(clause.Node.List[index], expr)
+
+	clause.Node.List[index] = expr
+
+	return nil
+}
+
+// Replaces the statements of the case with `body`.
+func (clause *CaseClause) SetBody(body []ast.Stmt) {
+	clause.Node.Body = body
+}
+
+// Removes the case from the switch.
+func (clause *CaseClause) Remove() {
+	list := make([]ast.Stmt, 0, len(clause.switchBody.List))
+
+	for _, stmt := range clause.switchBody.List {
+		if stmt != clause.Node {
+			list = append(list, stmt)
+		}
+	}
+
+	clause.switchBody.List = list
+}
+
+// Returns the position of `expr` in the expressions of the case or -1 if the case does not have it.
+func (clause *CaseClause) indexOf(expr string) int {
+	caseExpr, err := parseCaseExpr(expr)
+	if err != nil {
+		return -1
+	}
+
+	source := SourceCode(caseExpr)
+
+	for i, existing := range clause.Node.List {
+		if SourceCode(existing) == source {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Parses `expr` to be added to the case, checking that no case of the switch has it already.
+func (clause *CaseClause) newExpr(expr string) (ast.Expr, error) {
+	caseExpr, err := parseCaseExpr(expr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, ok := findCase(casesOf(clause.file, clause.switchBody), expr); ok {
+		return nil, errors.Errorf("switch already has a case for %s", expr)
+	}
+
+	return caseExpr, nil
+}
+
+// Returns true if the last statement of the case is fallthrough.
+func (clause *CaseClause) fallsThrough() bool {
+	if len(clause.Node.Body) == 0 {
+		return false
+	}
+
+	branch, ok := clause.Node.Body[len(clause.Node.Body)-1].(*ast.BranchStmt)
+
+	return ok && branch.Tok == token.FALLTHROUGH
+}
+
+func parseCaseExpr(source string) (ast.Expr, error) {
+	expr, err := parser.ParseExpr(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid case expression %s", source)
+	}
+
+	return cloneNode(expr).(ast.Expr), nil
+}
+
+func casesOf(file *SourceFile, switchBody *ast.BlockStmt) []CaseClause {
+	out := make([]CaseClause, 0, len(switchBody.List))
+
+	for _, stmt := range switchBody.List {
+		if clause, ok := stmt.(*ast.CaseClause); ok {
+			out = append(out, CaseClause{Node: clause, file: file, switchBody: switchBody})
+		}
+	}
+
+	return out
+}
+
+func findCase(cases []CaseClause, expr string) (CaseClause, bool) {
+	for _, clause := range cases {
+		if clause.Has(expr) {
+			return clause, true
+		}
+	}
+
+	return CaseClause{}, false
+}
+
+func findDefault(cases []CaseClause) (CaseClause, bool) {
+	for _, clause := range cases {
+		if clause.IsDefault() {
+			return clause, true
+		}
+	}
+
+	return CaseClause{}, false
+}
+
+func addCase(file *SourceFile, switchBody *ast.BlockStmt, exprs []string, body []ast.Stmt) (CaseClause, error) {
+	if len(exprs) == 0 {
+		return CaseClause{}, errors.New("can't add case without expressions, use AddDefault to add the default case")
+	}
+
+	clause := CaseClause{Node: &ast.CaseClause{List: make([]ast.Expr, 0, len(exprs)), Body: body}, file: file, switchBody: switchBody}
+
+	for _, expr := range exprs {
+		caseExpr, err := clause.newExpr(expr)
+		if err != nil {
+			return CaseClause{}, errors.WithStack(err)
+		}
+
+		if clause.Has(expr) {
+			return CaseClause{}, errors.Errorf("can't add case: %s appears more than once", expr)
+		}
+
+		clause.Node.List = append(clause.Node.List, caseExpr)
+	}
+
+	index := len(switchBody.List)
+	if defaultCase, ok := findDefault(casesOf(file, switchBody)); ok {
+		for i, stmt := range switchBody.List {
+			if stmt == defaultCase.Node {
+				index = i
+			}
+		}
+	}
+
+	list := make([]ast.Stmt, 0, len(switchBody.List)+1)
+	list = append(list, switchBody.List[:index]...)
+	list = append(list, clause.Node)
+	list = append(list, switchBody.List[index:]...)
+
+	switchBody.List = list
+
+	return clause, nil
+}
+
+func addDefault(file *SourceFile, switchBody *ast.BlockStmt, body []ast.Stmt) (CaseClause, error) {
+	if _, ok := findDefault(casesOf(file, switchBody)); ok {
+		return CaseClause{}, errors.New("switch already has a default case")
+	}
+
+	clause := CaseClause{Node: &ast.CaseClause{Body: body}, file: file, switchBody: switchBody}
+
+	switchBody.List = append(switchBody.List, clause.Node)
+
+	return clause, nil
+}
+
+func removeCase(cases []CaseClause, expr string) error {
+	for i, clause := range cases {
+		if !clause.Has(expr) {
+			continue
+		}
+
+		if i > 0 && cases[i-1].fallsThrough() {
+			return errors.Errorf("can't remove case %s: the case before it falls through to it", expr)
+		}
+
+		clause.Remove()
+
+		return nil
+	}
+
+	return errors.Errorf("can't remove case: switch does not have a case for %s", expr)
+}
+
+// `boundVariable` is the variable bound by a type switch, if any, the merged cases can't use it.
+func mergeCases(cases []CaseClause, exprs []string, boundVariable string) (CaseClause, error) {
+	merged := make([]int, 0, len(exprs))
+
+	for _, expr := range exprs {
+		index := -1
+		for i, clause := range cases {
+			if clause.Has(expr) {
+				index = i
+			}
+		}
+
+		if index == -1 {
+			return CaseClause{}, errors.Errorf("can't merge cases: switch does not have a case for %s", expr)
+		}
+
+		if !containsInt(merged, index) {
+			merged = append(merged, index)
+		}
+	}
+
+	if len(merged) == 0 {
+		return CaseClause{}, errors.New("can't merge cases: no expressions given")
+	}
+
+	sort.Ints(merged)
+
+	first := cases[merged[0]]
+	body := SourceCode(cloneNode(&ast.BlockStmt{List: first.Node.Body}))
+
+	for _, index := range merged[1:] {
+		clause := cases[index]
+
+		if clause.IsDefault() || first.IsDefault() {
+			return CaseClause{}, errors.New("can't merge cases: the default case can't be merged")
+		}
+
+		if SourceCode(cloneNode(&ast.BlockStmt{List: clause.Node.Body})) != body {
+			return CaseClause{}, errors.Errorf("can't merge case %s: its body is not the same as the body of case %s",
+				clause.Exprs()[0], first.Exprs()[0])
+		}
+
+		if clause.fallsThrough() || cases[index-1].fallsThrough() {
+			return CaseClause{}, errors.Errorf("can't merge case %s: it falls through or is fallen through to", clause.Exprs()[0])
+		}
+	}
+
+	if len(merged) > 1 && boundVariable != "" {
+		for _, stmt := range first.Node.Body {
+			if mentionsName(stmt, boundVariable) {
+				return CaseClause{}, errors.Errorf("can't merge cases: their bodies use %s, which would have the type of the switch expression", boundVariable)
+			}
+		}
+	}
+
+	for _, index := range merged[1:] {
+		clause := cases[index]
+
+		first.Node.List = append(first.Node.List, clause.Node.List...)
+
+		clause.Remove()
+	}
+
+	return first, nil
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+
+	return false
+}
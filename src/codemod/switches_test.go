@@ -0,0 +1,220 @@
+package codemod_test
+
+import (
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SwitchStmt_Cases(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func describe(status Status) string {
+	switch status {
+	case StatusActive, StatusPending:
+		return "active"
+	case StatusDisabled:
+		return "inactive"
+	case StatusDeleted:
+		return "inactive"
+	case StatusUnknown:
+		fallthrough
+	default:
+		return "unknown"
+	}
+}
+`)})
+	assert.NoError(t, err)
+
+	var stmt codemod.SwitchStmt
+	for _, statements := range file.SwitchStatements() {
+		stmt = statements[0]
+	}
+
+	cases := stmt.Cases()
+	assert.Equal(t, 5, len(cases))
+	assert.Equal(t, []string{"StatusActive", "StatusPending"}, cases[0].Exprs())
+	assert.True(t, cases[4].IsDefault())
+
+	active, ok := stmt.Case("StatusPending")
+	assert.True(t, ok)
+	assert.NoError(t, active.ReplaceExpr("StatusPending", "StatusWaiting"))
+	assert.Error(t, active.ReplaceExpr("StatusPending", "StatusWaiting"))
+	assert.Error(t, active.AddExpr("StatusDeleted"))
+	assert.Error(t, active.AddExpr("case"))
+
+	_, err = stmt.MergeCases("StatusActive", "StatusDisabled")
+	assert.Error(t, err)
+
+	merged, err := stmt.MergeCases("StatusDisabled", "StatusDeleted")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"StatusDisabled", "StatusDeleted"}, merged.Exprs())
+
+	assert.Error(t, stmt.RemoveCase("StatusMissing"))
+	assert.NoError(t, stmt.RemoveCase("StatusUnknown"))
+
+	_, err = stmt.AddCase([]string{"StatusActive"}, nil)
+	assert.Error(t, err)
+
+	_, err = stmt.AddCase([]string{"StatusArchived"}, codemod.Stmts(`return "archived"`))
+	assert.NoError(t, err)
+
+	_, err = stmt.AddDefault(nil)
+	assert.Error(t, err)
+
+	defaultCase, ok := stmt.Default()
+	assert.True(t, ok)
+	defaultCase.SetBody(codemod.Stmts(`panic(status)`))
+
+	expected := `package main
+
+func describe(status Status) string {
+	switch status {
+	case StatusActive, StatusWaiting:
+		return "active"
+	case StatusDisabled, StatusDeleted:
+		return "inactive"
+	case StatusArchived:
+		return "archived"
+	default:
+		panic(status)
+	}
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
+
+func Test_SourceFile_TypeSwitchStatements(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+import "os"
+
+func handle(err error) {
+	switch err := err.(type) {
+	case *os.PathError:
+		println(err.Error())
+	case nil:
+		return
+	}
+
+	switch x := 1; x {
+	case 1:
+	}
+}
+`)})
+	assert.NoError(t, err)
+
+	scopedStatements := file.TypeSwitchStatements()
+	assert.Equal(t, 1, len(scopedStatements))
+
+	for _, statements := range scopedStatements {
+		assert.Equal(t, 1, len(statements))
+
+		stmt := statements[0]
+
+		_, ok := stmt.Default()
+		assert.False(t, ok)
+
+		pathError, ok := stmt.Case("*os.PathError")
+		assert.True(t, ok)
+		assert.NoError(t, pathError.AddExpr("*os.LinkError"))
+
+		assert.NoError(t, stmt.RemoveCase("nil"))
+
+		_, err := stmt.AddDefault(codemod.Stmts(`panic(err)`))
+		assert.NoError(t, err)
+	}
+
+	expected := `package main
+
+import "os"
+
+func handle(err error) {
+	switch err := err.(type) {
+	case *os.PathError, *os.LinkError:
+		println(err.Error())
+	default:
+		panic(err)
+	}
+
+	switch x := 1; x {
+	case 1:
+	}
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
+
+func Test_TypeSwitchStmt_MergeCases(t *testing.T) {
+	t.Parallel()
+
+	file, err := codemod.New(codemod.NewInput{SourceCode: []byte(`package main
+
+func area(shape Shape) int {
+	switch v := shape.(type) {
+	case Square:
+		return v.Width
+	case Rectangle:
+		return v.Width
+	case Circle:
+		return 0
+	case Point:
+		return 0
+	}
+
+	switch shape.(type) {
+	case Square:
+		return 1
+	case Rectangle:
+		return 1
+	}
+
+	return 0
+}
+`)})
+	assert.NoError(t, err)
+
+	statements := file.TypeSwitchStatementsInOrder()
+	assert.Equal(t, 2, len(statements))
+
+	_, err = statements[0].MergeCases("Square", "Rectangle")
+	assert.Error(t, err)
+
+	merged, err := statements[0].MergeCases("Circle", "Point")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Circle", "Point"}, merged.Exprs())
+
+	merged, err = statements[1].MergeCases("Square", "Rectangle")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Square", "Rectangle"}, merged.Exprs())
+
+	expected := `package main
+
+func area(shape Shape) int {
+	switch v := shape.(type) {
+	case Square:
+		return v.Width
+	case Rectangle:
+		return v.Width
+	case Circle, Point:
+		return 0
+	}
+
+	switch shape.(type) {
+	case Square, Rectangle:
+		return 1
+	}
+
+	return 0
+}
+`
+
+	assert.Equal(t, expected, string(file.SourceCode()))
+}
@@ -0,0 +1,283 @@
+package codemod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Values for the named placeholders of a template, like $err in Expr("f($err)").
+//
+// See Expr for the values that can be used.
+type Placeholders map[string]interface{}
+
+// Builds an expression from `template`.
+//
+// Placeholders in the template are replaced by `args`:
+//
+// %s is replaced by the next argument.
+//
+// $name and $name... are replaced by the value called name in a Placeholders argument.
+// $ inside of string and rune literals, like "$1" in SQL queries, is not a placeholder.
+//
+// %% is replaced by %.
+//
+// Arguments that are ast nodes are spliced into the new node.
+// Arguments that are lists of nodes, like []ast.Expr, are spliced into
+// the list the placeholder is in, like the arguments of a call.
+// Arguments that are strings are pasted into the template as source code,
+// which is useful for names:
+//
+//	codemod.Expr("%s.Close(%s)", "file", ctx)
+//
+//	codemod.Expr("fmt.Errorf($format, $args...)", codemod.Placeholders{
+//		"format": format,
+//		"args":   call.Args[1:],
+//	})
+//
+// A node is only spliced as it is the first time it is used,
+// copies of it are used in the other places it appears in.
+// The new node does not have position information.
+//
+// Panics if the template is not a valid expression once
+// placeholders are replaced, or if the arguments don't match the placeholders.
+func Expr(template string, args ...interface{}) ast.Expr {
+	return buildFromTemplate(template, args, "expression", func(source string) (ast.Node, error) {
+		return parser.ParseExpr(source)
+	}).(ast.Expr)
+}
+
+// Builds a list of statements from `template`.
+//
+// See Expr.
+func Stmts(template string, args ...interface{}) []ast.Stmt {
+	block := buildFromTemplate(template, args, "statements", func(source string) (ast.Node, error) {
+		file, err := parser.ParseFile(token.NewFileSet(), "", fmt.Sprintf("package p; func _() {\n%s\n}", source), 0)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return file.Decls[0].(*ast.FuncDecl).Body, nil
+	}).(*ast.BlockStmt)
+
+	return block.List
+}
+
+// Builds a declaration, like a function or a type declaration, from `template`.
+//
+// See Expr.
+func Decl(template string, args ...interface{}) ast.Decl {
+	return buildFromTemplate(template, args, "declaration", func(source string) (ast.Node, error) {
+		file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+source, 0)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if len(file.Decls) != 1 {
+			return nil, errors.Errorf("template must have one declaration, got %d", len(file.Decls))
+		}
+
+		return file.Decls[0], nil
+	}).(ast.Decl)
+}
+
+// Builds a type, like map[string]int, from `template`.
+//
+// See Expr.
+func Type(template string, args ...interface{}) ast.Expr {
+	return buildFromTemplate(template, args, "type", func(source string) (ast.Node, error) {
+		file, err := parser.ParseFile(token.NewFileSet(), "", fmt.Sprintf("package p; var _ %s", source), 0)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type, nil
+	}).(ast.Expr)
+}
+
+// Replaces the placeholders of `template`, parses it with `parse`
+// and splices the arguments into the parsed node.
+func buildFromTemplate(template string, args []interface{}, kind string, parse func(string) (ast.Node, error)) ast.Node {
+	source, matcher, err := expandTemplate(template, args)
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid %s template: %s", kind, template))
+	}
+
+	node, err := parse(source)
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid %s template: %s", kind, template))
+	}
+
+	return matcher.fill(node)
+}
+
+// Replaces the placeholders of `template` with metavariables, or with the source code
+// of string arguments, and returns a matcher with the nodes bound to each metavariable.
+func expandTemplate(template string, args []interface{}) (string, *patternMatcher, error) {
+	matcher := newPatternMatcher()
+
+	var placeholders Placeholders
+
+	positional := make([]interface{}, 0, len(args))
+
+	for _, arg := range args {
+		if named, ok := arg.(Placeholders); ok {
+			if placeholders != nil {
+				return "", nil, errors.New("only one Placeholders argument can be used")
+			}
+
+			placeholders = named
+			continue
+		}
+
+		positional = append(positional, arg)
+	}
+
+	// Binds `value` to a metavariable and returns the metavariable,
+	// or returns `value` if it is source code.
+	bind := func(name string, value interface{}) (string, error) {
+		if source, ok := value.(string); ok {
+			return source, nil
+		}
+
+		nodes, list, err := templateNodes(value)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid value for %s", name)
+		}
+
+		matcher.bindings[name] = nodes
+
+		if list {
+			return variadicMetavariablePrefix + name, nil
+		}
+
+		return metavariablePrefix + name, nil
+	}
+
+	var source strings.Builder
+
+	next := 0
+
+	// Metavariables by the offset they start at, $ in string literals is not a placeholder.
+	metavariables := make(map[int]metavariableToken)
+	for _, metavariable := range scanMetavariables(template) {
+		metavariables[metavariable.start] = metavariable
+	}
+
+	for i := 0; i < len(template); i++ {
+		if template[i] == '%' && i+1 < len(template) {
+			switch template[i+1] {
+			case '%':
+				source.WriteByte('%')
+				i++
+				continue
+
+			case 's':
+				if next >= len(positional) {
+					return "", nil, errors.Errorf("missing argument for %%s number %d", next+1)
+				}
+
+				replacement, err := bind(fmt.Sprintf("_arg%d", next), positional[next])
+				if err != nil {
+					return "", nil, errors.WithStack(err)
+				}
+
+				source.WriteString(replacement)
+				next++
+				i++
+				continue
+			}
+		}
+
+		if template[i] == '$' {
+			if metavariable, ok := metavariables[i]; ok {
+				name := metavariable.name
+
+				value, ok := placeholders[name]
+				if !ok {
+					return "", nil, errors.Errorf("missing value for $%s", name)
+				}
+
+				replacement, err := bind(name, value)
+				if err != nil {
+					return "", nil, errors.WithStack(err)
+				}
+
+				source.WriteString(replacement)
+				i = metavariable.end - 1
+				continue
+			}
+		}
+
+		source.WriteByte(template[i])
+	}
+
+	if next != len(positional) {
+		return "", nil, errors.Errorf("template has %d %%s placeholders but got %d arguments", next, len(positional))
+	}
+
+	return source.String(), matcher, nil
+}
+
+// Returns the nodes in `value`, which is a node or a list of nodes,
+// and true if `value` is a list.
+func templateNodes(value interface{}) ([]ast.Node, bool, error) {
+	if node, ok := value.(ast.Node); ok {
+		if isNilNode(node) {
+			return nil, false, errors.New("node is nil")
+		}
+
+		return []ast.Node{node}, false, nil
+	}
+
+	list := reflect.ValueOf(value)
+
+	if !list.IsValid() || list.Kind() != reflect.Slice || !isNodeType(list.Type().Elem()) {
+		return nil, false, errors.Errorf("%T is not an ast node, a list of nodes or a string", value)
+	}
+
+	nodes := make([]ast.Node, 0, list.Len())
+
+	for i := 0; i < list.Len(); i++ {
+		nodes = append(nodes, list.Index(i).Interface().(ast.Node))
+	}
+
+	return nodes, true, nil
+}
+
+// Returns a copy of `template` without position information
+// where metavariables are replaced by the nodes bound to them.
+func (matcher *patternMatcher) fill(template ast.Node) ast.Node {
+	// The same node may be passed for more than one placeholder.
+	spliced := make(map[ast.Node]bool)
+
+	c := &cloner{
+		replace: func(node ast.Node) ([]ast.Node, bool) {
+			name, _, isMetavariable := metavariable(node)
+			if !isMetavariable {
+				return nil, false
+			}
+
+			nodes := make([]ast.Node, 0, len(matcher.bindings[name]))
+
+			for _, bound := range matcher.bindings[name] {
+				if spliced[bound] {
+					bound = cloneNode(bound)
+				} else {
+					spliced[bound] = true
+				}
+
+				nodes = append(nodes, adaptToTemplate(node, bound))
+			}
+
+			return nodes, true
+		},
+	}
+
+	return c.clone(template)
+}
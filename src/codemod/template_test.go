@@ -0,0 +1,79 @@
+package codemod_test
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Templates(t *testing.T) {
+	t.Parallel()
+
+	t.Run("builds expressions, statements, declarations and types", func(t *testing.T) {
+		ctx := codemod.Expr("context.Background()")
+
+		assert.Equal(t, "file.Close(context.Background())", codemod.SourceCode(codemod.Expr("%s.Close(%s)", "file", ctx)))
+
+		stmts := codemod.Stmts(`
+			x := %s
+			if x == nil {
+				return fmt.Sprintf("100%%")
+			}
+		`, ctx)
+		assert.Equal(t, 2, len(stmts))
+		assert.Equal(t, "x := context.Background()", codemod.SourceCode(stmts[0]))
+		assert.Equal(t, "if x == nil {\n\treturn fmt.Sprintf(\"100%\")\n}", codemod.SourceCode(stmts[1]))
+
+		decl := codemod.Decl("func %s(ctx $typ) error { return nil }", "handle", codemod.Placeholders{
+			"typ": codemod.Type("context.Context"),
+		})
+		assert.Equal(t, "func handle(ctx context.Context) error {\n\treturn nil\n}", codemod.SourceCode(decl))
+
+		typ := codemod.Type("map[%s][]$value", "string", codemod.Placeholders{"value": &ast.Ident{Name: "int"}})
+		assert.Equal(t, "map[string][]int", codemod.SourceCode(typ))
+	})
+
+	t.Run("splices lists of nodes into lists", func(t *testing.T) {
+		call := codemod.Expr(`errors.Wrapf(err, "finding %d", id)`).(*ast.CallExpr)
+
+		newCall := codemod.Expr("fmt.Errorf($format, $args..., $err)", codemod.Placeholders{
+			"format": call.Args[1],
+			"args":   call.Args[2:],
+			"err":    call.Args[0],
+		})
+		assert.Equal(t, `fmt.Errorf("finding %d", id, err)`, codemod.SourceCode(newCall))
+
+		stmts := codemod.Stmts("defer cancel()\n%s", codemod.Stmts("a()\nb()"))
+		assert.Equal(t, 3, len(stmts))
+	})
+
+	t.Run("copies nodes used more than once", func(t *testing.T) {
+		x := &ast.Ident{Name: "x"}
+
+		expr := codemod.Expr("%s + %s", x, x).(*ast.BinaryExpr)
+
+		assert.Same(t, x, expr.X)
+		assert.NotSame(t, x, expr.Y)
+		assert.Equal(t, "x + x", codemod.SourceCode(expr))
+	})
+
+	t.Run("panics when the template or the arguments are invalid", func(t *testing.T) {
+		assert.Panics(t, func() { codemod.Expr("f(%s)") })
+		assert.Panics(t, func() { codemod.Expr("f()", "x") })
+		assert.Panics(t, func() { codemod.Expr("f($x)") })
+		assert.Panics(t, func() { codemod.Expr("f(%s)", 1) })
+		assert.Panics(t, func() { codemod.Expr("x :=") })
+		assert.Panics(t, func() { codemod.Decl("var a = 1\nvar b = 2") })
+	})
+
+	t.Run("leaves $ inside of string and rune literals alone", func(t *testing.T) {
+		assert.Equal(t, `fmt.Sprint("$5")`, codemod.SourceCode(codemod.Expr(`fmt.Sprint("$5")`)))
+
+		query := codemod.Expr("db.Query(`SELECT * FROM users WHERE id = $1`, $id, '$')", codemod.Placeholders{
+			"id": &ast.Ident{Name: "userID"},
+		})
+		assert.Equal(t, "db.Query(`SELECT * FROM users WHERE id = $1`, userID, '$')", codemod.SourceCode(query))
+	})
+}
@@ -0,0 +1,370 @@
+package codemod
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+)
+
+// Type information about the package a source file belongs to.
+//
+// It is only available for source files created with NewTypeChecked.
+type packageInfo struct {
+	// The import path of the package.
+	path  string
+	types *types.Package
+	info  *types.Info
+	files []*SourceFile
+	// Every package type checked together with this one, including this one.
+	packages []*packageInfo
+}
+
+// A set of files that belong to the same package.
+type packageFiles struct {
+	directory string
+	name      string
+	path      string
+	files     []*SourceFile
+	info      *packageInfo
+}
+
+// Parses every file in `inputs` and type checks the files that belong to
+// the same package together.
+//
+// Files are grouped into packages using the directory in their path
+// and the name in their package clause, which means the paths should point
+// to where the files live on disk so their imports can be resolved.
+//
+// Packages that import each other are type checked once and share
+// their type information, imports that are not part of `inputs` are
+// loaded from source.
+//
+// Type errors do not stop type checking, the type information
+// for the parts of the package that could be checked is still available.
+func NewTypeChecked(inputs []NewInput) ([]*SourceFile, error) {
+	fileSet := token.NewFileSet()
+
+	packages := make(map[string]*packageFiles)
+
+	out := make([]*SourceFile, 0, len(inputs))
+
+	for _, input := range inputs {
+		file, err := parser.ParseFile(fileSet, input.FilePath, input.SourceCode, parser.ParseComments)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		sourceFile := &SourceFile{
+			fileSet:     fileSet,
+			file:        file,
+			FilePath:    input.FilePath,
+			decorations: decorate(fileSet, file),
+			source:      input.SourceCode,
+		}
+
+		out = append(out, sourceFile)
+
+		directory := filepath.Dir(input.FilePath)
+		key := directory + "#" + file.Name.Name
+
+		pkg, ok := packages[key]
+		if !ok {
+			pkg = &packageFiles{directory: directory, name: file.Name.Name}
+			packages[key] = pkg
+		}
+
+		pkg.files = append(pkg.files, sourceFile)
+	}
+
+	checker := newPackageChecker(fileSet, packages)
+
+	checked := make([]*packageInfo, 0, len(packages))
+
+	for _, key := range sortedKeys(packages) {
+		info, err := checker.check(packages[key])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		checked = append(checked, info)
+	}
+
+	for _, info := range checked {
+		info.packages = checked
+	}
+
+	return out, nil
+}
+
+func sortedKeys(packages map[string]*packageFiles) []string {
+	keys := make([]string, 0, len(packages))
+
+	for key := range packages {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Type checks packages and resolves imports between them.
+type packageChecker struct {
+	fileSet *token.FileSet
+	// Packages indexed by import path.
+	byPath map[string]*packageFiles
+	// Import paths of the packages being type checked at the moment,
+	// used to detect import cycles.
+	checking map[string]bool
+	// Used to load the standard library from export data.
+	std types.Importer
+	// Used to load anything that is not in the standard library
+	// and is not one of the packages being type checked.
+	source types.ImporterFrom
+}
+
+func newPackageChecker(fileSet *token.FileSet, packages map[string]*packageFiles) *packageChecker {
+	checker := &packageChecker{
+		fileSet:  fileSet,
+		byPath:   make(map[string]*packageFiles),
+		checking: make(map[string]bool),
+		std:      importer.Default(),
+		source:   importer.ForCompiler(fileSet, "source", nil).(types.ImporterFrom),
+	}
+
+	for _, pkg := range packages {
+		pkg.path = importPath(pkg.directory)
+
+		// External test packages live in the same directory as the package
+		// they test but can't be imported.
+		if strings.HasSuffix(pkg.name, "_test") {
+			pkg.path += "_test"
+			continue
+		}
+
+		checker.byPath[pkg.path] = pkg
+	}
+
+	return checker
+}
+
+func (checker *packageChecker) check(pkg *packageFiles) (*packageInfo, error) {
+	if pkg.info != nil {
+		return pkg.info, nil
+	}
+
+	if checker.checking[pkg.path] {
+		return nil, errors.Errorf("import cycle not allowed: %s", pkg.path)
+	}
+
+	checker.checking[pkg.path] = true
+	defer delete(checker.checking, pkg.path)
+
+	files := make([]*ast.File, 0, len(pkg.files))
+
+	for _, file := range pkg.files {
+		files = append(files, file.file)
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Instances:  make(map[*ast.Ident]types.Instance),
+	}
+
+	config := types.Config{
+		Importer:    checker,
+		FakeImportC: true,
+		// We want as much type information as possible even if
+		// the package does not compile, so errors are ignored.
+		Error: func(error) {},
+	}
+
+	typesPackage, _ := config.Check(pkg.path, checker.fileSet, files, info)
+
+	pkg.info = &packageInfo{
+		path:  pkg.path,
+		types: typesPackage,
+		info:  info,
+		files: pkg.files,
+	}
+
+	for _, file := range pkg.files {
+		file.pkg = pkg.info
+	}
+
+	return pkg.info, nil
+}
+
+func (checker *packageChecker) Import(importPath string) (*types.Package, error) {
+	return checker.ImportFrom(importPath, "", 0)
+}
+
+func (checker *packageChecker) ImportFrom(importPath, directory string, mode types.ImportMode) (*types.Package, error) {
+	if pkg, ok := checker.byPath[importPath]; ok {
+		info, err := checker.check(pkg)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		return info.types, nil
+	}
+
+	if isStandardLibrary(importPath) {
+		pkg, err := checker.std.Import(importPath)
+		if err == nil {
+			return pkg, nil
+		}
+	}
+
+	pkg, err := checker.source.ImportFrom(importPath, directory, mode)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return pkg, nil
+}
+
+// Returns true when `importPath` looks like the import path
+// of a standard library package.
+//
+// Packages outside of the standard library have a domain name
+// as the first element of their import paths.
+func isStandardLibrary(importPath string) bool {
+	firstElement := strings.Split(importPath, "/")[0]
+
+	return !strings.Contains(firstElement, ".")
+}
+
+// Returns the import path of the package in `directory`
+// using the module path in the closest go.mod file.
+//
+// If there's no go.mod file, the directory is used as import path.
+func importPath(directory string) string {
+	absoluteDirectory, err := filepath.Abs(directory)
+	if err != nil {
+		return filepath.ToSlash(directory)
+	}
+
+	moduleRoot, modulePath, ok := findModule(absoluteDirectory)
+	if !ok {
+		return filepath.ToSlash(directory)
+	}
+
+	relativePath, err := filepath.Rel(moduleRoot, absoluteDirectory)
+	if err != nil {
+		return filepath.ToSlash(directory)
+	}
+
+	return path.Join(modulePath, filepath.ToSlash(relativePath))
+}
+
+// Returns the directory of the closest go.mod file to `directory`
+// and the module path in it, and false if there's no go.mod file.
+func findModule(directory string) (string, string, bool) {
+	absoluteDirectory, err := filepath.Abs(directory)
+	if err != nil {
+		return "", "", false
+	}
+
+	for current := absoluteDirectory; ; current = filepath.Dir(current) {
+		contents, err := ioutil.ReadFile(filepath.Join(current, "go.mod"))
+		if err == nil {
+			return current, modfile.ModulePath(contents), true
+		}
+
+		if !os.IsNotExist(err) || filepath.Dir(current) == current {
+			return "", "", false
+		}
+	}
+}
+
+// Returns true when the source file was created with type information.
+func (code *SourceFile) IsTypeChecked() bool {
+	return code.pkg != nil
+}
+
+// Returns the type of `expr` or nil if the type is unknown
+// or the source file has not been type checked.
+func (code *SourceFile) TypeOf(expr ast.Expr) types.Type {
+	if code.pkg == nil {
+		return nil
+	}
+
+	return code.pkg.info.TypeOf(expr)
+}
+
+// Returns the object `ident` declares or refers to or nil if it is unknown
+// or the source file has not been type checked.
+func (code *SourceFile) ObjectOf(ident *ast.Ident) types.Object {
+	if code.pkg == nil {
+		return nil
+	}
+
+	return code.pkg.info.ObjectOf(ident)
+}
+
+// Returns the type checked package the source file belongs to or nil
+// if the source file has not been type checked.
+func (code *SourceFile) TypesPackage() *types.Package {
+	if code.pkg == nil {
+		return nil
+	}
+
+	return code.pkg.types
+}
+
+// Returns the function, method, builtin or variable being called
+// or nil if the callee is unknown or the source file has not been type checked.
+//
+// Conversions such as int64(x) have no callee.
+func (call *FunctionCall) Callee() types.Object {
+	if call.file == nil {
+		return nil
+	}
+
+	var ident *ast.Ident
+
+	switch fun := unparen(withoutTypeArgs(unparen(call.Node.Fun))).(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return nil
+	}
+
+	object := call.file.ObjectOf(ident)
+
+	if _, ok := object.(*types.TypeName); ok {
+		return nil
+	}
+
+	return object
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+
+		expr = paren.X
+	}
+}
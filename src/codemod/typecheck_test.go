@@ -0,0 +1,188 @@
+package codemod_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PoorlyDefinedBehaviour/apply_codemod/src/codemod"
+	"github.com/stretchr/testify/assert"
+)
+
+// Writes `files` to a temporary directory that contains a go.mod file
+// and returns the inputs that can be passed to codemod.NewTypeChecked.
+func writeModule(t *testing.T, files map[string]string) []codemod.NewInput {
+	t.Helper()
+
+	directory, err := ioutil.TempDir("", "codemod")
+	assert.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(directory) })
+
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(directory, "go.mod"),
+		[]byte("module example.com/project\n\ngo 1.16\n"),
+		os.ModePerm,
+	))
+
+	inputs := make([]codemod.NewInput, 0, len(files))
+
+	for path, sourceCode := range files {
+		filePath := filepath.Join(directory, path)
+
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), os.ModePerm))
+		assert.NoError(t, ioutil.WriteFile(filePath, []byte(sourceCode), os.ModePerm))
+
+		inputs = append(inputs, codemod.NewInput{SourceCode: []byte(sourceCode), FilePath: filePath})
+	}
+
+	return inputs
+}
+
+func findFile(t *testing.T, files []*codemod.SourceFile, name string) *codemod.SourceFile {
+	t.Helper()
+
+	for _, file := range files {
+		if filepath.Base(file.FilePath) == name {
+			return file
+		}
+	}
+
+	assert.FailNow(t, fmt.Sprintf("file not found: %s", name))
+
+	return nil
+}
+
+func Test_NewTypeChecked(t *testing.T) {
+	t.Parallel()
+
+	inputs := writeModule(t, map[string]string{
+		"errors/errors.go": `
+		package errors
+
+		import "fmt"
+
+		func Wrapf(err error, format string, args ...interface{}) error {
+			return fmt.Errorf(format+": %w", append(args, err)...)
+		}
+		`,
+		"main.go": `
+		package main
+
+		import (
+			"os"
+
+			pkgerrors "example.com/project/errors"
+		)
+
+		type wrapper struct{}
+
+		func (wrapper) Wrapf(err error, format string, args ...interface{}) error { return err }
+
+		func main() {
+			errors := wrapper{}
+
+			_ = errors.Wrapf(os.ErrNotExist, "a")
+			_ = pkgerrors.Wrapf(os.ErrNotExist, "b")
+			_ = int64(1)
+		}
+		`,
+	})
+
+	files, err := codemod.NewTypeChecked(inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+
+	file := findFile(t, files, "main.go")
+
+	assert.True(t, file.IsTypeChecked())
+	assert.Equal(t, "example.com/project", file.TypesPackage().Path())
+
+	t.Run("resolves callees", func(t *testing.T) {
+		callees := make(map[string]string)
+
+		for _, calls := range file.FunctionCalls() {
+			for _, call := range calls {
+				callee := call.Callee()
+				if callee == nil {
+					callees[call.FunctionName()] = ""
+					continue
+				}
+
+				callees[call.FunctionName()] = fmt.Sprintf("%s.%s", callee.Pkg().Path(), callee.Name())
+			}
+		}
+
+		expected := map[string]string{
+			"errors.Wrapf":    "example.com/project.Wrapf",
+			"pkgerrors.Wrapf": "example.com/project/errors.Wrapf",
+			"int64":           "",
+		}
+
+		assert.Equal(t, expected, callees)
+	})
+
+	t.Run("returns types of expressions", func(t *testing.T) {
+		for _, calls := range file.FunctionCalls() {
+			for _, call := range calls {
+				if call.FunctionName() != "pkgerrors.Wrapf" {
+					continue
+				}
+
+				assert.Equal(t, "error", file.TypeOf(call.Node.Args[0]).String())
+				assert.Equal(t, "string", file.TypeOf(call.Node.Args[1]).String())
+				assert.Equal(t, "error", file.TypeOf(call.Node).String())
+			}
+		}
+	})
+
+	t.Run("returns objects of identifiers", func(t *testing.T) {
+		for _, calls := range file.FunctionCalls() {
+			for _, call := range calls {
+				selector, ok := call.Node.Fun.(*ast.SelectorExpr)
+				if !ok {
+					continue
+				}
+
+				object := file.ObjectOf(selector.X.(*ast.Ident))
+
+				switch call.FunctionName() {
+				case "errors.Wrapf":
+					_, isVariable := object.(*types.Var)
+					assert.True(t, isVariable)
+				case "pkgerrors.Wrapf":
+					pkgName, isPackage := object.(*types.PkgName)
+					assert.True(t, isPackage)
+					assert.Equal(t, "example.com/project/errors", pkgName.Imported().Path())
+				}
+			}
+		}
+	})
+}
+
+func Test_SourceFile_WithoutTypeInformation(t *testing.T) {
+	t.Parallel()
+
+	file, _ := codemod.New(codemod.NewInput{SourceCode: []byte(`
+	package main
+
+	func main() {
+		println(1)
+	}
+	`)})
+
+	assert.False(t, file.IsTypeChecked())
+	assert.Nil(t, file.TypesPackage())
+
+	for _, calls := range file.FunctionCalls() {
+		for _, call := range calls {
+			assert.Nil(t, call.Callee())
+			assert.Nil(t, file.TypeOf(call.Node))
+			assert.Nil(t, file.ObjectOf(call.Node.Fun.(*ast.Ident)))
+		}
+	}
+}